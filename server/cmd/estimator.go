@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/m1k1o/neko/server/internal/config"
+	"github.com/m1k1o/neko/server/internal/webrtc/estimator"
+)
+
+func init() {
+	command := &cobra.Command{
+		Use:   "estimator [trace]",
+		Short: "replay recorded estimator trace",
+		Long:  `replay estimator trace recorded by a peer through a strategy and print its decisions`,
+		Run:   estimatorCmd,
+		Args:  cobra.ExactArgs(1),
+	}
+
+	// flags are not bound to global config, they would override those of serve command
+	config.WebRTCEstimator{}.Flags(command.Flags())
+	command.Flags().StringSlice("ids", []string{}, "video ids ordered from the highest to the lowest, by recorded bitrate if not set")
+
+	root.AddCommand(command)
+}
+
+func estimatorCmd(cmd *cobra.Command, args []string) {
+	v := viper.New()
+	if err := v.BindPFlags(cmd.Flags()); err != nil {
+		log.Fatal().Err(err).Msg("unable to bind flags")
+	}
+
+	conf := config.WebRTCEstimator{}
+	conf.SetFrom(v)
+
+	logger := zerolog.Nop()
+	if conf.Debug {
+		logger = log.With().Str("component", "estimator").Str("strategy", conf.Strategy).Logger().Level(zerolog.DebugLevel)
+	}
+
+	strategy, err := estimator.New(conf, logger)
+	if err != nil {
+		log.Fatal().Err(err).Strs("strategies", estimator.Strategies()).Msg("unable to create strategy")
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		log.Fatal().Err(err).Msg("unable to open trace")
+	}
+	defer f.Close()
+
+	trace, err := estimator.ReadTrace(f)
+	if err != nil {
+		log.Fatal().Err(err).Msg("unable to read trace")
+	}
+
+	ids, _ := cmd.Flags().GetStringSlice("ids")
+	streams := estimator.TraceStreams(trace, ids)
+	result := estimator.Replay(strategy, trace, streams)
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(result); err != nil {
+		log.Fatal().Err(err).Msg("unable to marshal result")
+	}
+}
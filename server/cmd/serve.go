@@ -180,6 +180,7 @@ func (c *serve) Start(cmd *cobra.Command) {
 		c.managers.member,
 		c.managers.desktop,
 		c.managers.capture,
+		c.managers.webRTC,
 	)
 
 	c.managers.plugins = plugins.New(
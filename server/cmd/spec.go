@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	neko "github.com/m1k1o/neko/server"
+	"github.com/m1k1o/neko/server/internal/api"
+	"github.com/m1k1o/neko/server/internal/config"
+	"github.com/m1k1o/neko/server/internal/plugins"
+	"github.com/m1k1o/neko/server/internal/session"
+	"github.com/m1k1o/neko/server/internal/spec"
+	"github.com/m1k1o/neko/server/internal/websocket"
+)
+
+func init() {
+	command := &cobra.Command{
+		Use:       "spec [openapi|asyncapi]",
+		Short:     "print machine-readable api specification",
+		Long:      `print OpenAPI specification of the REST API or AsyncAPI specification of the WebSocket API`,
+		Run:       specCmd,
+		Args:      cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		ValidArgs: []string{"openapi", "asyncapi"},
+	}
+
+	command.Flags().String("plugins", "", "directory with external plugins, whose routes should be included")
+	command.Flags().StringP("output", "o", "", "write specification to a file instead of stdout")
+
+	root.AddCommand(command)
+}
+
+func specCmd(cmd *cobra.Command, args []string) {
+	version := neko.Version.String()
+
+	var doc any
+	switch args[0] {
+	case "openapi":
+		pluginDir, _ := cmd.Flags().GetString("plugins")
+
+		// managers are only created to register routes, none of them is started
+		sessions := session.New(&config.Session{})
+		webSocket := websocket.New(sessions, nil, nil, nil)
+		apiManager := api.New(sessions, nil, nil, nil, nil)
+
+		plugs := plugins.New(&config.Plugins{
+			Enabled:  pluginDir != "",
+			Required: true,
+			Dir:      pluginDir,
+		})
+		plugs.Start(sessions, webSocket, apiManager)
+
+		doc = spec.NewOpenAPI(version, "/api", apiManager.Route, apiManager.Authenticate)
+	case "asyncapi":
+		doc = spec.NewAsyncAPI(version, "/api/ws")
+	}
+
+	out := os.Stdout
+	if output, _ := cmd.Flags().GetString("output"); output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			log.Fatal().Err(err).Msg("unable to create output file")
+		}
+		defer f.Close()
+		out = f
+	}
+
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		log.Fatal().Err(err).Msg("unable to marshal specification")
+	}
+}
@@ -1,24 +1,37 @@
 package room
 
 import (
+	"errors"
 	"net/http"
+	"regexp"
 
-	"github.com/m1k1o/neko/server/pkg/types/event"
-	"github.com/m1k1o/neko/server/pkg/types/message"
+	"github.com/go-chi/chi/v5"
+
+	"github.com/m1k1o/neko/server/pkg/types"
 	"github.com/m1k1o/neko/server/pkg/utils"
 )
 
 type BroadcastStatusPayload struct {
 	URL      string `json:"url,omitempty"`
 	IsActive bool   `json:"is_active"`
+	// all started destinations including the default one
+	Destinations []types.BroadcastDestination `json:"destinations,omitempty"`
+}
+
+type BroadcastDestinationPayload struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
 }
 
+var broadcastIdRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
 func (h *RoomHandler) broadcastStatus(w http.ResponseWriter, r *http.Request) error {
 	broadcast := h.capture.Broadcast()
 
 	return utils.HttpSuccess(w, BroadcastStatusPayload{
-		IsActive: broadcast.Started(),
-		URL:      broadcast.Url(),
+		IsActive:     broadcast.Started(),
+		URL:          broadcast.Url(),
+		Destinations: broadcast.Destinations(),
 	})
 }
 
@@ -41,13 +54,6 @@ func (h *RoomHandler) broadcastStart(w http.ResponseWriter, r *http.Request) err
 		return utils.HttpInternalServerError().WithInternalErr(err)
 	}
 
-	h.sessions.AdminBroadcast(
-		event.BROADCAST_STATUS,
-		message.BroadcastStatus{
-			IsActive: broadcast.Started(),
-			URL:      broadcast.Url(),
-		})
-
 	return utils.HttpSuccess(w)
 }
 
@@ -59,12 +65,48 @@ func (h *RoomHandler) broadcastStop(w http.ResponseWriter, r *http.Request) erro
 
 	broadcast.Stop()
 
-	h.sessions.AdminBroadcast(
-		event.BROADCAST_STATUS,
-		message.BroadcastStatus{
-			IsActive: broadcast.Started(),
-			URL:      broadcast.Url(),
-		})
+	return utils.HttpSuccess(w)
+}
+
+func (h *RoomHandler) broadcastDestinationsList(w http.ResponseWriter, r *http.Request) error {
+	return utils.HttpSuccess(w, h.capture.Broadcast().Destinations())
+}
+
+func (h *RoomHandler) broadcastDestinationStart(w http.ResponseWriter, r *http.Request) error {
+	data := &BroadcastDestinationPayload{}
+	if err := utils.HttpJsonRequest(w, r, data); err != nil {
+		return err
+	}
+
+	if !broadcastIdRegex.MatchString(data.ID) {
+		return utils.HttpBadRequest("invalid broadcast destination id")
+	}
+
+	if data.URL == "" {
+		return utils.HttpBadRequest("missing broadcast URL")
+	}
+
+	err := h.capture.Broadcast().StartDestination(data.ID, data.URL)
+	if errors.Is(err, types.ErrCapturePipelineAlreadyExists) {
+		return utils.HttpUnprocessableEntity("destination is already broadcasting")
+	}
+	if err != nil {
+		return utils.HttpInternalServerError().WithInternalErr(err)
+	}
+
+	return utils.HttpSuccess(w)
+}
+
+func (h *RoomHandler) broadcastDestinationStop(w http.ResponseWriter, r *http.Request) error {
+	destinationId := chi.URLParam(r, "destinationId")
+
+	err := h.capture.Broadcast().StopDestination(destinationId)
+	if errors.Is(err, types.ErrBroadcastNotFound) {
+		return utils.HttpNotFound("destination is not broadcasting")
+	}
+	if err != nil {
+		return utils.HttpInternalServerError().WithInternalErr(err)
+	}
 
 	return utils.HttpSuccess(w)
 }
@@ -0,0 +1,80 @@
+package room
+
+import (
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/m1k1o/neko/server/pkg/types"
+	"github.com/m1k1o/neko/server/pkg/utils"
+)
+
+func (h *RoomHandler) forwardList(w http.ResponseWriter, r *http.Request) error {
+	return utils.HttpSuccess(w, h.capture.Forward().List())
+}
+
+func (h *RoomHandler) forwardStart(w http.ResponseWriter, r *http.Request) error {
+	data := &types.Forward{}
+	if err := utils.HttpJsonRequest(w, r, data); err != nil {
+		return err
+	}
+
+	if data.Protocol != types.ForwardProtocolRTP && data.Protocol != types.ForwardProtocolSRT {
+		return utils.HttpBadRequest("protocol must be either rtp or srt")
+	}
+
+	if _, _, err := net.SplitHostPort(data.Address); err != nil {
+		return utils.HttpBadRequest("address must be in host:port format")
+	}
+
+	forward, err := h.capture.Forward().Start(*data)
+	if err != nil {
+		return utils.HttpUnprocessableEntity("unable to start forward").WithInternalErr(err)
+	}
+
+	return utils.HttpSuccess(w, forward)
+}
+
+func (h *RoomHandler) forwardGet(w http.ResponseWriter, r *http.Request) error {
+	forwardId := chi.URLParam(r, "forwardId")
+
+	forward, ok := h.capture.Forward().Get(forwardId)
+	if !ok {
+		return utils.HttpNotFound("forward was not found")
+	}
+
+	return utils.HttpSuccess(w, forward)
+}
+
+func (h *RoomHandler) forwardStop(w http.ResponseWriter, r *http.Request) error {
+	forwardId := chi.URLParam(r, "forwardId")
+
+	err := h.capture.Forward().Stop(forwardId)
+	if errors.Is(err, types.ErrForwardNotFound) {
+		return utils.HttpNotFound("forward was not found")
+	}
+	if err != nil {
+		return utils.HttpInternalServerError().WithInternalErr(err)
+	}
+
+	return utils.HttpSuccess(w)
+}
+
+func (h *RoomHandler) forwardSDP(w http.ResponseWriter, r *http.Request) error {
+	forwardId := chi.URLParam(r, "forwardId")
+
+	sdp, err := h.capture.Forward().SDP(forwardId)
+	if errors.Is(err, types.ErrForwardNotFound) {
+		return utils.HttpNotFound("forward was not found")
+	}
+	if err != nil {
+		return utils.HttpUnprocessableEntity(err.Error())
+	}
+
+	w.Header().Set("Content-Type", "application/sdp")
+
+	_, err = w.Write([]byte(sdp))
+	return err
+}
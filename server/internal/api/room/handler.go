@@ -64,6 +64,32 @@ func (h *RoomHandler) Route(r types.Router) {
 		r.Get("/", h.broadcastStatus)
 		r.Post("/start", h.broadcastStart)
 		r.Post("/stop", h.broadcastStop)
+
+		r.Get("/destinations", h.broadcastDestinationsList)
+		r.Post("/destinations", h.broadcastDestinationStart)
+		r.Delete("/destinations/{destinationId}", h.broadcastDestinationStop)
+	})
+
+	r.With(auth.AdminsOnly).Route("/recording", func(r types.Router) {
+		r.Get("/", h.recordingStatus)
+		r.Post("/start", h.recordingStart)
+		r.Post("/stop", h.recordingStop)
+		r.Get("/list", h.recordingList)
+		r.Get("/download/{recordingName}", h.recordingDownload)
+	})
+
+	r.With(auth.AdminsOnly).Route("/forward", func(r types.Router) {
+		r.Get("/", h.forwardList)
+		r.Post("/", h.forwardStart)
+		r.Get("/{forwardId}", h.forwardGet)
+		r.Delete("/{forwardId}", h.forwardStop)
+		r.Get("/{forwardId}/sdp", h.forwardSDP)
+	})
+
+	r.With(auth.AdminsOnly).Route("/microphone", func(r types.Router) {
+		r.Get("/", h.microphoneStatus)
+		r.Post("/mute/{sessionId}", h.microphoneMute)
+		r.Post("/unmute/{sessionId}", h.microphoneUnmute)
 	})
 
 	r.With(auth.CanAccessClipboardOnly).With(auth.HostsOnly).Route("/clipboard", func(r types.Router) {
@@ -105,9 +131,15 @@ func (h *RoomHandler) Route(r types.Router) {
 		r.With(auth.AdminsOnly).Get("/configurations", h.screenConfigurationsList)
 
 		r.Get("/cast.jpg", h.screenCastGet)
+		r.Get("/cast.mjpeg", h.screenCastStream)
 		r.With(auth.AdminsOnly).Get("/shot.jpg", h.screenShotGet)
 	})
 
+	r.With(auth.CanWatchOnly).With(h.hlsMiddleware).Route("/hls", func(r types.Router) {
+		r.Get("/playlist.m3u8", h.hlsPlaylist)
+		r.Get("/{segmentName}", h.hlsSegment)
+	})
+
 	r.With(h.uploadMiddleware).Route("/upload", func(r types.Router) {
 		r.Post("/drop", h.uploadDrop)
 		r.Post("/dialog", h.uploadDialogPost)
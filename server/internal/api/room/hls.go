@@ -0,0 +1,85 @@
+package room
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/m1k1o/neko/server/pkg/auth"
+	"github.com/m1k1o/neko/server/pkg/types"
+	"github.com/m1k1o/neko/server/pkg/utils"
+)
+
+func (h *RoomHandler) hlsMiddleware(w http.ResponseWriter, r *http.Request) (context.Context, error) {
+	if !h.capture.HLS().Enabled() {
+		return nil, utils.HttpBadRequest("hls is not enabled")
+	}
+
+	// private mode hides the screen from everyone who is not an admin
+	if session, ok := auth.GetSession(r); ok && session.PrivateModeEnabled() {
+		return nil, utils.HttpForbidden("private mode is enabled")
+	}
+
+	return nil, nil
+}
+
+func (h *RoomHandler) hlsPlaylist(w http.ResponseWriter, r *http.Request) error {
+	playlist, err := h.capture.HLS().Playlist()
+	if err != nil {
+		return utils.HttpInternalServerError().WithInternalErr(err)
+	}
+
+	// players do not add query to segment urls, so that token must be added to them
+	if r.URL.RawQuery != "" {
+		playlist = hlsAppendQuery(playlist, r.URL.RawQuery)
+	}
+
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+
+	_, err = w.Write(playlist)
+	return err
+}
+
+func (h *RoomHandler) hlsSegment(w http.ResponseWriter, r *http.Request) error {
+	segmentName := chi.URLParam(r, "segmentName")
+
+	path, err := h.capture.HLS().Segment(segmentName)
+	if errors.Is(err, types.ErrHLSSegmentNotFound) {
+		return utils.HttpNotFound("segment was not found")
+	}
+	if err != nil {
+		return utils.HttpInternalServerError().WithInternalErr(err)
+	}
+
+	w.Header().Set("Content-Type", "video/mp2t")
+	http.ServeFile(w, r, path)
+	return nil
+}
+
+// hlsAppendQuery appends query to every uri line of the playlist.
+func hlsAppendQuery(playlist []byte, query string) []byte {
+	var out bytes.Buffer
+
+	scanner := bufio.NewScanner(bytes.NewReader(playlist))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line != "" && !strings.HasPrefix(line, "#") {
+			if strings.Contains(line, "?") {
+				line += "&" + query
+			} else {
+				line += "?" + query
+			}
+		}
+
+		out.WriteString(line)
+		out.WriteByte('\n')
+	}
+
+	return out.Bytes()
+}
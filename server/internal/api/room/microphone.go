@@ -0,0 +1,66 @@
+package room
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/m1k1o/neko/server/pkg/types"
+	"github.com/m1k1o/neko/server/pkg/types/event"
+	"github.com/m1k1o/neko/server/pkg/types/message"
+	"github.com/m1k1o/neko/server/pkg/utils"
+)
+
+type MicrophoneStatusPayload struct {
+	Mixing bool                   `json:"mixing"`
+	Inputs []types.StreamSrcInput `json:"inputs"`
+}
+
+func (h *RoomHandler) microphoneStatus(w http.ResponseWriter, r *http.Request) error {
+	microphone := h.capture.Microphone()
+
+	return utils.HttpSuccess(w, MicrophoneStatusPayload{
+		Mixing: microphone.Mixing(),
+		Inputs: microphone.Inputs(),
+	})
+}
+
+func (h *RoomHandler) microphoneMute(w http.ResponseWriter, r *http.Request) error {
+	return h.microphoneSetMuted(w, r, true)
+}
+
+func (h *RoomHandler) microphoneUnmute(w http.ResponseWriter, r *http.Request) error {
+	return h.microphoneSetMuted(w, r, false)
+}
+
+func (h *RoomHandler) microphoneSetMuted(w http.ResponseWriter, r *http.Request, muted bool) error {
+	microphone := h.capture.Microphone()
+	if !microphone.Mixing() {
+		return utils.HttpUnprocessableEntity("microphone mixing is not enabled")
+	}
+
+	sessionId := chi.URLParam(r, "sessionId")
+
+	err := microphone.SetInputMuted(sessionId, muted)
+	if errors.Is(err, types.ErrStreamSrcInputNotFound) {
+		return utils.HttpNotFound("target session does not share microphone")
+	}
+	if err != nil {
+		return utils.HttpInternalServerError().WithInternalErr(err)
+	}
+
+	payload := message.MicrophoneMuted{
+		ID:    sessionId,
+		Muted: muted,
+	}
+
+	h.sessions.AdminBroadcast(event.MICROPHONE_MUTED, payload)
+
+	// muted participant should know, that nobody can hear them
+	if target, ok := h.sessions.Get(sessionId); ok && !target.Profile().IsAdmin {
+		target.Send(event.MICROPHONE_MUTED, payload)
+	}
+
+	return utils.HttpSuccess(w)
+}
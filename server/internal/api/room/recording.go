@@ -0,0 +1,84 @@
+package room
+
+import (
+	"errors"
+	"net/http"
+	"path/filepath"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/m1k1o/neko/server/pkg/types"
+	"github.com/m1k1o/neko/server/pkg/utils"
+)
+
+type RecordingStatusPayload struct {
+	IsActive bool   `json:"is_active"`
+	File     string `json:"file,omitempty"`
+}
+
+func (h *RoomHandler) recordingStatus(w http.ResponseWriter, r *http.Request) error {
+	recording := h.capture.Recording()
+
+	return utils.HttpSuccess(w, RecordingStatusPayload{
+		IsActive: recording.Started(),
+		File:     recording.File(),
+	})
+}
+
+func (h *RoomHandler) recordingStart(w http.ResponseWriter, r *http.Request) error {
+	recording := h.capture.Recording()
+	if recording.Started() {
+		return utils.HttpUnprocessableEntity("server is already recording")
+	}
+
+	err := recording.Start()
+	if errors.Is(err, types.ErrCapturePipelineAlreadyExists) {
+		return utils.HttpUnprocessableEntity("server is already recording")
+	}
+	if err != nil {
+		return utils.HttpInternalServerError().WithInternalErr(err)
+	}
+
+	return utils.HttpSuccess(w)
+}
+
+func (h *RoomHandler) recordingStop(w http.ResponseWriter, r *http.Request) error {
+	recording := h.capture.Recording()
+	if !recording.Started() {
+		return utils.HttpUnprocessableEntity("server is not recording")
+	}
+
+	recording.Stop()
+
+	return utils.HttpSuccess(w)
+}
+
+func (h *RoomHandler) recordingList(w http.ResponseWriter, r *http.Request) error {
+	recordings, err := h.capture.Recording().List()
+	if err != nil {
+		return utils.HttpInternalServerError().WithInternalErr(err)
+	}
+
+	return utils.HttpSuccess(w, recordings)
+}
+
+func (h *RoomHandler) recordingDownload(w http.ResponseWriter, r *http.Request) error {
+	recording := h.capture.Recording()
+	recordingName := chi.URLParam(r, "recordingName")
+
+	if recordingName == recording.File() {
+		return utils.HttpUnprocessableEntity("recording is in progress")
+	}
+
+	path, err := recording.Path(recordingName)
+	if errors.Is(err, types.ErrRecordingNotFound) {
+		return utils.HttpNotFound("recording was not found")
+	}
+	if err != nil {
+		return utils.HttpInternalServerError().WithInternalErr(err)
+	}
+
+	w.Header().Set("Content-Disposition", "attachment; filename="+filepath.Base(path))
+	http.ServeFile(w, r, path)
+	return nil
+}
@@ -1,7 +1,9 @@
 package room
 
 import (
+	"mime/multipart"
 	"net/http"
+	"net/textproto"
 	"strconv"
 
 	"github.com/m1k1o/neko/server/pkg/auth"
@@ -99,3 +101,73 @@ func (h *RoomHandler) screenCastGet(w http.ResponseWriter, r *http.Request) erro
 	_, err = w.Write(bytes)
 	return err
 }
+
+func (h *RoomHandler) screenCastStream(w http.ResponseWriter, r *http.Request) error {
+	var images <-chan []byte
+
+	session, _ := auth.GetSession(r)
+	privateMode := func() bool {
+		return session != nil && session.PrivateModeEnabled()
+	}
+
+	// stream only fallback image when private mode is enabled
+	if privateMode() {
+		if h.privateModeImage == nil {
+			return utils.HttpBadRequest("private mode is enabled but no fallback image available")
+		}
+
+		fallback := make(chan []byte, 1)
+		fallback <- h.privateModeImage
+		close(fallback)
+		images = fallback
+	} else {
+		screencast := h.capture.Screencast()
+		if !screencast.Enabled() {
+			return utils.HttpBadRequest("screencast pipeline is not enabled")
+		}
+
+		rate, _ := strconv.ParseFloat(r.URL.Query().Get("rate"), 64)
+
+		var err error
+		images, err = screencast.Stream(r.Context(), rate)
+		if err != nil {
+			return utils.HttpInternalServerError().WithInternalErr(err)
+		}
+	}
+
+	mw := multipart.NewWriter(w)
+	rc := http.NewResponseController(w)
+
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	w.Header().Set("Content-Type", "multipart/x-mixed-replace; boundary="+mw.Boundary())
+	w.WriteHeader(http.StatusOK)
+
+	for image := range images {
+		// private mode can be enabled while streaming, fallback image ends the stream
+		private := privateMode()
+		if private {
+			if h.privateModeImage == nil {
+				return nil
+			}
+			image = h.privateModeImage
+		}
+
+		part, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":   {"image/jpeg"},
+			"Content-Length": {strconv.Itoa(len(image))},
+		})
+
+		// client has disconnected, response cannot be changed anymore
+		if err == nil {
+			_, err = part.Write(image)
+		}
+		if err == nil {
+			err = rc.Flush()
+		}
+		if err != nil || private {
+			return nil
+		}
+	}
+
+	return nil
+}
@@ -8,6 +8,8 @@ import (
 	"github.com/m1k1o/neko/server/internal/api/members"
 	"github.com/m1k1o/neko/server/internal/api/room"
 	"github.com/m1k1o/neko/server/internal/api/sessions"
+	"github.com/m1k1o/neko/server/internal/api/whep"
+	"github.com/m1k1o/neko/server/internal/api/whip"
 	"github.com/m1k1o/neko/server/pkg/auth"
 	"github.com/m1k1o/neko/server/pkg/types"
 	"github.com/m1k1o/neko/server/pkg/utils"
@@ -18,6 +20,7 @@ type ApiManagerCtx struct {
 	members  types.MemberManager
 	desktop  types.DesktopManager
 	capture  types.CaptureManager
+	webrtc   types.WebRTCManager
 	routers  map[string]func(types.Router)
 }
 
@@ -26,6 +29,7 @@ func New(
 	members types.MemberManager,
 	desktop types.DesktopManager,
 	capture types.CaptureManager,
+	webrtc types.WebRTCManager,
 ) *ApiManagerCtx {
 
 	return &ApiManagerCtx{
@@ -33,6 +37,7 @@ func New(
 		members:  members,
 		desktop:  desktop,
 		capture:  capture,
+		webrtc:   webrtc,
 		routers:  make(map[string]func(types.Router)),
 	}
 }
@@ -48,6 +53,7 @@ func (api *ApiManagerCtx) Route(r types.Router) {
 		r.Get("/whoami", api.Whoami)
 		r.Post("/profile", api.UpdateProfile)
 		r.Get("/stats", api.Stats)
+		r.Post("/ws-ticket", api.WebSocketTicket)
 
 		sessionsHandler := sessions.New(api.sessions)
 		r.Route("/sessions", sessionsHandler.Route)
@@ -59,6 +65,12 @@ func (api *ApiManagerCtx) Route(r types.Router) {
 		roomHandler := room.New(api.sessions, api.desktop, api.capture)
 		r.Route("/room", roomHandler.Route)
 
+		whepHandler := whep.New(api.sessions, api.webrtc, api.capture)
+		r.Route("/whep", whepHandler.Route)
+
+		whipHandler := whip.New(api.sessions, api.webrtc)
+		r.Route("/whip", whipHandler.Route)
+
 		for path, router := range api.routers {
 			r.Route(path, router)
 		}
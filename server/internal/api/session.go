@@ -3,6 +3,7 @@ package api
 import (
 	"errors"
 	"net/http"
+	"time"
 
 	"github.com/m1k1o/neko/server/pkg/auth"
 	"github.com/m1k1o/neko/server/pkg/types"
@@ -21,6 +22,11 @@ type SessionDataPayload struct {
 	State   types.SessionState  `json:"state"`
 }
 
+type WebSocketTicketPayload struct {
+	Ticket    string    `json:"ticket"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
 func (api *ApiManagerCtx) Login(w http.ResponseWriter, r *http.Request) error {
 	data := &SessionLoginPayload{}
 	if err := utils.HttpJsonRequest(w, r, data); err != nil {
@@ -114,6 +120,24 @@ func (api *ApiManagerCtx) UpdateProfile(w http.ResponseWriter, r *http.Request)
 	return utils.HttpSuccess(w, true)
 }
 
+func (api *ApiManagerCtx) WebSocketTicket(w http.ResponseWriter, r *http.Request) error {
+	session, _ := auth.GetSession(r)
+
+	if !session.Profile().CanConnect {
+		return utils.HttpForbidden("connection disabled")
+	}
+
+	ticket, expiresAt, err := api.sessions.CreateTicket(session)
+	if err != nil {
+		return utils.HttpInternalServerError().WithInternalErr(err)
+	}
+
+	return utils.HttpSuccess(w, WebSocketTicketPayload{
+		Ticket:    ticket,
+		ExpiresAt: expiresAt,
+	})
+}
+
 func (api *ApiManagerCtx) Stats(w http.ResponseWriter, r *http.Request) error {
 	stats := api.sessions.Stats()
 	return utils.HttpSuccess(w, stats)
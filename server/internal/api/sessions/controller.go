@@ -79,3 +79,19 @@ func (h *SessionsHandler) sessionsDisconnect(w http.ResponseWriter, r *http.Requ
 
 	return utils.HttpSuccess(w)
 }
+
+func (h *SessionsHandler) sessionsWebRTCStats(w http.ResponseWriter, r *http.Request) error {
+	sessionId := chi.URLParam(r, "sessionId")
+
+	session, ok := h.sessions.Get(sessionId)
+	if !ok {
+		return utils.HttpNotFound("session not found")
+	}
+
+	peer := session.GetWebRTCPeer()
+	if peer == nil {
+		return utils.HttpUnprocessableEntity("session is not connected to webrtc")
+	}
+
+	return utils.HttpSuccess(w, peer.Stats())
+}
@@ -26,5 +26,6 @@ func (h *SessionsHandler) Route(r types.Router) {
 		r.Get("/", h.sessionsRead)
 		r.Delete("/", h.sessionsDelete)
 		r.Post("/disconnect", h.sessionsDisconnect)
+		r.Get("/webrtc", h.sessionsWebRTCStats)
 	})
 }
@@ -0,0 +1,241 @@
+package whep
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/pion/webrtc/v4"
+
+	"github.com/m1k1o/neko/server/pkg/auth"
+	"github.com/m1k1o/neko/server/pkg/types"
+	"github.com/m1k1o/neko/server/pkg/utils"
+)
+
+// maximum size of sdp offer
+const maxOfferSize = 64 * 1024
+
+type resource struct {
+	session types.Session
+	peer    types.WebRTCPeer
+}
+
+type WHEPHandler struct {
+	webrtc  types.WebRTCManager
+	capture types.CaptureManager
+
+	resourcesMu sync.Mutex
+	resources   map[string]resource
+}
+
+func New(
+	sessions types.SessionManager,
+	webrtc types.WebRTCManager,
+	capture types.CaptureManager,
+) *WHEPHandler {
+	h := &WHEPHandler{
+		webrtc:    webrtc,
+		capture:   capture,
+		resources: make(map[string]resource),
+	}
+
+	// peers are not set to their sessions, so that session changes must be applied here
+	sessions.OnSettingsChanged(func(session types.Session, new, old types.Settings) {
+		if new.PrivateMode == old.PrivateMode {
+			return
+		}
+
+		for _, res := range h.sessionResources(nil) {
+			res.peer.SetPaused(res.session.PrivateModeEnabled())
+		}
+	})
+
+	sessions.OnProfileChanged(func(session types.Session, new, old types.MemberProfile) {
+		for _, res := range h.sessionResources(session) {
+			if !new.CanLogin || !new.CanWatch {
+				res.peer.Destroy()
+				continue
+			}
+
+			res.peer.SetPaused(session.PrivateModeEnabled())
+		}
+	})
+
+	sessions.OnDeleted(func(session types.Session) {
+		for _, res := range h.sessionResources(session) {
+			res.peer.Destroy()
+		}
+	})
+
+	return h
+}
+
+// Route registers WHEP endpoint, trickle ICE using PATCH is not supported.
+func (h *WHEPHandler) Route(r types.Router) {
+	r.With(auth.CanWatchOnly).Post("/", h.whepOffer)
+	r.Delete("/{resourceId}", h.whepDelete)
+}
+
+func (h *WHEPHandler) whepOffer(w http.ResponseWriter, r *http.Request) error {
+	session, _ := auth.GetSession(r)
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/sdp" {
+		return utils.HttpError(http.StatusUnsupportedMediaType, "content type must be application/sdp")
+	}
+
+	sdp, err := io.ReadAll(io.LimitReader(r.Body, maxOfferSize))
+	if err != nil {
+		return utils.HttpBadRequest("unable to read offer").WithInternalErr(err)
+	}
+
+	// use requested or default first video
+	videoID := r.URL.Query().Get("video")
+	if videoID == "" {
+		videoID = h.capture.Video().IDs()[0]
+	}
+
+	// enable audio by default if not requested otherwise
+	audioDisabled := r.URL.Query().Get("audio") == "false"
+
+	answer, peer, err := h.webrtc.CreatePeer(session, &webrtc.SessionDescription{
+		Type: webrtc.SDPTypeOffer,
+		SDP:  string(sdp),
+	}, nil)
+	if err != nil {
+		return utils.HttpBadRequest("unable to create peer from offer").WithInternalErr(err)
+	}
+
+	// set webrtc as paused if session has private mode enabled
+	if session.PrivateModeEnabled() {
+		peer.SetPaused(true)
+	}
+
+	videoAuto := false
+	err = peer.SetVideo(types.PeerVideoRequest{
+		Selector: &types.StreamSelector{
+			ID:   videoID,
+			Type: types.StreamSelectorTypeExact,
+		},
+		Auto: &videoAuto,
+	})
+	if err != nil {
+		peer.Destroy()
+		return utils.HttpBadRequest("unable to set video").WithInternalErr(err)
+	}
+
+	err = peer.SetAudio(types.PeerAudioRequest{
+		Disabled: &audioDisabled,
+	})
+	if err != nil {
+		peer.Destroy()
+		return utils.HttpInternalServerError().WithInternalErr(err)
+	}
+
+	resourceID, err := h.addResource(session, peer)
+	if err != nil {
+		peer.Destroy()
+		return utils.HttpInternalServerError().WithInternalErr(err)
+	}
+
+	// request uri is used, because path prefix might have been stripped from url
+	location, err := url.Parse(r.RequestURI)
+	if err != nil {
+		location = r.URL
+	}
+
+	for _, server := range h.webrtc.ICEServers(session) {
+		if len(server.URLs) > 0 {
+			w.Header().Add("Link", iceServerLink(server))
+		}
+	}
+
+	w.Header().Set("Location", strings.TrimSuffix(location.Path, "/")+"/"+resourceID)
+	w.Header().Set("Content-Type", "application/sdp")
+	w.WriteHeader(http.StatusCreated)
+
+	_, err = w.Write([]byte(answer.SDP))
+	return err
+}
+
+func (h *WHEPHandler) whepDelete(w http.ResponseWriter, r *http.Request) error {
+	session, _ := auth.GetSession(r)
+	resourceID := chi.URLParam(r, "resourceId")
+
+	h.resourcesMu.Lock()
+	res, ok := h.resources[resourceID]
+	if ok && (res.session == session || session.Profile().IsAdmin) {
+		delete(h.resources, resourceID)
+	}
+	h.resourcesMu.Unlock()
+
+	if !ok {
+		return utils.HttpNotFound("resource not found")
+	}
+
+	if res.session != session && !session.Profile().IsAdmin {
+		return utils.HttpForbidden("resource belongs to another session")
+	}
+
+	res.peer.Destroy()
+	return utils.HttpSuccess(w)
+}
+
+func (h *WHEPHandler) addResource(session types.Session, peer types.WebRTCPeer) (string, error) {
+	resourceID, err := utils.NewUID(32)
+	if err != nil {
+		return "", err
+	}
+
+	h.resourcesMu.Lock()
+	defer h.resourcesMu.Unlock()
+
+	// remove resources whose peers were closed meanwhile
+	for id, res := range h.resources {
+		select {
+		case <-res.peer.Done():
+			delete(h.resources, id)
+		default:
+		}
+	}
+
+	h.resources[resourceID] = resource{
+		session: session,
+		peer:    peer,
+	}
+
+	return resourceID, nil
+}
+
+// sessionResources returns resources of the session, or all resources if session is nil.
+func (h *WHEPHandler) sessionResources(session types.Session) []resource {
+	h.resourcesMu.Lock()
+	defer h.resourcesMu.Unlock()
+
+	resources := []resource{}
+	for _, res := range h.resources {
+		if session == nil || res.session == session {
+			resources = append(resources, res)
+		}
+	}
+
+	return resources
+}
+
+// ice servers are advertised using link headers as defined in WHEP
+func iceServerLink(server types.ICEServer) string {
+	links := make([]string, 0, len(server.URLs))
+	for _, u := range server.URLs {
+		link := fmt.Sprintf(`<%s>; rel="ice-server"`, u)
+		if server.Username != "" {
+			link += fmt.Sprintf(`; username=%q; credential=%q; credential-type="password"`, server.Username, server.Credential)
+		}
+		links = append(links, link)
+	}
+	return strings.Join(links, ", ")
+}
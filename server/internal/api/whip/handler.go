@@ -0,0 +1,171 @@
+package whip
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/pion/webrtc/v4"
+
+	"github.com/m1k1o/neko/server/pkg/auth"
+	"github.com/m1k1o/neko/server/pkg/types"
+	"github.com/m1k1o/neko/server/pkg/utils"
+)
+
+// maximum size of sdp offer
+const maxOfferSize = 64 * 1024
+
+type resource struct {
+	session types.Session
+	peer    types.WebRTCIngestPeer
+}
+
+type WHIPHandler struct {
+	webrtc types.WebRTCManager
+
+	resourcesMu sync.Mutex
+	resources   map[string]resource
+}
+
+func New(
+	sessions types.SessionManager,
+	webrtc types.WebRTCManager,
+) *WHIPHandler {
+	h := &WHIPHandler{
+		webrtc:    webrtc,
+		resources: make(map[string]resource),
+	}
+
+	// peers are not set to their sessions, so that session changes must be applied here
+	sessions.OnProfileChanged(func(session types.Session, new, old types.MemberProfile) {
+		if new.CanLogin && new.CanShareMedia {
+			return
+		}
+
+		for _, res := range h.sessionResources(session) {
+			res.peer.Destroy()
+		}
+	})
+
+	sessions.OnDeleted(func(session types.Session) {
+		for _, res := range h.sessionResources(session) {
+			res.peer.Destroy()
+		}
+	})
+
+	return h
+}
+
+// Route registers WHIP endpoint, trickle ICE using PATCH is not supported.
+func (h *WHIPHandler) Route(r types.Router) {
+	r.With(auth.CanShareMediaOnly).Post("/", h.whipOffer)
+	r.Delete("/{resourceId}", h.whipDelete)
+}
+
+func (h *WHIPHandler) whipOffer(w http.ResponseWriter, r *http.Request) error {
+	session, _ := auth.GetSession(r)
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/sdp" {
+		return utils.HttpError(http.StatusUnsupportedMediaType, "content type must be application/sdp")
+	}
+
+	sdp, err := io.ReadAll(io.LimitReader(r.Body, maxOfferSize))
+	if err != nil {
+		return utils.HttpBadRequest("unable to read offer").WithInternalErr(err)
+	}
+
+	answer, peer, err := h.webrtc.CreateIngestPeer(session, webrtc.SessionDescription{
+		Type: webrtc.SDPTypeOffer,
+		SDP:  string(sdp),
+	})
+	if err != nil {
+		return utils.HttpBadRequest("unable to create peer from offer").WithInternalErr(err)
+	}
+
+	resourceID, err := h.addResource(session, peer)
+	if err != nil {
+		peer.Destroy()
+		return utils.HttpInternalServerError().WithInternalErr(err)
+	}
+
+	// request uri is used, because path prefix might have been stripped from url
+	location, err := url.Parse(r.RequestURI)
+	if err != nil {
+		location = r.URL
+	}
+
+	w.Header().Set("Location", strings.TrimSuffix(location.Path, "/")+"/"+resourceID)
+	w.Header().Set("Content-Type", "application/sdp")
+	w.WriteHeader(http.StatusCreated)
+
+	_, err = w.Write([]byte(answer.SDP))
+	return err
+}
+
+func (h *WHIPHandler) whipDelete(w http.ResponseWriter, r *http.Request) error {
+	session, _ := auth.GetSession(r)
+	resourceID := chi.URLParam(r, "resourceId")
+
+	h.resourcesMu.Lock()
+	res, ok := h.resources[resourceID]
+	if ok && (res.session == session || session.Profile().IsAdmin) {
+		delete(h.resources, resourceID)
+	}
+	h.resourcesMu.Unlock()
+
+	if !ok {
+		return utils.HttpNotFound("resource not found")
+	}
+
+	if res.session != session && !session.Profile().IsAdmin {
+		return utils.HttpForbidden("resource belongs to another session")
+	}
+
+	res.peer.Destroy()
+	return utils.HttpSuccess(w)
+}
+
+func (h *WHIPHandler) addResource(session types.Session, peer types.WebRTCIngestPeer) (string, error) {
+	resourceID, err := utils.NewUID(32)
+	if err != nil {
+		return "", err
+	}
+
+	h.resourcesMu.Lock()
+	defer h.resourcesMu.Unlock()
+
+	// remove resources whose peers were closed meanwhile
+	for id, res := range h.resources {
+		select {
+		case <-res.peer.Done():
+			delete(h.resources, id)
+		default:
+		}
+	}
+
+	h.resources[resourceID] = resource{
+		session: session,
+		peer:    peer,
+	}
+
+	return resourceID, nil
+}
+
+func (h *WHIPHandler) sessionResources(session types.Session) []resource {
+	h.resourcesMu.Lock()
+	defer h.resourcesMu.Unlock()
+
+	resources := []resource{}
+	for _, res := range h.resources {
+		if res.session == session {
+			resources = append(resources, res)
+		}
+	}
+
+	return resources
+}
@@ -1,7 +1,10 @@
 package capture
 
 import (
+	"slices"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
@@ -12,33 +15,98 @@ import (
 	"github.com/m1k1o/neko/server/pkg/types"
 )
 
+// names of appsinks in shared encoder and appsrcs in destination pipelines
+const (
+	broadcastVideoName = "video"
+	broadcastAudioName = "audio"
+)
+
+const (
+	// reconnect delay, doubled after every failed attempt
+	broadcastRetryMin = time.Second
+	broadcastRetryMax = time.Minute
+
+	// destination live for this long is reconnected again with minimal delay
+	broadcastStableAfter = 30 * time.Second
+)
+
+var (
+	broadcastBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name:      "broadcast_bytes_total",
+		Namespace: "neko",
+		Subsystem: "capture",
+		Help:      "Total number of encoded bytes sent to broadcast destination.",
+	}, []string{"destination_id", "codec_type"})
+
+	broadcastDroppedFrames = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name:      "broadcast_dropped_frames_total",
+		Namespace: "neko",
+		Subsystem: "capture",
+		Help:      "Total number of encoded video frames dropped while broadcast destination was not connected.",
+	}, []string{"destination_id"})
+
+	broadcastReconnects = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name:      "broadcast_reconnects_total",
+		Namespace: "neko",
+		Subsystem: "capture",
+		Help:      "Total number of reconnect attempts of broadcast destination.",
+	}, []string{"destination_id"})
+)
+
+type broadcastDestination struct {
+	url      string
+	pipeline gst.Pipeline
+	state    string
+	err      error
+
+	// reconnecting
+	retries   int
+	retry     *time.Timer
+	liveSince time.Time
+}
+
+// BroacastManagerCtx sends the screen to multiple destinations. When encoder pipeline is not empty,
+// the screen is encoded only once and encoded samples are teed to pipelines of all destinations.
 type BroacastManagerCtx struct {
 	logger zerolog.Logger
 	mu     sync.Mutex
 
-	pipeline   gst.Pipeline
-	pipelineMu sync.Mutex
+	encoder    gst.Pipeline
+	encoderFn  func() (string, error)
 	pipelineFn func(url string) (string, error)
 
-	url     string
-	started bool
+	// started destinations, their pipelines exist only while screen is captured
+	destinations map[string]*broadcastDestination
+
+	// pipelines receiving encoded samples, nil while destination is reconnecting,
+	// separate lock lets tee drain samples while encoder is destroyed
+	sinks   map[string]gst.Pipeline
+	sinksMu sync.RWMutex
+
+	statusListener func(destination types.BroadcastDestination)
 
 	// metrics
 	pipelinesCounter prometheus.Counter
 	pipelinesActive  prometheus.Gauge
 }
 
-func broadcastNew(pipelineFn func(url string) (string, error), defaultUrl string, autostart bool) *BroacastManagerCtx {
+func broadcastNew(encoderFn func() (string, error), pipelineFn func(url string) (string, error), defaultUrl string, autostart bool) *BroacastManagerCtx {
 	logger := log.With().
 		Str("module", "capture").
 		Str("submodule", "broadcast").
 		Logger()
 
+	destinations := map[string]*broadcastDestination{}
+	if defaultUrl != "" && autostart {
+		destinations[types.BroadcastDefaultID] = &broadcastDestination{url: defaultUrl}
+	}
+
 	return &BroacastManagerCtx{
-		logger:     logger,
-		pipelineFn: pipelineFn,
-		url:        defaultUrl,
-		started:    defaultUrl != "" && autostart,
+		logger:       logger,
+		encoderFn:    encoderFn,
+		pipelineFn:   pipelineFn,
+		destinations: destinations,
+		sinks:        map[string]gst.Pipeline{},
 
 		// metrics
 		pipelinesCounter: promauto.NewCounter(prometheus.CounterOpts{
@@ -71,87 +139,406 @@ func broadcastNew(pipelineFn func(url string) (string, error), defaultUrl string
 func (manager *BroacastManagerCtx) shutdown() {
 	manager.logger.Info().Msgf("shutdown")
 
-	manager.destroyPipeline()
+	manager.destroyPipelines()
 }
 
 func (manager *BroacastManagerCtx) Start(url string) error {
+	return manager.StartDestination(types.BroadcastDefaultID, url)
+}
+
+func (manager *BroacastManagerCtx) Stop() {
+	_ = manager.StopDestination(types.BroadcastDefaultID)
+}
+
+func (manager *BroacastManagerCtx) Started() bool {
 	manager.mu.Lock()
 	defer manager.mu.Unlock()
 
-	manager.url = url
+	_, ok := manager.destinations[types.BroadcastDefaultID]
+	return ok
+}
 
-	err := manager.createPipeline()
-	if err != nil {
+func (manager *BroacastManagerCtx) Url() string {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	destination, ok := manager.destinations[types.BroadcastDefaultID]
+	if !ok {
+		return ""
+	}
+
+	return destination.url
+}
+
+func (manager *BroacastManagerCtx) StartDestination(id string, url string) error {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	if _, ok := manager.destinations[id]; ok {
+		return types.ErrCapturePipelineAlreadyExists
+	}
+
+	destination := &broadcastDestination{url: url}
+	if err := manager.createPipeline(id, destination); err != nil {
+		if len(manager.destinations) == 0 {
+			manager.destroyEncoder()
+		}
 		return err
 	}
 
-	manager.started = true
+	manager.destinations[id] = destination
+	manager.statusChanged(id, destination)
 	return nil
 }
 
-func (manager *BroacastManagerCtx) Stop() {
+func (manager *BroacastManagerCtx) StopDestination(id string) error {
 	manager.mu.Lock()
 	defer manager.mu.Unlock()
 
-	manager.started = false
-	manager.destroyPipeline()
+	destination, ok := manager.destinations[id]
+	if !ok {
+		return types.ErrBroadcastNotFound
+	}
+
+	manager.cancelRetry(destination)
+	manager.destroyPipeline(id, destination)
+	delete(manager.destinations, id)
+
+	manager.sinksMu.Lock()
+	delete(manager.sinks, id)
+	manager.sinksMu.Unlock()
+
+	broadcastBytes.DeletePartialMatch(prometheus.Labels{"destination_id": id})
+	broadcastDroppedFrames.DeleteLabelValues(id)
+	broadcastReconnects.DeleteLabelValues(id)
+
+	// encoder is not needed without destinations
+	if len(manager.destinations) == 0 {
+		manager.destroyEncoder()
+	}
+
+	if manager.statusListener != nil {
+		manager.statusListener(types.BroadcastDestination{
+			ID:       id,
+			URL:      destination.url,
+			IsActive: false,
+		})
+	}
+
+	return nil
 }
 
-func (manager *BroacastManagerCtx) Started() bool {
+func (manager *BroacastManagerCtx) Destinations() []types.BroadcastDestination {
 	manager.mu.Lock()
 	defer manager.mu.Unlock()
 
-	return manager.started
+	destinations := make([]types.BroadcastDestination, 0, len(manager.destinations))
+	for id, destination := range manager.destinations {
+		destinations = append(destinations, destination.status(id))
+	}
+
+	slices.SortFunc(destinations, func(a, b types.BroadcastDestination) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+
+	return destinations
 }
 
-func (manager *BroacastManagerCtx) Url() string {
+func (manager *BroacastManagerCtx) OnStatusChange(listener func(destination types.BroadcastDestination)) {
 	manager.mu.Lock()
 	defer manager.mu.Unlock()
 
-	return manager.url
+	manager.statusListener = listener
 }
 
-func (manager *BroacastManagerCtx) createPipeline() error {
-	manager.pipelineMu.Lock()
-	defer manager.pipelineMu.Unlock()
+func (destination *broadcastDestination) status(id string) types.BroadcastDestination {
+	status := types.BroadcastDestination{
+		ID:       id,
+		URL:      destination.url,
+		IsActive: true,
+		State:    destination.state,
+	}
+	if destination.err != nil {
+		status.Error = destination.err.Error()
+	}
+	return status
+}
 
-	if manager.pipeline != nil {
-		return types.ErrCapturePipelineAlreadyExists
+func (manager *BroacastManagerCtx) statusChanged(id string, destination *broadcastDestination) {
+	if manager.statusListener != nil {
+		manager.statusListener(destination.status(id))
 	}
+}
+
+// createPipelines creates pipelines of all started destinations, e.g. after screen size change.
+func (manager *BroacastManagerCtx) createPipelines() error {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	var lastErr error
+	for id, destination := range manager.destinations {
+		if destination.pipeline != nil || destination.retry != nil {
+			continue
+		}
+
+		if err := manager.createPipeline(id, destination); err != nil {
+			manager.scheduleRetry(id, destination, err)
+			lastErr = err
+			continue
+		}
+
+		manager.statusChanged(id, destination)
+	}
+
+	return lastErr
+}
+
+// destroyPipelines destroys pipelines of all destinations, but keeps them started.
+func (manager *BroacastManagerCtx) destroyPipelines() {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	for id, destination := range manager.destinations {
+		manager.cancelRetry(destination)
+		manager.destroyPipeline(id, destination)
+	}
+
+	manager.destroyEncoder()
+}
+
+// scheduleRetry reports failed destination and reconnects it after a delay growing with every failed attempt.
+func (manager *BroacastManagerCtx) scheduleRetry(id string, destination *broadcastDestination, err error) {
+	if destination.state == types.BroadcastStateLive && time.Since(destination.liveSince) >= broadcastStableAfter {
+		destination.retries = 0
+	}
+
+	delay := min(broadcastRetryMin<<min(destination.retries, 6), broadcastRetryMax)
+
+	manager.logger.Warn().
+		Err(err).
+		Str("id", id).
+		Str("url", destination.url).
+		Dur("delay", delay).
+		Msg("broadcast destination failed, reconnecting")
+
+	destination.retries++
+	destination.state = types.BroadcastStateError
+	destination.err = err
+
+	var timer *time.Timer
+	timer = time.AfterFunc(delay, func() {
+		manager.reconnect(id, destination, timer)
+	})
+	destination.retry = timer
 
-	pipelineStr, err := manager.pipelineFn(manager.url)
+	manager.statusChanged(id, destination)
+}
+
+func (manager *BroacastManagerCtx) cancelRetry(destination *broadcastDestination) {
+	if destination.retry == nil {
+		return
+	}
+
+	destination.retry.Stop()
+	destination.retry = nil
+}
+
+func (manager *BroacastManagerCtx) reconnect(id string, destination *broadcastDestination, timer *time.Timer) {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	// destination was stopped or its pipelines destroyed in the meantime
+	if manager.destinations[id] != destination || destination.retry != timer {
+		return
+	}
+
+	destination.retry = nil
+	broadcastReconnects.WithLabelValues(id).Inc()
+
+	if err := manager.createPipeline(id, destination); err != nil {
+		manager.scheduleRetry(id, destination, err)
+		return
+	}
+
+	manager.statusChanged(id, destination)
+}
+
+// pipelineError destroys failed destination pipeline and schedules its reconnect.
+func (manager *BroacastManagerCtx) pipelineError(id string, pipeline gst.Pipeline, err error) {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	destination, ok := manager.destinations[id]
+	if !ok || destination.pipeline != pipeline {
+		return
+	}
+
+	manager.destroyPipeline(id, destination)
+	manager.scheduleRetry(id, destination, err)
+}
+
+func (manager *BroacastManagerCtx) pipelinePlaying(id string, pipeline gst.Pipeline) {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	destination, ok := manager.destinations[id]
+	if !ok || destination.pipeline != pipeline || destination.state == types.BroadcastStateLive {
+		return
+	}
+
+	manager.logger.Info().Str("id", id).Msg("broadcast destination is live")
+
+	destination.state = types.BroadcastStateLive
+	destination.liveSince = time.Now()
+	destination.err = nil
+
+	manager.statusChanged(id, destination)
+}
+
+// encoderError fails all destinations, encoder is created again by the first reconnect.
+func (manager *BroacastManagerCtx) encoderError(encoder gst.Pipeline, err error) {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	if manager.encoder != encoder {
+		return
+	}
+
+	for id, destination := range manager.destinations {
+		if destination.retry != nil {
+			continue
+		}
+
+		manager.destroyPipeline(id, destination)
+		manager.scheduleRetry(id, destination, err)
+	}
+
+	manager.destroyEncoder()
+}
+
+func (manager *BroacastManagerCtx) createPipeline(id string, destination *broadcastDestination) error {
+	if manager.encoder == nil {
+		if err := manager.createEncoder(); err != nil {
+			return err
+		}
+	}
+
+	pipelineStr, err := manager.pipelineFn(destination.url)
 	if err != nil {
 		return err
 	}
 
 	manager.logger.Info().
-		Str("url", manager.url).
+		Str("id", id).
+		Str("url", destination.url).
 		Str("src", pipelineStr).
 		Msgf("starting pipeline")
 
-	manager.pipeline, err = gst.CreatePipeline(pipelineStr)
+	pipeline, err := gst.CreatePipeline(pipelineStr)
 	if err != nil {
 		return err
 	}
 
-	manager.pipeline.Play()
+	pipeline.OnPlaying(func() {
+		manager.pipelinePlaying(id, pipeline)
+	})
+	pipeline.OnError(func(err error) {
+		manager.pipelineError(id, pipeline, err)
+	})
+
+	pipeline.Play()
+	destination.pipeline = pipeline
+
+	if destination.retries > 0 {
+		destination.state = types.BroadcastStateRetrying
+	} else {
+		destination.state = types.BroadcastStateConnecting
+	}
+
+	manager.sinksMu.Lock()
+	manager.sinks[id] = pipeline
+	manager.sinksMu.Unlock()
+
 	manager.pipelinesCounter.Inc()
-	manager.pipelinesActive.Set(1)
+	manager.pipelinesActive.Inc()
 
 	return nil
 }
 
-func (manager *BroacastManagerCtx) destroyPipeline() {
-	manager.pipelineMu.Lock()
-	defer manager.pipelineMu.Unlock()
+func (manager *BroacastManagerCtx) destroyPipeline(id string, destination *broadcastDestination) {
+	if destination.pipeline == nil {
+		return
+	}
+
+	// keep destination in sinks, so that dropped frames are counted
+	manager.sinksMu.Lock()
+	manager.sinks[id] = nil
+	manager.sinksMu.Unlock()
+
+	destination.pipeline.Destroy()
+	manager.logger.Info().Str("id", id).Msgf("destroying pipeline")
+	destination.pipeline = nil
 
-	if manager.pipeline == nil {
+	manager.pipelinesActive.Dec()
+}
+
+func (manager *BroacastManagerCtx) createEncoder() error {
+	encoderStr, err := manager.encoderFn()
+	if err != nil || encoderStr == "" {
+		return err
+	}
+
+	manager.logger.Info().
+		Str("src", encoderStr).
+		Msgf("starting encoder pipeline")
+
+	encoder, err := gst.CreatePipeline(encoderStr)
+	if err != nil {
+		return err
+	}
+
+	video := encoder.AttachAppsinkChannel("appsink_" + broadcastVideoName)
+	audio := encoder.AttachAppsinkChannel("appsink_" + broadcastAudioName)
+
+	go manager.tee(video, broadcastVideoName)
+	go manager.tee(audio, broadcastAudioName)
+
+	encoder.OnError(func(err error) {
+		manager.encoderError(encoder, err)
+	})
+
+	encoder.Play()
+	manager.encoder = encoder
+	return nil
+}
+
+func (manager *BroacastManagerCtx) destroyEncoder() {
+	if manager.encoder == nil {
 		return
 	}
 
-	manager.pipeline.Destroy()
-	manager.logger.Info().Msgf("destroying pipeline")
-	manager.pipeline = nil
+	manager.encoder.Destroy()
+	manager.logger.Info().Msgf("destroying encoder pipeline")
+	manager.encoder = nil
+}
 
-	manager.pipelinesActive.Set(0)
+// tee pushes encoded samples to all destinations, until the encoder is destroyed.
+func (manager *BroacastManagerCtx) tee(samples chan types.Sample, name string) {
+	srcName := "appsrc_" + name
+
+	for sample := range samples {
+		manager.sinksMu.RLock()
+		for id, pipeline := range manager.sinks {
+			if pipeline == nil {
+				if name == broadcastVideoName {
+					broadcastDroppedFrames.WithLabelValues(id).Inc()
+				}
+				continue
+			}
+
+			pipeline.PushTo(srcName, sample.Data)
+			broadcastBytes.WithLabelValues(id, name).Add(float64(sample.Length))
+		}
+		manager.sinksMu.RUnlock()
+	}
 }
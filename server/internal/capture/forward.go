@@ -0,0 +1,433 @@
+package capture
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pion/rtp"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+
+	"github.com/m1k1o/neko/server/pkg/gst"
+	"github.com/m1k1o/neko/server/pkg/types"
+	"github.com/m1k1o/neko/server/pkg/types/codec"
+	"github.com/m1k1o/neko/server/pkg/utils"
+)
+
+const forwardMTU = 1200
+
+// host name labels as in RFC 1123, separated by dots
+var forwardHostnameRegex = regexp.MustCompile(`^([a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?\.)*[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?$`)
+
+// forwardValidHost returns whether host is an ip address or a host name, that
+// can be safely used in pipeline description and session description.
+func forwardValidHost(host string) bool {
+	if net.ParseIP(host) != nil {
+		return true
+	}
+	return len(host) <= 253 && forwardHostnameRegex.MatchString(host)
+}
+
+type forwardCtx struct {
+	forward types.Forward
+	sdp     string
+
+	video       types.StreamSinkManager
+	audio       types.StreamSinkManager
+	videoTrack  types.SampleListener
+	audioTrack  types.SampleListener
+	destroyFunc func()
+}
+
+// ForwardManagerCtx forwards already encoded samples of video and audio streams
+// to external receivers, without creating any new encoder.
+type ForwardManagerCtx struct {
+	logger zerolog.Logger
+	mu     sync.Mutex
+
+	audio  types.StreamSinkManager
+	videos []types.StreamSelectorManager
+
+	forwards map[string]*forwardCtx
+}
+
+func forwardNew(audio types.StreamSinkManager, videos []types.StreamSelectorManager) *ForwardManagerCtx {
+	logger := log.With().
+		Str("module", "capture").
+		Str("submodule", "forward").
+		Logger()
+
+	return &ForwardManagerCtx{
+		logger:   logger,
+		audio:    audio,
+		videos:   videos,
+		forwards: map[string]*forwardCtx{},
+	}
+}
+
+func (manager *ForwardManagerCtx) shutdown() {
+	manager.logger.Info().Msgf("shutdown")
+
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	for id, fwd := range manager.forwards {
+		manager.destroy(fwd)
+		delete(manager.forwards, id)
+	}
+}
+
+func (manager *ForwardManagerCtx) List() []types.Forward {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	forwards := make([]types.Forward, 0, len(manager.forwards))
+	for _, fwd := range manager.forwards {
+		forwards = append(forwards, fwd.forward)
+	}
+
+	return forwards
+}
+
+func (manager *ForwardManagerCtx) Get(id string) (types.Forward, bool) {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	fwd, ok := manager.forwards[id]
+	if !ok {
+		return types.Forward{}, false
+	}
+
+	return fwd.forward, true
+}
+
+func (manager *ForwardManagerCtx) SDP(id string) (string, error) {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	fwd, ok := manager.forwards[id]
+	if !ok {
+		return "", types.ErrForwardNotFound
+	}
+
+	if fwd.forward.Protocol != types.ForwardProtocolRTP {
+		return "", fmt.Errorf("session description is available only for %s forwards", types.ForwardProtocolRTP)
+	}
+
+	return fwd.sdp, nil
+}
+
+func (manager *ForwardManagerCtx) Start(forward types.Forward) (types.Forward, error) {
+	video, err := manager.videoStream(forward.VideoCodec, forward.VideoID)
+	if err != nil {
+		return forward, err
+	}
+
+	forward.VideoID = video.ID()
+	forward.VideoCodec = video.Codec().Name
+
+	host, portStr, err := net.SplitHostPort(forward.Address)
+	if err != nil {
+		return forward, err
+	}
+
+	if !forwardValidHost(host) {
+		return forward, fmt.Errorf("invalid host %q", host)
+	}
+
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil || port == 0 {
+		return forward, fmt.Errorf("invalid port %q", portStr)
+	}
+
+	forward.ID, err = utils.NewUID(8)
+	if err != nil {
+		return forward, err
+	}
+
+	fwd := &forwardCtx{
+		forward: forward,
+		video:   video,
+		audio:   manager.audio,
+	}
+
+	logger := manager.logger.With().
+		Str("id", forward.ID).
+		Str("protocol", forward.Protocol).
+		Str("address", forward.Address).
+		Logger()
+
+	switch forward.Protocol {
+	case types.ForwardProtocolRTP:
+		err = manager.createRTP(logger, fwd, host, int(port))
+	case types.ForwardProtocolSRT:
+		err = manager.createSRT(logger, fwd, host, int(port))
+	default:
+		err = fmt.Errorf("unknown protocol %q", forward.Protocol)
+	}
+	if err != nil {
+		return forward, err
+	}
+
+	// listeners are added last, so that samples are written only to ready tracks
+	if err := fwd.video.AddListener(fwd.videoTrack); err != nil {
+		fwd.destroyFunc()
+		return forward, err
+	}
+
+	if err := fwd.audio.AddListener(fwd.audioTrack); err != nil {
+		_ = fwd.video.RemoveListener(fwd.videoTrack)
+		fwd.destroyFunc()
+		return forward, err
+	}
+
+	// receiver can decode video only from a keyframe, do not let it wait for the next one
+	if stream, ok := fwd.video.(*StreamSinkManagerCtx); ok {
+		stream.emitVideoKeyframe()
+	}
+
+	manager.mu.Lock()
+	manager.forwards[forward.ID] = fwd
+	manager.mu.Unlock()
+
+	logger.Info().Msg("forward started")
+	return forward, nil
+}
+
+func (manager *ForwardManagerCtx) Stop(id string) error {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	fwd, ok := manager.forwards[id]
+	if !ok {
+		return types.ErrForwardNotFound
+	}
+
+	manager.destroy(fwd)
+	delete(manager.forwards, id)
+
+	manager.logger.Info().Str("id", id).Msg("forward stopped")
+	return nil
+}
+
+func (manager *ForwardManagerCtx) destroy(fwd *forwardCtx) {
+	if err := fwd.video.RemoveListener(fwd.videoTrack); err != nil {
+		manager.logger.Warn().Err(err).Str("id", fwd.forward.ID).Msg("unable to remove video listener")
+	}
+
+	if err := fwd.audio.RemoveListener(fwd.audioTrack); err != nil {
+		manager.logger.Warn().Err(err).Str("id", fwd.forward.ID).Msg("unable to remove audio listener")
+	}
+
+	fwd.destroyFunc()
+}
+
+func (manager *ForwardManagerCtx) videoStream(codecName, id string) (types.StreamSinkManager, error) {
+	var video types.StreamSelectorManager
+	if codecName == "" {
+		video = manager.videos[0]
+	} else {
+		for _, v := range manager.videos {
+			if v.Codec().Name == codecName {
+				video = v
+				break
+			}
+		}
+	}
+
+	if video == nil {
+		return nil, fmt.Errorf("video codec %q is not available", codecName)
+	}
+
+	if id == "" {
+		id = video.IDs()[0]
+	}
+
+	stream, ok := video.GetStream(types.StreamSelector{
+		ID:   id,
+		Type: types.StreamSelectorTypeExact,
+	})
+	if !ok {
+		return nil, types.ErrWebRTCStreamNotFound
+	}
+
+	return stream, nil
+}
+
+//
+// rtp
+//
+
+type forwardRTPTrack struct {
+	logger     zerolog.Logger
+	conn       net.Conn
+	packetizer rtp.Packetizer
+	clockRate  uint32
+}
+
+func newForwardRTPTrack(logger zerolog.Logger, c codec.RTPCodec, address string) (*forwardRTPTrack, error) {
+	payloader, err := c.Payloader()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	return &forwardRTPTrack{
+		logger: logger.With().Str("codec", c.Name).Logger(),
+		conn:   conn,
+		packetizer: rtp.NewPacketizer(
+			forwardMTU,
+			uint8(c.PayloadType),
+			rand.Uint32(),
+			payloader,
+			rtp.NewRandomSequencer(),
+			c.Capability.ClockRate,
+		),
+		clockRate: c.Capability.ClockRate,
+	}, nil
+}
+
+func (track *forwardRTPTrack) WriteSample(sample types.Sample) {
+	samples := uint32(sample.Duration.Seconds() * float64(track.clockRate))
+
+	for _, packet := range track.packetizer.Packetize(sample.Data, samples) {
+		buf, err := packet.Marshal()
+		if err != nil {
+			track.logger.Warn().Err(err).Msg("unable to marshal rtp packet")
+			continue
+		}
+
+		// receiver might not be listening yet, nothing to do about it
+		if _, err := track.conn.Write(buf); err != nil {
+			track.logger.Trace().Err(err).Msg("unable to write rtp packet")
+		}
+	}
+}
+
+func (manager *ForwardManagerCtx) createRTP(logger zerolog.Logger, fwd *forwardCtx, host string, port int) error {
+	videoCodec, audioCodec := fwd.video.Codec(), fwd.audio.Codec()
+
+	// audio is sent to the next even port, rtcp would use the odd one
+	videoAddress := net.JoinHostPort(host, strconv.Itoa(port))
+	audioAddress := net.JoinHostPort(host, strconv.Itoa(port+2))
+
+	videoTrack, err := newForwardRTPTrack(logger, videoCodec, videoAddress)
+	if err != nil {
+		return err
+	}
+
+	audioTrack, err := newForwardRTPTrack(logger, audioCodec, audioAddress)
+	if err != nil {
+		videoTrack.conn.Close()
+		return err
+	}
+
+	fwd.videoTrack = videoTrack
+	fwd.audioTrack = audioTrack
+	fwd.sdp = forwardSDP(host, port, videoCodec, port+2, audioCodec)
+	fwd.destroyFunc = func() {
+		videoTrack.conn.Close()
+		audioTrack.conn.Close()
+	}
+
+	return nil
+}
+
+// forwardSDP describes rtp streams, so that receivers such as ffmpeg or gstreamer can play them.
+func forwardSDP(host string, videoPort int, videoCodec codec.RTPCodec, audioPort int, audioCodec codec.RTPCodec) string {
+	addrType := "IP4"
+	if ip := net.ParseIP(host); ip != nil && ip.To4() == nil {
+		addrType = "IP6"
+	}
+
+	media := func(kind string, port int, c codec.RTPCodec) string {
+		rtpmap := fmt.Sprintf("%s/%d", strings.ToUpper(c.Name), c.Capability.ClockRate)
+		if c.Capability.Channels > 0 {
+			rtpmap += fmt.Sprintf("/%d", c.Capability.Channels)
+		}
+
+		lines := fmt.Sprintf("m=%s %d RTP/AVP %d\r\n", kind, port, c.PayloadType)
+		lines += fmt.Sprintf("a=rtpmap:%d %s\r\n", c.PayloadType, rtpmap)
+		if c.Capability.SDPFmtpLine != "" {
+			lines += fmt.Sprintf("a=fmtp:%d %s\r\n", c.PayloadType, c.Capability.SDPFmtpLine)
+		}
+		return lines
+	}
+
+	return "v=0\r\n" +
+		fmt.Sprintf("o=- 0 0 IN %s %s\r\n", addrType, host) +
+		"s=neko\r\n" +
+		fmt.Sprintf("c=IN %s %s\r\n", addrType, host) +
+		"t=0 0\r\n" +
+		media("video", videoPort, videoCodec) +
+		media("audio", audioPort, audioCodec)
+}
+
+//
+// srt
+//
+
+// only codecs supported by mpegts muxer
+var forwardSRTCaps = map[string]string{
+	codec.H264().Name: "video/x-h264,stream-format=byte-stream,alignment=au ! h264parse config-interval=-1",
+	codec.H265().Name: "video/x-h265,stream-format=byte-stream,alignment=au ! h265parse config-interval=-1",
+	codec.Opus().Name: "audio/x-opus,channel-mapping-family=0 ! opusparse",
+}
+
+type forwardSRTTrack struct {
+	pipeline gst.Pipeline
+	srcName  string
+}
+
+func (track *forwardSRTTrack) WriteSample(sample types.Sample) {
+	track.pipeline.PushTo(track.srcName, sample.Data)
+}
+
+func (manager *ForwardManagerCtx) createSRT(logger zerolog.Logger, fwd *forwardCtx, host string, port int) error {
+	if err := gst.CheckPlugins([]string{"srt", "mpegtsmux"}); err != nil {
+		return err
+	}
+
+	videoCodec, audioCodec := fwd.video.Codec(), fwd.audio.Codec()
+
+	videoCaps, ok := forwardSRTCaps[videoCodec.Name]
+	if !ok || !videoCodec.IsVideo() {
+		return fmt.Errorf("video codec %s cannot be forwarded over srt", videoCodec.Name)
+	}
+
+	audioCaps, ok := forwardSRTCaps[audioCodec.Name]
+	if !ok || !audioCodec.IsAudio() {
+		return fmt.Errorf("audio codec %s cannot be forwarded over srt", audioCodec.Name)
+	}
+
+	pipelineStr := fmt.Sprintf(
+		"mpegtsmux name=mux alignment=7 ! srtsink uri=srt://%s wait-for-connection=false "+
+			"appsrc name=appsrc_video format=time is-live=true do-timestamp=true caps=%s ! queue ! mux. "+
+			"appsrc name=appsrc_audio format=time is-live=true do-timestamp=true caps=%s ! queue ! mux.",
+		net.JoinHostPort(host, strconv.Itoa(port)), videoCaps, audioCaps,
+	)
+
+	logger.Info().Str("src", pipelineStr).Msg("creating pipeline")
+
+	pipeline, err := gst.CreatePipeline(pipelineStr)
+	if err != nil {
+		return err
+	}
+
+	pipeline.Play()
+
+	fwd.videoTrack = &forwardSRTTrack{pipeline: pipeline, srcName: "appsrc_video"}
+	fwd.audioTrack = &forwardSRTTrack{pipeline: pipeline, srcName: "appsrc_audio"}
+	fwd.destroyFunc = pipeline.Destroy
+
+	return nil
+}
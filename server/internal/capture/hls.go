@@ -0,0 +1,425 @@
+package capture
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+
+	"github.com/m1k1o/neko/server/pkg/gst"
+	"github.com/m1k1o/neko/server/pkg/types"
+)
+
+const (
+	hlsPlaylistName  = "playlist.m3u8"
+	hlsSegmentPrefix = "segment"
+
+	// timeout between intervals, when hls pipeline is checked
+	hlsTimeout = 30 * time.Second
+
+	// how often is checked whether the first playlist was written
+	hlsPlaylistInterval = 100 * time.Millisecond
+)
+
+// HLSManagerCtx writes segments and playlist to a temporary directory, that is
+// created with the pipeline and removed when nobody requested the playlist for a while.
+type HLSManagerCtx struct {
+	logger zerolog.Logger
+	mu     sync.Mutex
+	wg     sync.WaitGroup
+
+	pipeline   gst.Pipeline
+	pipelineFn func(playlist, segment string) (string, error)
+	pipelineMu sync.Mutex
+	dir        string
+
+	// pipeline interrupted by screen size change is replaced by a new one writing to
+	// the same directory, its segments are numbered from zero again, so that served
+	// playlist continues the previous one after a discontinuity
+	generation     int
+	sequenceOffset int
+
+	tickerStop chan struct{}
+
+	enabled          bool
+	started          bool
+	expired          int32
+	maxWebRTCViewers int
+	// first playlist is written after the first segment
+	playlistTimeout time.Duration
+
+	// metrics
+	pipelinesCounter prometheus.Counter
+	pipelinesActive  prometheus.Gauge
+}
+
+func hlsNew(enabled bool, pipelineFn func(playlist, segment string) (string, error), segmentDuration time.Duration, maxWebRTCViewers int) *HLSManagerCtx {
+	logger := log.With().
+		Str("module", "capture").
+		Str("submodule", "hls").
+		Logger()
+
+	manager := &HLSManagerCtx{
+		logger:     logger,
+		pipelineFn: pipelineFn,
+		tickerStop: make(chan struct{}),
+
+		enabled:          enabled,
+		started:          false,
+		maxWebRTCViewers: maxWebRTCViewers,
+		playlistTimeout:  2*segmentDuration + 5*time.Second,
+
+		// metrics
+		pipelinesCounter: promauto.NewCounter(prometheus.CounterOpts{
+			Name:      "pipelines_total",
+			Namespace: "neko",
+			Subsystem: "capture",
+			Help:      "Total number of created pipelines.",
+			ConstLabels: map[string]string{
+				"submodule":  "hls",
+				"video_id":   "main",
+				"codec_name": "-",
+				"codec_type": "-",
+			},
+		}),
+		pipelinesActive: promauto.NewGauge(prometheus.GaugeOpts{
+			Name:      "pipelines_active",
+			Namespace: "neko",
+			Subsystem: "capture",
+			Help:      "Total number of active pipelines.",
+			ConstLabels: map[string]string{
+				"submodule":  "hls",
+				"video_id":   "main",
+				"codec_name": "-",
+				"codec_type": "-",
+			},
+		}),
+	}
+
+	manager.wg.Go(func() {
+		ticker := time.NewTicker(hlsTimeout)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-manager.tickerStop:
+				return
+			case <-ticker.C:
+				if manager.Started() && !atomic.CompareAndSwapInt32(&manager.expired, 0, 1) {
+					manager.stop()
+				}
+			}
+		}
+	})
+
+	return manager
+}
+
+func (manager *HLSManagerCtx) shutdown() {
+	manager.logger.Info().Msgf("shutdown")
+
+	manager.destroyPipeline()
+
+	close(manager.tickerStop)
+	manager.wg.Wait()
+}
+
+func (manager *HLSManagerCtx) Enabled() bool {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	return manager.enabled
+}
+
+func (manager *HLSManagerCtx) Started() bool {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	return manager.started
+}
+
+func (manager *HLSManagerCtx) MaxWebRTCViewers() int {
+	return manager.maxWebRTCViewers
+}
+
+func (manager *HLSManagerCtx) Playlist() ([]byte, error) {
+	atomic.StoreInt32(&manager.expired, 0)
+
+	err := manager.start()
+	if err != nil && !errors.Is(err, types.ErrCapturePipelineAlreadyExists) {
+		return nil, err
+	}
+
+	manager.pipelineMu.Lock()
+	dir := manager.dir
+	generation := manager.generation
+	sequenceOffset := manager.sequenceOffset
+	manager.pipelineMu.Unlock()
+
+	if dir == "" {
+		return nil, errors.New("hls pipeline is not running")
+	}
+
+	deadline := time.Now().Add(manager.playlistTimeout)
+	for {
+		data, err := os.ReadFile(filepath.Join(dir, hlsPlaylistName))
+		if err == nil {
+			return hlsContinuePlaylist(data, generation, sequenceOffset), nil
+		}
+
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+
+		if time.Now().After(deadline) {
+			return nil, errors.New("timeouted while waiting for playlist")
+		}
+
+		time.Sleep(hlsPlaylistInterval)
+	}
+}
+
+func (manager *HLSManagerCtx) Segment(name string) (string, error) {
+	atomic.StoreInt32(&manager.expired, 0)
+
+	// name must not point outside of hls directory
+	if name != filepath.Base(name) || !strings.HasPrefix(name, hlsSegmentPrefix) {
+		return "", types.ErrHLSSegmentNotFound
+	}
+
+	manager.pipelineMu.Lock()
+	dir := manager.dir
+	manager.pipelineMu.Unlock()
+
+	if dir == "" {
+		return "", types.ErrHLSSegmentNotFound
+	}
+
+	path := filepath.Join(dir, name)
+	if _, err := os.Stat(path); err != nil {
+		return "", types.ErrHLSSegmentNotFound
+	}
+
+	return path, nil
+}
+
+func (manager *HLSManagerCtx) start() error {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	if !manager.enabled {
+		return errors.New("hls not enabled")
+	}
+
+	err := manager.createPipeline()
+	if err != nil {
+		return err
+	}
+
+	manager.started = true
+	return nil
+}
+
+func (manager *HLSManagerCtx) stop() {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	manager.started = false
+	manager.destroyPipeline()
+}
+
+func (manager *HLSManagerCtx) createPipeline() error {
+	manager.pipelineMu.Lock()
+	defer manager.pipelineMu.Unlock()
+
+	if manager.pipeline != nil {
+		return types.ErrCapturePipelineAlreadyExists
+	}
+
+	// directory of interrupted pipeline is kept
+	dir := manager.dir
+	if dir == "" {
+		var err error
+		dir, err = os.MkdirTemp("", "neko-hls-")
+		if err != nil {
+			return err
+		}
+	}
+
+	// segment names must not collide with segments of interrupted pipelines
+	segment := fmt.Sprintf("%s%d-%%05d.ts", hlsSegmentPrefix, manager.generation)
+
+	pipelineStr, err := manager.pipelineFn(filepath.Join(dir, hlsPlaylistName), filepath.Join(dir, segment))
+	if err != nil {
+		manager.removeDir(dir)
+		return err
+	}
+
+	manager.logger.Info().
+		Str("dir", dir).
+		Str("src", pipelineStr).
+		Msgf("creating pipeline")
+
+	pipeline, err := gst.CreatePipeline(pipelineStr)
+	if err != nil {
+		manager.removeDir(dir)
+		return err
+	}
+
+	// failed pipeline is created again by the next request
+	pipeline.OnError(func(err error) {
+		manager.logger.Err(err).Msg("pipeline failed")
+
+		manager.mu.Lock()
+		defer manager.mu.Unlock()
+
+		manager.pipelineMu.Lock()
+		failed := manager.pipeline == pipeline
+		manager.pipelineMu.Unlock()
+
+		if failed {
+			manager.started = false
+			manager.destroyPipeline()
+		}
+	})
+
+	pipeline.Play()
+	manager.pipeline = pipeline
+	manager.dir = dir
+
+	manager.pipelinesCounter.Inc()
+	manager.pipelinesActive.Set(1)
+
+	return nil
+}
+
+func (manager *HLSManagerCtx) destroyPipeline() {
+	manager.pipelineMu.Lock()
+	defer manager.pipelineMu.Unlock()
+
+	if manager.pipeline != nil {
+		manager.pipeline.Destroy()
+		manager.logger.Info().Msgf("destroying pipeline")
+		manager.pipeline = nil
+
+		manager.pipelinesActive.Set(0)
+	}
+
+	// directory might be kept by interrupted pipeline
+	if manager.dir != "" {
+		manager.removeDir(manager.dir)
+	}
+}
+
+// interruptPipeline destroys pipeline, but keeps its directory with segments,
+// so that players can continue with segments of the next pipeline.
+func (manager *HLSManagerCtx) interruptPipeline() {
+	manager.pipelineMu.Lock()
+	defer manager.pipelineMu.Unlock()
+
+	if manager.pipeline == nil {
+		return
+	}
+
+	manager.pipeline.Destroy()
+	manager.logger.Info().Msgf("interrupting pipeline")
+	manager.pipeline = nil
+
+	manager.pipelinesActive.Set(0)
+
+	// playlist is removed, so that it is served again only after the next pipeline writes it
+	playlist := filepath.Join(manager.dir, hlsPlaylistName)
+	data, err := os.ReadFile(playlist)
+	if err == nil {
+		sequence, segments := hlsPlaylistSegments(data)
+		err = os.Remove(playlist)
+
+		manager.sequenceOffset += sequence + segments
+	}
+
+	if err != nil && !os.IsNotExist(err) {
+		manager.logger.Err(err).Str("dir", manager.dir).Msg("unable to remove hls playlist")
+	}
+
+	// segments of the previous pipeline were not in the playlist for a while
+	if manager.generation > 0 {
+		pattern := fmt.Sprintf("%s%d-*.ts", hlsSegmentPrefix, manager.generation-1)
+		segments, _ := filepath.Glob(filepath.Join(manager.dir, pattern))
+		for _, segment := range segments {
+			os.Remove(segment)
+		}
+	}
+
+	manager.generation++
+}
+
+func (manager *HLSManagerCtx) removeDir(dir string) {
+	if err := os.RemoveAll(dir); err != nil {
+		manager.logger.Err(err).Str("dir", dir).Msg("unable to remove hls directory")
+	}
+
+	manager.dir = ""
+	manager.generation = 0
+	manager.sequenceOffset = 0
+}
+
+// hlsPlaylistSegments returns media sequence number of the first segment and count of segments
+func hlsPlaylistSegments(data []byte) (sequence int, segments int) {
+	for line := range strings.Lines(string(data)) {
+		line = strings.TrimSpace(line)
+
+		if value, ok := strings.CutPrefix(line, "#EXT-X-MEDIA-SEQUENCE:"); ok {
+			sequence, _ = strconv.Atoi(value)
+		} else if line != "" && !strings.HasPrefix(line, "#") {
+			segments++
+		}
+	}
+
+	return
+}
+
+// hlsContinuePlaylist offsets media sequence of the playlist written by pipeline of given generation,
+// so that it continues playlists of interrupted pipelines, and marks the discontinuity between them.
+func hlsContinuePlaylist(data []byte, generation, sequenceOffset int) []byte {
+	if generation == 0 {
+		return data
+	}
+
+	sequence, _ := hlsPlaylistSegments(data)
+
+	// discontinuity is before the first segment of the generation, while it is in the playlist
+	discontinuity := sequence == 0
+	discontinuitySequence := generation
+	if discontinuity {
+		discontinuitySequence--
+	}
+
+	var b strings.Builder
+	for line := range strings.Lines(string(data)) {
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case strings.HasPrefix(trimmed, "#EXT-X-MEDIA-SEQUENCE:"):
+			fmt.Fprintf(&b, "#EXT-X-MEDIA-SEQUENCE:%d\n", sequence+sequenceOffset)
+			fmt.Fprintf(&b, "#EXT-X-DISCONTINUITY-SEQUENCE:%d\n", discontinuitySequence)
+			continue
+		case discontinuity && strings.HasPrefix(trimmed, "#EXTINF:"):
+			b.WriteString("#EXT-X-DISCONTINUITY\n")
+			discontinuity = false
+		}
+
+		b.WriteString(line)
+	}
+
+	return []byte(b.String())
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"slices"
 	"strings"
 
 	"github.com/rs/zerolog"
@@ -21,9 +22,13 @@ type CaptureManagerCtx struct {
 
 	// sinks
 	broadcast  *BroacastManagerCtx
+	recording  *RecordingManagerCtx
 	screencast *ScreencastManagerCtx
+	hls        *HLSManagerCtx
+	forward    *ForwardManagerCtx
 	audio      *StreamSinkManagerCtx
 	video      *StreamSelectorManagerCtx
+	videos     []*StreamSelectorManagerCtx
 
 	// sources
 	webcam     *StreamSrcManagerCtx
@@ -33,52 +38,43 @@ type CaptureManagerCtx struct {
 func New(desktop types.DesktopManager, config *config.Capture) *CaptureManagerCtx {
 	logger := log.With().Str("module", "capture").Logger()
 
-	videos := map[string]types.StreamSinkManager{}
-	for video_id, cnf := range config.VideoPipelines {
-		pipelineConf := cnf
-
-		createPipeline := func() (string, error) {
-			if pipelineConf.GstPipeline != "" {
-				// replace {display} with valid display
-				return strings.Replace(pipelineConf.GstPipeline, "{display}", config.Display, 1), nil
-			}
-
-			screen := desktop.GetScreenSize()
-			pipeline, err := pipelineConf.GetPipeline(screen)
-			if err != nil {
-				return "", err
-			}
-
-			return fmt.Sprintf(
-				"ximagesrc display-name=%s show-pointer=%v use-damage=false "+
-					"%s ! appsink name=appsink", config.Display, pipelineConf.ShowPointer, pipeline,
-			), nil
-		}
-
-		// trigger function to catch evaluation errors at startup
-		pipeline, err := createPipeline()
-		if err != nil {
-			logger.Panic().Err(err).
-				Str("video_id", video_id).
-				Msg("failed to create video pipeline")
-		}
-
-		logger.Info().
-			Str("video_id", video_id).
-			Str("pipeline", pipeline).
-			Msg("syntax check for video stream pipeline passed")
-
-		// append to videos
-		videos[video_id] = streamSinkNew(config.VideoCodec, createPipeline, video_id)
+	// default video codec is always first
+	videos := []*StreamSelectorManagerCtx{
+		videoNew(logger, desktop, config, config.VideoCodec, config.VideoPipelines),
+	}
+	for _, cnf := range config.VideoCodecs {
+		videos = append(videos, videoNew(logger, desktop, config, cnf.Codec, cnf.Pipelines))
 	}
 
-	return &CaptureManagerCtx{
+	manager := &CaptureManagerCtx{
 		logger:  logger,
 		desktop: desktop,
 		config:  config,
 
 		// sinks
-		broadcast: broadcastNew(func(url string) (string, error) {
+		broadcast: broadcastNew(func() (string, error) {
+			// custom pipeline encodes the screen for every destination
+			if config.BroadcastPipeline != "" {
+				return "", nil
+			}
+
+			return fmt.Sprintf(
+				"pulsesrc device=%s "+
+					"! audio/x-raw,channels=2 "+
+					"! audioconvert "+
+					"! queue "+
+					"! voaacenc bitrate=%d "+
+					"! audio/mpeg,stream-format=adts "+
+					"! appsink name=appsink_%s "+
+					"ximagesrc display-name=%s show-pointer=true use-damage=false "+
+					"! video/x-raw "+
+					"! videoconvert "+
+					"! queue "+
+					"! x264enc threads=4 bitrate=%d key-int-max=15 byte-stream=true tune=zerolatency speed-preset=%s "+
+					"! video/x-h264,stream-format=byte-stream "+
+					"! appsink name=appsink_%s", config.AudioDevice, config.BroadcastAudioBitrate*1000, broadcastAudioName, config.Display, config.BroadcastVideoBitrate, config.BroadcastPreset, broadcastVideoName,
+			), nil
+		}, func(url string) (string, error) {
 			if config.BroadcastPipeline != "" {
 				var pipeline = config.BroadcastPipeline
 				if hostname, err := os.Hostname(); err == nil {
@@ -94,21 +90,65 @@ func New(desktop types.DesktopManager, config *config.Capture) *CaptureManagerCt
 			}
 
 			return fmt.Sprintf(
-				"flvmux name=mux ! rtmpsink location='%s live=1' "+
+				"flvmux name=mux streamable=true ! rtmpsink location='%s live=1' "+
+					"appsrc name=appsrc_%s format=time is-live=true do-timestamp=true caps=audio/mpeg,mpegversion=4,stream-format=adts "+
+					"! aacparse "+
+					"! queue "+
+					"! mux. "+
+					"appsrc name=appsrc_%s format=time is-live=true do-timestamp=true caps=video/x-h264,stream-format=byte-stream,alignment=au "+
+					"! h264parse config-interval=-1 "+
+					"! queue "+
+					"! mux.", url, broadcastAudioName, broadcastVideoName,
+			), nil
+		}, config.BroadcastUrl, config.BroadcastAutostart),
+		recording: recordingNew(func(file string) (string, error) {
+			if config.RecordingPipeline != "" {
+				var pipeline = config.RecordingPipeline
+				// replace {display} with valid display
+				pipeline = strings.Replace(pipeline, "{display}", config.Display, 1)
+				// replace {device} with valid device
+				pipeline = strings.Replace(pipeline, "{device}", config.AudioDevice, 1)
+				// replace {file} with path of the recording
+				return strings.Replace(pipeline, "{file}", file, 1), nil
+			}
+
+			// muxers write files, that can be played even if the pipeline is not finalized
+			if config.RecordingFormat == "webm" {
+				return fmt.Sprintf(
+					"webmmux name=mux streamable=true ! filesink location=%s "+
+						"pulsesrc device=%s "+
+						"! audio/x-raw,channels=2 "+
+						"! audioconvert "+
+						"! queue "+
+						"! opusenc bitrate=%d "+
+						"! mux. "+
+						"ximagesrc display-name=%s show-pointer=true use-damage=false "+
+						"! video/x-raw "+
+						"! videoconvert "+
+						"! queue "+
+						"! vp8enc target-bitrate=%d cpu-used=8 threads=4 deadline=1 keyframe-max-dist=60 "+
+						"! mux.", gstQuote(file), config.AudioDevice, config.BroadcastAudioBitrate*1000, config.Display, config.BroadcastVideoBitrate*1000,
+				), nil
+			}
+
+			return fmt.Sprintf(
+				"mp4mux name=mux fragment-duration=1000 streamable=true ! filesink location=%s "+
 					"pulsesrc device=%s "+
 					"! audio/x-raw,channels=2 "+
 					"! audioconvert "+
 					"! queue "+
 					"! voaacenc bitrate=%d "+
+					"! aacparse "+
 					"! mux. "+
 					"ximagesrc display-name=%s show-pointer=true use-damage=false "+
 					"! video/x-raw "+
 					"! videoconvert "+
 					"! queue "+
-					"! x264enc threads=4 bitrate=%d key-int-max=15 byte-stream=true tune=zerolatency speed-preset=%s "+
-					"! mux.", url, config.AudioDevice, config.BroadcastAudioBitrate*1000, config.Display, config.BroadcastVideoBitrate, config.BroadcastPreset,
+					"! x264enc threads=4 bitrate=%d key-int-max=60 tune=zerolatency speed-preset=%s "+
+					"! h264parse "+
+					"! mux.", gstQuote(file), config.AudioDevice, config.BroadcastAudioBitrate*1000, config.Display, config.BroadcastVideoBitrate, config.BroadcastPreset,
 			), nil
-		}, config.BroadcastUrl, config.BroadcastAutostart),
+		}, config),
 		screencast: screencastNew(config.ScreencastEnabled, func() string {
 			if config.ScreencastPipeline != "" {
 				// replace {display} with valid display
@@ -124,6 +164,39 @@ func New(desktop types.DesktopManager, config *config.Capture) *CaptureManagerCt
 					"! appsink name=appsink", config.Display, config.ScreencastRate, config.ScreencastQuality,
 			)
 		}()),
+		hls: hlsNew(config.HLSEnabled, func(playlist, segment string) (string, error) {
+			if config.HLSPipeline != "" {
+				var pipeline = config.HLSPipeline
+				// replace {display} with valid display
+				pipeline = strings.Replace(pipeline, "{display}", config.Display, 1)
+				// replace {device} with valid device
+				pipeline = strings.Replace(pipeline, "{device}", config.AudioDevice, 1)
+				// replace {playlist} with path of the playlist
+				pipeline = strings.Replace(pipeline, "{playlist}", playlist, 1)
+				// replace {segment} with path template of segments
+				return strings.Replace(pipeline, "{segment}", segment, 1), nil
+			}
+
+			// every segment must start with a keyframe
+			duration := int(config.HLSSegmentDuration.Seconds())
+			return fmt.Sprintf(
+				"hlssink2 name=hls location=%s playlist-location=%s target-duration=%d playlist-length=%d max-files=%d "+
+					"pulsesrc device=%s "+
+					"! audio/x-raw,channels=2 "+
+					"! audioconvert "+
+					"! queue "+
+					"! voaacenc bitrate=%d "+
+					"! aacparse "+
+					"! hls.audio "+
+					"ximagesrc display-name=%s show-pointer=true use-damage=false "+
+					"! video/x-raw,framerate=25/1 "+
+					"! videoconvert "+
+					"! queue "+
+					"! x264enc threads=4 bitrate=%d key-int-max=%d tune=zerolatency speed-preset=%s "+
+					"! h264parse "+
+					"! hls.video", segment, playlist, duration, config.HLSPlaylistLength, 2*config.HLSPlaylistLength, config.AudioDevice, config.BroadcastAudioBitrate*1000, config.Display, config.BroadcastVideoBitrate, 25*duration, config.BroadcastPreset,
+			), nil
+		}, config.HLSSegmentDuration, config.HLSMaxWebRTCViewers),
 
 		audio: streamSinkNew(config.AudioCodec, func() (string, error) {
 			if config.AudioPipeline != "" {
@@ -140,7 +213,8 @@ func New(desktop types.DesktopManager, config *config.Capture) *CaptureManagerCt
 					"! appsink name=appsink", config.AudioDevice, config.AudioCodec.Pipeline,
 			), nil
 		}, "audio"),
-		video: streamSelectorNew(config.VideoCodec, videos, config.VideoIDs),
+		video:  videos[0],
+		videos: videos,
 
 		// sources
 		webcam: streamSrcNew(config.WebcamEnabled, map[string]string{
@@ -191,46 +265,215 @@ func New(desktop types.DesktopManager, config *config.Capture) *CaptureManagerCt
 				fmt.Sprintf("! pulsesink device=%s", config.MicrophoneDevice),
 		}, "microphone"),
 	}
+
+	manager.audio.enableWatchdog(config.WatchdogTimeout)
+	manager.screencast.enableWatchdog(config.WatchdogTimeout)
+
+	if config.MicrophoneMixing {
+		manager.microphone.enableMixing(
+			fmt.Sprintf("audiomixer name=mixer ! audioconvert ! pulsesink device=%s", config.MicrophoneDevice),
+			map[string]string{
+				codec.Opus().Name: "appsrc format=time is-live=true do-timestamp=true name=appsrc{name} " +
+					fmt.Sprintf("! application/x-rtp, payload=%d, encoding-name=OPUS ", codec.Opus().PayloadType) +
+					"! rtpopusdepay " +
+					"! decodebin " +
+					"! audioconvert " +
+					"! audioresample " +
+					"! volume name=volume{name} mute={mute} " +
+					"! mixer.",
+				codec.G722().Name: "appsrc format=time is-live=true do-timestamp=true name=appsrc{name} " +
+					"! application/x-rtp clock-rate=8000 " +
+					"! rtpg722depay " +
+					"! decodebin " +
+					"! audioconvert " +
+					"! audioresample " +
+					"! volume name=volume{name} mute={mute} " +
+					"! mixer.",
+			},
+		)
+	}
+
+	manager.forward = forwardNew(manager.audio, manager.Videos())
+	return manager
 }
 
-func (manager *CaptureManagerCtx) Start() {
-	if manager.broadcast.Started() {
-		if err := manager.broadcast.createPipeline(); err != nil {
-			manager.logger.Panic().Err(err).Msg("unable to create broadcast pipeline")
+// videoNew creates stream sinks for all video pipelines of a single codec
+func videoNew(logger zerolog.Logger, desktop types.DesktopManager, config *config.Capture, videoCodec codec.RTPCodec, pipelines map[string]types.VideoConfig) *StreamSelectorManagerCtx {
+	// video pipelines, that can share single screen capture
+	sharedIDs := []string{}
+	if config.VideoShared {
+		for video_id, cnf := range pipelines {
+			if cnf.GstPipeline == "" && !cnf.ShowPointer {
+				sharedIDs = append(sharedIDs, video_id)
+			}
 		}
+		slices.Sort(sharedIDs)
+	}
+
+	var tee *StreamTeeManagerCtx
+	if len(sharedIDs) > 0 {
+		createPipeline := func() (string, error) {
+			screen := desktop.GetScreenSize()
+
+			// capture only once at the screen framerate and split it to encoders of all video pipelines
+			pipeline := fmt.Sprintf(
+				"ximagesrc display-name=%s show-pointer=false use-damage=false "+
+					"! video/x-raw,framerate=%d/1 ! tee name=t", config.Display, max(screen.Rate, 1),
+			)
+
+			for _, video_id := range sharedIDs {
+				pipelineConf := pipelines[video_id]
+
+				branch, err := pipelineConf.GetNamedPipeline(screen, "_"+video_id)
+				if err != nil {
+					return "", err
+				}
+
+				// valve is opened only when the branch is used
+				pipeline += fmt.Sprintf(
+					" t. ! queue ! valve name=%s drop=true "+
+						"%s ! appsink name=%s", teeValveName(video_id), branch, teeAppsinkName(video_id),
+				)
+			}
+
+			return pipeline, nil
+		}
+
+		// trigger function to catch evaluation errors at startup
+		pipeline, err := createPipeline()
+		if err != nil {
+			logger.Panic().Err(err).
+				Str("codec", videoCodec.Name).
+				Msg("failed to create shared video pipeline")
+		}
+
+		logger.Info().
+			Str("codec", videoCodec.Name).
+			Strs("video_ids", sharedIDs).
+			Str("pipeline", pipeline).
+			Msg("syntax check for shared video stream pipeline passed")
+
+		tee = streamTeeNew(videoCodec, createPipeline, sharedIDs)
+		tee.enableWatchdog(config.WatchdogTimeout)
+	}
+
+	streams := map[string]types.StreamSinkManager{}
+	for video_id, cnf := range pipelines {
+		if tee != nil && slices.Contains(sharedIDs, video_id) {
+			streams[video_id] = streamSinkTeeNew(videoCodec, tee, video_id)
+			continue
+		}
+
+		pipelineConf := cnf
+
+		createPipeline := func() (string, error) {
+			if pipelineConf.GstPipeline != "" {
+				// replace {display} with valid display
+				return strings.Replace(pipelineConf.GstPipeline, "{display}", config.Display, 1), nil
+			}
+
+			screen := desktop.GetScreenSize()
+			pipeline, err := pipelineConf.GetPipeline(screen)
+			if err != nil {
+				return "", err
+			}
+
+			return fmt.Sprintf(
+				"ximagesrc display-name=%s show-pointer=%v use-damage=false "+
+					"%s ! appsink name=appsink", config.Display, pipelineConf.ShowPointer, pipeline,
+			), nil
+		}
+
+		// trigger function to catch evaluation errors at startup
+		pipeline, err := createPipeline()
+		if err != nil {
+			logger.Panic().Err(err).
+				Str("codec", videoCodec.Name).
+				Str("video_id", video_id).
+				Msg("failed to create video pipeline")
+		}
+
+		logger.Info().
+			Str("codec", videoCodec.Name).
+			Str("video_id", video_id).
+			Str("pipeline", pipeline).
+			Msg("syntax check for video stream pipeline passed")
+
+		// append to videos
+		stream := streamSinkNew(videoCodec, createPipeline, video_id)
+		stream.enableWatchdog(config.WatchdogTimeout)
+		streams[video_id] = stream
+	}
+
+	return streamSelectorNew(videoCodec, streams, config.VideoIDs, tee)
+}
+
+func (manager *CaptureManagerCtx) Start() {
+	if err := manager.broadcast.createPipelines(); err != nil {
+		manager.logger.Err(err).Msg("unable to create broadcast pipelines")
 	}
 
 	manager.desktop.OnBeforeScreenSizeChange(func() {
-		manager.video.destroyPipelines()
+		// watchdogs would restart destroyed pipelines with the old screen size
+		for _, video := range manager.videos {
+			video.suspendWatchdogs()
+		}
+		manager.screencast.watchdog.suspend()
 
-		if manager.broadcast.Started() {
-			manager.broadcast.destroyPipeline()
+		for _, video := range manager.videos {
+			video.destroyPipelines()
+		}
+
+		manager.broadcast.destroyPipelines()
+
+		if manager.recording.Started() {
+			manager.recording.destroyPipeline()
 		}
 
 		if manager.screencast.Started() {
 			manager.screencast.destroyPipeline()
 		}
+
+		if manager.hls.Started() {
+			manager.hls.interruptPipeline()
+		}
 	})
 
 	manager.desktop.OnAfterScreenSizeChange(func() {
-		err := manager.video.recreatePipelines()
-		if err != nil {
-			manager.logger.Panic().Err(err).Msg("unable to recreate video pipelines")
+		for _, video := range manager.videos {
+			err := video.recreatePipelines()
+			if err != nil {
+				manager.logger.Panic().Err(err).Msg("unable to recreate video pipelines")
+			}
 		}
 
-		if manager.broadcast.Started() {
-			err := manager.broadcast.createPipeline()
-			if err != nil && !errors.Is(err, types.ErrCapturePipelineAlreadyExists) {
-				manager.logger.Panic().Err(err).Msg("unable to recreate broadcast pipeline")
-			}
+		// failed destinations are reconnected later, others continue broadcasting
+		if err := manager.broadcast.createPipelines(); err != nil {
+			manager.logger.Err(err).Msg("unable to recreate broadcast pipelines")
 		}
 
+		// recording continues in a new file with the new screen size
+		manager.recording.recreatePipeline()
+
 		if manager.screencast.Started() {
 			err := manager.screencast.createPipeline()
 			if err != nil && !errors.Is(err, types.ErrCapturePipelineAlreadyExists) {
 				manager.logger.Panic().Err(err).Msg("unable to recreate screencast pipeline")
 			}
 		}
+
+		// players continue with segments of the new pipeline after a discontinuity
+		if manager.hls.Started() {
+			err := manager.hls.createPipeline()
+			if err != nil && !errors.Is(err, types.ErrCapturePipelineAlreadyExists) {
+				manager.logger.Err(err).Msg("unable to recreate hls pipeline")
+			}
+		}
+
+		for _, video := range manager.videos {
+			video.resumeWatchdogs()
+		}
+		manager.screencast.watchdog.resume()
 	})
 }
 
@@ -238,10 +481,15 @@ func (manager *CaptureManagerCtx) Shutdown() error {
 	manager.logger.Info().Msgf("shutdown")
 
 	manager.broadcast.shutdown()
+	manager.recording.shutdown()
 	manager.screencast.shutdown()
+	manager.hls.shutdown()
+	manager.forward.shutdown()
 
 	manager.audio.shutdown()
-	manager.video.shutdown()
+	for _, video := range manager.videos {
+		video.shutdown()
+	}
 
 	manager.webcam.shutdown()
 	manager.microphone.shutdown()
@@ -253,10 +501,22 @@ func (manager *CaptureManagerCtx) Broadcast() types.BroadcastManager {
 	return manager.broadcast
 }
 
+func (manager *CaptureManagerCtx) Recording() types.RecordingManager {
+	return manager.recording
+}
+
 func (manager *CaptureManagerCtx) Screencast() types.ScreencastManager {
 	return manager.screencast
 }
 
+func (manager *CaptureManagerCtx) HLS() types.HLSManager {
+	return manager.hls
+}
+
+func (manager *CaptureManagerCtx) Forward() types.ForwardManager {
+	return manager.forward
+}
+
 func (manager *CaptureManagerCtx) Audio() types.StreamSinkManager {
 	return manager.audio
 }
@@ -265,6 +525,14 @@ func (manager *CaptureManagerCtx) Video() types.StreamSelectorManager {
 	return manager.video
 }
 
+func (manager *CaptureManagerCtx) Videos() []types.StreamSelectorManager {
+	videos := make([]types.StreamSelectorManager, len(manager.videos))
+	for i, video := range manager.videos {
+		videos[i] = video
+	}
+	return videos
+}
+
 func (manager *CaptureManagerCtx) Webcam() types.StreamSrcManager {
 	return manager.webcam
 }
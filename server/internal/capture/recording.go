@@ -0,0 +1,432 @@
+package capture
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+
+	"github.com/m1k1o/neko/server/internal/config"
+	"github.com/m1k1o/neko/server/pkg/gst"
+	"github.com/m1k1o/neko/server/pkg/types"
+)
+
+const (
+	recordingPrefix = "recording-"
+
+	// how often is current file checked for rotation
+	recordingRotateInterval = time.Second
+
+	// how long to wait for muxer to finalize the file
+	recordingEOSTimeout = 5 * time.Second
+)
+
+type RecordingManagerCtx struct {
+	logger zerolog.Logger
+	mu     sync.Mutex
+
+	pipeline   gst.Pipeline
+	pipelineMu sync.Mutex
+	pipelineFn func(file string) (string, error)
+
+	dir         string
+	ext         string
+	maxDuration time.Duration
+	maxSize     int64
+	maxFiles    int
+	maxAge      time.Duration
+
+	file      string
+	fileSince time.Time
+	started   bool
+	stop      chan struct{}
+
+	statusListener func(isActive bool, file string)
+
+	// metrics
+	pipelinesCounter prometheus.Counter
+	pipelinesActive  prometheus.Gauge
+}
+
+func recordingNew(pipelineFn func(file string) (string, error), config *config.Capture) *RecordingManagerCtx {
+	logger := log.With().
+		Str("module", "capture").
+		Str("submodule", "recording").
+		Logger()
+
+	return &RecordingManagerCtx{
+		logger:     logger,
+		pipelineFn: pipelineFn,
+
+		dir:         config.RecordingDir,
+		ext:         config.RecordingFormat,
+		maxDuration: config.RecordingMaxDuration,
+		maxSize:     int64(config.RecordingMaxSize) * 1024 * 1024,
+		maxFiles:    config.RecordingMaxFiles,
+		maxAge:      config.RecordingMaxAge,
+
+		// metrics
+		pipelinesCounter: promauto.NewCounter(prometheus.CounterOpts{
+			Name:      "pipelines_total",
+			Namespace: "neko",
+			Subsystem: "capture",
+			Help:      "Total number of created pipelines.",
+			ConstLabels: map[string]string{
+				"submodule":  "recording",
+				"video_id":   "main",
+				"codec_name": "-",
+				"codec_type": "-",
+			},
+		}),
+		pipelinesActive: promauto.NewGauge(prometheus.GaugeOpts{
+			Name:      "pipelines_active",
+			Namespace: "neko",
+			Subsystem: "capture",
+			Help:      "Total number of active pipelines.",
+			ConstLabels: map[string]string{
+				"submodule":  "recording",
+				"video_id":   "main",
+				"codec_name": "-",
+				"codec_type": "-",
+			},
+		}),
+	}
+}
+
+func (manager *RecordingManagerCtx) shutdown() {
+	manager.logger.Info().Msgf("shutdown")
+
+	manager.Stop()
+}
+
+func (manager *RecordingManagerCtx) Start() error {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	if manager.started {
+		return types.ErrCapturePipelineAlreadyExists
+	}
+
+	if err := os.MkdirAll(manager.dir, 0755); err != nil {
+		return err
+	}
+
+	if err := manager.createPipeline(); err != nil {
+		return err
+	}
+
+	manager.started = true
+
+	if manager.maxDuration > 0 || manager.maxSize > 0 {
+		manager.stop = make(chan struct{})
+		go manager.rotate(manager.stop)
+	}
+
+	manager.statusChanged()
+	return nil
+}
+
+func (manager *RecordingManagerCtx) Stop() {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	if manager.stop != nil {
+		close(manager.stop)
+		manager.stop = nil
+	}
+
+	wasStarted := manager.started
+	manager.started = false
+	manager.destroyPipeline()
+	manager.retention()
+
+	if wasStarted {
+		manager.statusChanged()
+	}
+}
+
+func (manager *RecordingManagerCtx) Started() bool {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	return manager.started
+}
+
+func (manager *RecordingManagerCtx) File() string {
+	manager.pipelineMu.Lock()
+	defer manager.pipelineMu.Unlock()
+
+	if manager.pipeline == nil {
+		return ""
+	}
+
+	return filepath.Base(manager.file)
+}
+
+func (manager *RecordingManagerCtx) OnStatusChange(listener func(isActive bool, file string)) {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	manager.statusListener = listener
+}
+
+func (manager *RecordingManagerCtx) statusChanged() {
+	if manager.statusListener != nil {
+		manager.statusListener(manager.started, manager.File())
+	}
+}
+
+func (manager *RecordingManagerCtx) List() ([]types.Recording, error) {
+	entries, err := os.ReadDir(manager.dir)
+	if os.IsNotExist(err) {
+		return []types.Recording{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	active := manager.File()
+
+	recordings := []types.Recording{}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !strings.HasPrefix(entry.Name(), recordingPrefix) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		recordings = append(recordings, types.Recording{
+			Name:    entry.Name(),
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+			Active:  entry.Name() == active,
+		})
+	}
+
+	// names contain time of the start
+	slices.SortFunc(recordings, func(a, b types.Recording) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return recordings, nil
+}
+
+func (manager *RecordingManagerCtx) Path(name string) (string, error) {
+	// name must not point outside of recordings directory
+	if name != filepath.Base(name) || !strings.HasPrefix(name, recordingPrefix) {
+		return "", types.ErrRecordingNotFound
+	}
+
+	path := filepath.Join(manager.dir, name)
+	if _, err := os.Stat(path); err != nil {
+		return "", types.ErrRecordingNotFound
+	}
+
+	return path, nil
+}
+
+// rotate starts a new file when the current one exceeds maximum duration or size,
+// file is replaced only between pipelines, so that every file is complete.
+func (manager *RecordingManagerCtx) rotate(stop chan struct{}) {
+	ticker := time.NewTicker(recordingRotateInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		if !manager.rotateFile(stop) {
+			return
+		}
+	}
+}
+
+// rotateFile replaces current file, if it should be rotated. It returns false, when
+// recording is not running anymore.
+func (manager *RecordingManagerCtx) rotateFile(stop chan struct{}) bool {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	// recording was stopped or started again meanwhile
+	if manager.stop != stop {
+		return false
+	}
+
+	if !manager.shouldRotate() {
+		return true
+	}
+
+	manager.destroyPipeline()
+	err := manager.createPipeline()
+	if err != nil {
+		manager.logger.Err(err).Msg("unable to rotate recording, stopping")
+
+		// rotation stops here, stop channel is not needed anymore
+		manager.stop = nil
+		manager.started = false
+	}
+
+	manager.retention()
+	manager.statusChanged()
+	return err == nil
+}
+
+// recreatePipeline continues started recording in a new file, e.g. after screen size change,
+// recording is stopped when it fails.
+func (manager *RecordingManagerCtx) recreatePipeline() {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	if !manager.started {
+		return
+	}
+
+	err := manager.createPipeline()
+	if errors.Is(err, types.ErrCapturePipelineAlreadyExists) {
+		return
+	}
+
+	if err != nil {
+		manager.logger.Err(err).Msg("unable to recreate recording pipeline, stopping")
+
+		if manager.stop != nil {
+			close(manager.stop)
+			manager.stop = nil
+		}
+		manager.started = false
+	}
+
+	manager.retention()
+	manager.statusChanged()
+}
+
+func (manager *RecordingManagerCtx) shouldRotate() bool {
+	manager.pipelineMu.Lock()
+	defer manager.pipelineMu.Unlock()
+
+	if manager.pipeline == nil {
+		return false
+	}
+
+	if manager.maxDuration > 0 && time.Since(manager.fileSince) >= manager.maxDuration {
+		return true
+	}
+
+	if manager.maxSize > 0 {
+		info, err := os.Stat(manager.file)
+		return err == nil && info.Size() >= manager.maxSize
+	}
+
+	return false
+}
+
+// retention deletes the oldest finished recordings exceeding maximum age or count.
+func (manager *RecordingManagerCtx) retention() {
+	if manager.maxFiles <= 0 && manager.maxAge <= 0 {
+		return
+	}
+
+	recordings, err := manager.List()
+	if err != nil {
+		manager.logger.Err(err).Msg("unable to list recordings")
+		return
+	}
+
+	for i, recording := range recordings {
+		if recording.Active {
+			continue
+		}
+
+		tooMany := manager.maxFiles > 0 && len(recordings)-i > manager.maxFiles
+		tooOld := manager.maxAge > 0 && time.Since(recording.ModTime) > manager.maxAge
+		if !tooMany && !tooOld {
+			continue
+		}
+
+		if err := os.Remove(filepath.Join(manager.dir, recording.Name)); err != nil {
+			manager.logger.Err(err).Str("file", recording.Name).Msg("unable to delete recording")
+			continue
+		}
+
+		manager.logger.Info().Str("file", recording.Name).Msg("recording deleted by retention policy")
+	}
+}
+
+func (manager *RecordingManagerCtx) createPipeline() error {
+	manager.pipelineMu.Lock()
+	defer manager.pipelineMu.Unlock()
+
+	if manager.pipeline != nil {
+		return types.ErrCapturePipelineAlreadyExists
+	}
+
+	name := recordingPrefix + time.Now().Format("20060102-150405")
+	file := filepath.Join(manager.dir, name+"."+manager.ext)
+	for i := 1; ; i++ {
+		if _, err := os.Stat(file); os.IsNotExist(err) {
+			break
+		}
+		file = filepath.Join(manager.dir, fmt.Sprintf("%s-%d.%s", name, i, manager.ext))
+	}
+
+	pipelineStr, err := manager.pipelineFn(file)
+	if err != nil {
+		return err
+	}
+
+	manager.logger.Info().
+		Str("file", file).
+		Str("src", pipelineStr).
+		Msgf("starting pipeline")
+
+	manager.pipeline, err = gst.CreatePipeline(pipelineStr)
+	if err != nil {
+		return err
+	}
+
+	manager.file = file
+	manager.fileSince = time.Now()
+
+	manager.pipeline.Play()
+	manager.pipelinesCounter.Inc()
+	manager.pipelinesActive.Set(1)
+
+	return nil
+}
+
+func (manager *RecordingManagerCtx) destroyPipeline() {
+	manager.pipelineMu.Lock()
+	defer manager.pipelineMu.Unlock()
+
+	if manager.pipeline == nil {
+		return
+	}
+
+	// file would be missing index or last fragment without end of stream
+	manager.pipeline.EndOfStream(recordingEOSTimeout)
+	manager.pipeline.Destroy()
+	manager.logger.Info().Str("file", manager.file).Msgf("destroying pipeline")
+	manager.pipeline = nil
+
+	manager.pipelinesActive.Set(0)
+}
+
+// gstQuote quotes property value in pipeline description, so that it can contain spaces
+func gstQuote(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
@@ -1,6 +1,8 @@
 package capture
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"sync"
 	"sync/atomic"
@@ -18,6 +20,12 @@ import (
 // timeout between intervals, when screencast pipeline is checked
 const screencastTimeout = 5 * time.Second
 
+// frame rate of screencast streams
+const (
+	screencastStreamDefaultRate = 1
+	screencastStreamMaxRate     = 10
+)
+
 type ScreencastManagerCtx struct {
 	logger zerolog.Logger
 	mu     sync.Mutex
@@ -31,6 +39,9 @@ type ScreencastManagerCtx struct {
 	imageMu    sync.Mutex
 	tickerStop chan struct{}
 
+	// restarts stalled or failed pipeline
+	watchdog *watchdog
+
 	enabled bool
 	started bool
 	expired int32
@@ -106,9 +117,17 @@ func screencastNew(enabled bool, pipelineStr string) *ScreencastManagerCtx {
 	return manager
 }
 
+// enableWatchdog restarts pipeline, when it does not create images for the timeout or when it fails.
+func (manager *ScreencastManagerCtx) enableWatchdog(timeout time.Duration) {
+	manager.watchdog = watchdogNew(timeout, manager.Started, func(err error) {
+		manager.restartPipeline(nil, err)
+	})
+}
+
 func (manager *ScreencastManagerCtx) shutdown() {
 	manager.logger.Info().Msgf("shutdown")
 
+	manager.watchdog.shutdown()
 	manager.destroyPipeline()
 
 	close(manager.tickerStop)
@@ -147,6 +166,55 @@ func (manager *ScreencastManagerCtx) Image() ([]byte, error) {
 	return manager.image.Data, nil
 }
 
+func (manager *ScreencastManagerCtx) Stream(ctx context.Context, rate float64) (<-chan []byte, error) {
+	if rate <= 0 {
+		rate = screencastStreamDefaultRate
+	}
+	rate = min(rate, screencastStreamMaxRate)
+
+	// first image returns error, when pipeline cannot be started
+	image, err := manager.Image()
+	if err != nil {
+		return nil, err
+	}
+
+	images := make(chan []byte)
+	go func() {
+		defer close(images)
+
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / rate))
+		defer ticker.Stop()
+
+		var last []byte
+		for {
+			// unchanged screen is not sent again
+			if !bytes.Equal(image, last) {
+				select {
+				case <-ctx.Done():
+					return
+				case images <- image:
+					last = image
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			// requesting images keeps the pipeline running
+			image, err = manager.Image()
+			if err != nil {
+				manager.logger.Warn().Err(err).Msg("stopping stream, unable to get image")
+				return
+			}
+		}
+	}()
+
+	return images, nil
+}
+
 func (manager *ScreencastManagerCtx) start() error {
 	manager.mu.Lock()
 	defer manager.mu.Unlock()
@@ -191,6 +259,14 @@ func (manager *ScreencastManagerCtx) createPipeline() error {
 		return err
 	}
 
+	if manager.watchdog != nil {
+		pipeline := manager.pipeline
+		pipeline.OnError(func(err error) {
+			manager.restartPipeline(pipeline, err)
+		})
+	}
+
+	manager.watchdog.touch()
 	manager.pipeline.AttachAppsink("appsink")
 	manager.pipeline.Play()
 	manager.pipelinesCounter.Inc()
@@ -226,7 +302,37 @@ func (manager *ScreencastManagerCtx) createPipeline() error {
 	return nil
 }
 
+// restartPipeline replaces running pipeline with a new one. When failed pipeline
+// is set, it is restarted only if it is still running.
+func (manager *ScreencastManagerCtx) restartPipeline(failed gst.Pipeline, reason error) {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	if !manager.started {
+		return
+	}
+
+	if failed != nil {
+		manager.pipelineMu.Lock()
+		running := manager.pipeline == failed
+		manager.pipelineMu.Unlock()
+
+		if !running {
+			return
+		}
+	}
+
+	manager.logger.Warn().Err(reason).Msg("restarting pipeline")
+
+	manager.destroyPipeline()
+	if err := manager.createPipeline(); err != nil {
+		manager.logger.Err(err).Msg("unable to restart pipeline")
+	}
+}
+
 func (manager *ScreencastManagerCtx) setImage(image types.Sample) {
+	manager.watchdog.touch()
+
 	manager.imageMu.Lock()
 	manager.image = image
 	manager.imageMu.Unlock()
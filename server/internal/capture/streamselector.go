@@ -16,9 +16,11 @@ type StreamSelectorManagerCtx struct {
 	codec     codec.RTPCodec
 	streams   map[string]types.StreamSinkManager
 	streamIDs []string
+	// shared pipeline of some streams, if any
+	tee *StreamTeeManagerCtx
 }
 
-func streamSelectorNew(codec codec.RTPCodec, streams map[string]types.StreamSinkManager, streamIDs []string) *StreamSelectorManagerCtx {
+func streamSelectorNew(codec codec.RTPCodec, streams map[string]types.StreamSinkManager, streamIDs []string, tee *StreamTeeManagerCtx) *StreamSelectorManagerCtx {
 	logger := log.With().
 		Str("module", "capture").
 		Str("submodule", "stream-selector").
@@ -29,15 +31,49 @@ func streamSelectorNew(codec codec.RTPCodec, streams map[string]types.StreamSink
 		codec:     codec,
 		streams:   streams,
 		streamIDs: streamIDs,
+		tee:       tee,
 	}
 }
 
 func (manager *StreamSelectorManagerCtx) shutdown() {
 	manager.logger.Info().Msgf("shutdown")
 
+	// watchdogs would restart destroyed pipelines
+	for _, stream := range manager.streams {
+		if sink, ok := stream.(*StreamSinkManagerCtx); ok {
+			sink.watchdog.shutdown()
+		}
+	}
+	if manager.tee != nil {
+		manager.tee.shutdown()
+	}
+
 	manager.destroyPipelines()
 }
 
+// suspendWatchdogs prevents restarts of destroyed pipelines, until they are recreated
+func (manager *StreamSelectorManagerCtx) suspendWatchdogs() {
+	for _, stream := range manager.streams {
+		if sink, ok := stream.(*StreamSinkManagerCtx); ok {
+			sink.watchdog.suspend()
+		}
+	}
+	if manager.tee != nil {
+		manager.tee.watchdog.suspend()
+	}
+}
+
+func (manager *StreamSelectorManagerCtx) resumeWatchdogs() {
+	for _, stream := range manager.streams {
+		if sink, ok := stream.(*StreamSinkManagerCtx); ok {
+			sink.watchdog.resume()
+		}
+	}
+	if manager.tee != nil {
+		manager.tee.watchdog.resume()
+	}
+}
+
 func (manager *StreamSelectorManagerCtx) destroyPipelines() {
 	for _, stream := range manager.streams {
 		if stream.Started() {
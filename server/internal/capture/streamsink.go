@@ -19,6 +19,14 @@ import (
 
 var moveSinkListenerMu = sync.Mutex{}
 
+// sinkPipeline emits samples for a single stream sink, it is either
+// a whole gstreamer pipeline or a branch of shared pipeline
+type sinkPipeline interface {
+	Sample() chan types.Sample
+	EmitVideoKeyframe() bool
+	Destroy()
+}
+
 type StreamSinkManagerCtx struct {
 	id string
 
@@ -33,9 +41,13 @@ type StreamSinkManagerCtx struct {
 	wg     sync.WaitGroup
 
 	codec      codec.RTPCodec
-	pipeline   gst.Pipeline
+	pipeline   sinkPipeline
 	pipelineMu sync.Mutex
 	pipelineFn func() (string, error)
+	// when set, branch of shared pipeline is used instead of pipelineFn
+	tee *StreamTeeManagerCtx
+	// restarts stalled or failed pipeline
+	watchdog *watchdog
 
 	listeners   map[uintptr]types.SampleListener
 	listenersKf map[uintptr]types.SampleListener // keyframe lobby
@@ -122,6 +134,24 @@ func streamSinkNew(codec codec.RTPCodec, pipelineFn func() (string, error), id s
 	return manager
 }
 
+func streamSinkTeeNew(codec codec.RTPCodec, tee *StreamTeeManagerCtx, id string) *StreamSinkManagerCtx {
+	manager := streamSinkNew(codec, nil, id)
+	manager.tee = tee
+	return manager
+}
+
+// enableWatchdog restarts pipeline, when it does not emit samples for the timeout or when it fails.
+// Branches of shared pipeline are watched by the shared pipeline itself.
+func (manager *StreamSinkManagerCtx) enableWatchdog(timeout time.Duration) {
+	if manager.tee != nil {
+		return
+	}
+
+	manager.watchdog = watchdogNew(timeout, manager.Started, func(err error) {
+		manager.restartPipeline(nil, err)
+	})
+}
+
 func (manager *StreamSinkManagerCtx) shutdown() {
 	manager.logger.Info().Msgf("shutdown")
 
@@ -134,6 +164,7 @@ func (manager *StreamSinkManagerCtx) shutdown() {
 	}
 	manager.listenersMu.Unlock()
 
+	manager.watchdog.shutdown()
 	manager.DestroyPipeline()
 	manager.wg.Wait()
 }
@@ -307,23 +338,21 @@ func (manager *StreamSinkManagerCtx) CreatePipeline() error {
 		return types.ErrCapturePipelineAlreadyExists
 	}
 
-	pipelineStr, err := manager.pipelineFn()
-	if err != nil {
-		return err
-	}
-
-	manager.logger.Info().
-		Str("codec", manager.codec.Name).
-		Str("src", pipelineStr).
-		Msgf("creating pipeline")
+	var err error
+	if manager.tee != nil {
+		manager.logger.Info().
+			Str("codec", manager.codec.Name).
+			Msgf("attaching to shared pipeline")
 
-	manager.pipeline, err = gst.CreatePipeline(pipelineStr)
+		manager.pipeline, err = manager.tee.attach(manager.id)
+	} else {
+		manager.pipeline, err = manager.createPipeline()
+	}
 	if err != nil {
 		return err
 	}
 
-	manager.pipeline.AttachAppsink("appsink")
-	manager.pipeline.Play()
+	manager.watchdog.touch()
 
 	pipeline := manager.pipeline
 	manager.wg.Go(func() {
@@ -346,6 +375,78 @@ func (manager *StreamSinkManagerCtx) CreatePipeline() error {
 	return nil
 }
 
+func (manager *StreamSinkManagerCtx) createPipeline() (sinkPipeline, error) {
+	pipelineStr, err := manager.pipelineFn()
+	if err != nil {
+		return nil, err
+	}
+
+	manager.logger.Info().
+		Str("codec", manager.codec.Name).
+		Str("src", pipelineStr).
+		Msgf("creating pipeline")
+
+	pipeline, err := gst.CreatePipeline(pipelineStr)
+	if err != nil {
+		return nil, err
+	}
+
+	if manager.watchdog != nil {
+		pipeline.OnError(func(err error) {
+			manager.restartPipeline(pipeline, err)
+		})
+	}
+
+	pipeline.AttachAppsink("appsink")
+	pipeline.Play()
+
+	return pipeline, nil
+}
+
+// restartPipeline replaces running pipeline with a new one, listeners are kept and
+// wait for a keyframe of the new pipeline. When failed pipeline is set, it is restarted
+// only if it is still running.
+func (manager *StreamSinkManagerCtx) restartPipeline(failed sinkPipeline, reason error) {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	if !manager.Started() {
+		return
+	}
+
+	if failed != nil {
+		manager.pipelineMu.Lock()
+		running := manager.pipeline == failed
+		manager.pipelineMu.Unlock()
+
+		if !running {
+			return
+		}
+	}
+
+	manager.logger.Warn().Err(reason).Msg("restarting pipeline")
+
+	manager.DestroyPipeline()
+	if err := manager.CreatePipeline(); err != nil {
+		manager.logger.Err(err).Msg("unable to restart pipeline")
+		return
+	}
+
+	if !manager.waitForKf {
+		return
+	}
+
+	// samples of the new pipeline can be decoded only after its keyframe
+	manager.listenersMu.Lock()
+	for k, v := range manager.listeners {
+		manager.listenersKf[k] = v
+	}
+	manager.listeners = make(map[uintptr]types.SampleListener)
+	manager.listenersMu.Unlock()
+
+	manager.pipeline.EmitVideoKeyframe()
+}
+
 func (manager *StreamSinkManagerCtx) saveSampleBitrate(timestamp time.Time, delta float64) {
 	// get unix timestamp in seconds
 	sec := timestamp.Unix()
@@ -368,6 +469,8 @@ func (manager *StreamSinkManagerCtx) saveSampleBitrate(timestamp time.Time, delt
 }
 
 func (manager *StreamSinkManagerCtx) onSample(sample types.Sample) {
+	manager.watchdog.touch()
+
 	manager.listenersMu.Lock()
 
 	// save to metrics
@@ -397,6 +500,17 @@ func (manager *StreamSinkManagerCtx) onSample(sample types.Sample) {
 	}
 }
 
+func (manager *StreamSinkManagerCtx) emitVideoKeyframe() bool {
+	manager.pipelineMu.Lock()
+	defer manager.pipelineMu.Unlock()
+
+	if manager.pipeline == nil {
+		return false
+	}
+
+	return manager.pipeline.EmitVideoKeyframe()
+}
+
 func (manager *StreamSinkManagerCtx) DestroyPipeline() {
 	manager.pipelineMu.Lock()
 	defer manager.pipelineMu.Unlock()
@@ -2,7 +2,11 @@ package capture
 
 import (
 	"errors"
+	"fmt"
+	"slices"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
@@ -24,12 +28,32 @@ type StreamSrcManagerCtx struct {
 	pipelineMu  sync.Mutex
 	pipelineStr string
 
+	// mixing of multiple inputs, mixer pipeline is recreated when inputs change or when
+	// it fails, so that all participants hear a short gap when someone joins or leaves
+	mixerStr       string
+	inputPipelines map[string]string // codec -> input pipeline
+	inputs         map[string]*streamSrcInput
+	mixer          gst.Pipeline
+	mixerCodecs    []string
+	// muted inputs, kept even when input is removed, so that it is muted when added again
+	muted map[string]bool
+
 	// metrics
 	pushedData       map[string]prometheus.Summary
 	pipelinesCounter map[string]prometheus.Counter
 	pipelinesActive  map[string]prometheus.Gauge
 }
 
+type streamSrcInput struct {
+	name    string
+	codec   codec.RTPCodec
+	level   float64
+	levelAt time.Time
+}
+
+// level that was not updated for this long is considered silence
+const streamSrcLevelTimeout = time.Second
+
 func streamSrcNew(enabled bool, codecPipeline map[string]string, video_id string) *StreamSrcManagerCtx {
 	logger := log.With().
 		Str("module", "capture").
@@ -90,6 +114,8 @@ func streamSrcNew(enabled bool, codecPipeline map[string]string, video_id string
 		logger:        logger,
 		enabled:       enabled,
 		codecPipeline: codecPipeline,
+		inputs:        map[string]*streamSrcInput{},
+		muted:         map[string]bool{},
 
 		// metrics
 		pushedData:       pushedData,
@@ -98,10 +124,23 @@ func streamSrcNew(enabled bool, codecPipeline map[string]string, video_id string
 	}
 }
 
+// enableMixing sets pipeline of the audiomixer named mixer and pipelines of its inputs, where
+// {name} is replaced with unique suffix of appsrc and volume element names and {mute} with
+// muted state of the input.
+func (manager *StreamSrcManagerCtx) enableMixing(mixerStr string, inputPipelines map[string]string) {
+	manager.mixerStr = mixerStr
+	manager.inputPipelines = inputPipelines
+}
+
 func (manager *StreamSrcManagerCtx) shutdown() {
 	manager.logger.Info().Msgf("shutdown")
 
 	manager.Stop()
+
+	manager.pipelineMu.Lock()
+	manager.inputs = map[string]*streamSrcInput{}
+	manager.destroyMixer()
+	manager.pipelineMu.Unlock()
 }
 
 func (manager *StreamSrcManagerCtx) Codec() codec.RTPCodec {
@@ -123,6 +162,10 @@ func (manager *StreamSrcManagerCtx) Start(codec codec.RTPCodec) error {
 		return errors.New("stream-src not enabled")
 	}
 
+	if manager.Mixing() {
+		return errors.New("stream-src uses mixing, add input instead")
+	}
+
 	found := false
 	for codecName, pipeline := range manager.codecPipeline {
 		if codecName == codec.Name {
@@ -195,3 +238,216 @@ func (manager *StreamSrcManagerCtx) Started() bool {
 
 	return manager.pipeline != nil
 }
+
+func (manager *StreamSrcManagerCtx) Mixing() bool {
+	return manager.mixerStr != ""
+}
+
+func (manager *StreamSrcManagerCtx) AddInput(id string, codec codec.RTPCodec) error {
+	manager.pipelineMu.Lock()
+	defer manager.pipelineMu.Unlock()
+
+	if !manager.enabled {
+		return errors.New("stream-src not enabled")
+	}
+
+	if !manager.Mixing() {
+		return errors.New("stream-src does not use mixing")
+	}
+
+	if _, ok := manager.inputPipelines[codec.Name]; !ok {
+		return errors.New("no input pipeline found for a codec")
+	}
+
+	input, ok := manager.inputs[id]
+	if !ok {
+		input = &streamSrcInput{}
+		manager.inputs[id] = input
+	}
+	input.codec = codec
+
+	return manager.createMixer()
+}
+
+func (manager *StreamSrcManagerCtx) RemoveInput(id string) {
+	manager.pipelineMu.Lock()
+	defer manager.pipelineMu.Unlock()
+
+	if _, ok := manager.inputs[id]; !ok {
+		return
+	}
+
+	delete(manager.inputs, id)
+
+	if err := manager.createMixer(); err != nil {
+		manager.logger.Err(err).Msg("unable to recreate mixer pipeline")
+	}
+}
+
+func (manager *StreamSrcManagerCtx) PushInput(id string, bytes []byte) {
+	manager.pipelineMu.Lock()
+	defer manager.pipelineMu.Unlock()
+
+	input, ok := manager.inputs[id]
+	if !ok || manager.mixer == nil {
+		return
+	}
+
+	manager.mixer.PushTo("appsrc"+input.name, bytes)
+	manager.pushedData[input.codec.Name].Observe(float64(len(bytes)))
+}
+
+func (manager *StreamSrcManagerCtx) SetInputMuted(id string, muted bool) error {
+	manager.pipelineMu.Lock()
+	defer manager.pipelineMu.Unlock()
+
+	input, ok := manager.inputs[id]
+	if !ok {
+		return types.ErrStreamSrcInputNotFound
+	}
+
+	if muted {
+		manager.muted[id] = true
+	} else {
+		delete(manager.muted, id)
+	}
+
+	if manager.mixer != nil {
+		value := 0
+		if muted {
+			value = 1
+		}
+		manager.mixer.SetPropInt("volume"+input.name, "mute", value)
+	}
+
+	return nil
+}
+
+func (manager *StreamSrcManagerCtx) SetInputLevel(id string, level float64) {
+	manager.pipelineMu.Lock()
+	defer manager.pipelineMu.Unlock()
+
+	input, ok := manager.inputs[id]
+	if !ok {
+		return
+	}
+
+	input.level = level
+	input.levelAt = time.Now()
+}
+
+func (manager *StreamSrcManagerCtx) Inputs() []types.StreamSrcInput {
+	manager.pipelineMu.Lock()
+	defer manager.pipelineMu.Unlock()
+
+	inputs := make([]types.StreamSrcInput, 0, len(manager.inputs))
+	for id, input := range manager.inputs {
+		level := input.level
+		if time.Since(input.levelAt) > streamSrcLevelTimeout {
+			level = 0
+		}
+
+		inputs = append(inputs, types.StreamSrcInput{
+			ID:    id,
+			Muted: manager.muted[id],
+			Level: level,
+		})
+	}
+
+	slices.SortFunc(inputs, func(a, b types.StreamSrcInput) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+
+	return inputs
+}
+
+// createMixer replaces current mixer pipeline with a new one containing all inputs,
+// gstreamer elements cannot be easily added to a running pipeline described by a string.
+func (manager *StreamSrcManagerCtx) createMixer() error {
+	manager.destroyMixer()
+
+	if len(manager.inputs) == 0 {
+		return nil
+	}
+
+	ids := make([]string, 0, len(manager.inputs))
+	for id := range manager.inputs {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	pipelineStr := manager.mixerStr
+	for i, id := range ids {
+		input := manager.inputs[id]
+		input.name = fmt.Sprintf("_input%d", i)
+
+		pipelineStr += " " + strings.NewReplacer(
+			"{name}", input.name,
+			"{mute}", fmt.Sprintf("%t", manager.muted[id]),
+		).Replace(manager.inputPipelines[input.codec.Name])
+	}
+
+	manager.logger.Info().
+		Strs("inputs", ids).
+		Str("src", pipelineStr).
+		Msgf("creating mixer pipeline")
+
+	mixer, err := gst.CreatePipeline(pipelineStr)
+	if err != nil {
+		return err
+	}
+
+	// failed input would silence everyone, so that the mixer is recreated
+	mixer.OnError(func(err error) {
+		manager.restartMixer(mixer, err)
+	})
+
+	manager.mixer = mixer
+	manager.mixer.Play()
+
+	// mixer is counted as a pipeline of every codec it contains
+	manager.mixerCodecs = manager.mixerCodecs[:0]
+	for _, input := range manager.inputs {
+		if !slices.Contains(manager.mixerCodecs, input.codec.Name) {
+			manager.mixerCodecs = append(manager.mixerCodecs, input.codec.Name)
+		}
+	}
+
+	for _, codecName := range manager.mixerCodecs {
+		manager.pipelinesCounter[codecName].Inc()
+		manager.pipelinesActive[codecName].Set(1)
+	}
+
+	return nil
+}
+
+// restartMixer recreates failed mixer pipeline, if it was not replaced meanwhile.
+func (manager *StreamSrcManagerCtx) restartMixer(failed gst.Pipeline, reason error) {
+	manager.pipelineMu.Lock()
+	defer manager.pipelineMu.Unlock()
+
+	if manager.mixer != failed {
+		return
+	}
+
+	manager.logger.Warn().Err(reason).Msg("restarting mixer pipeline")
+
+	if err := manager.createMixer(); err != nil {
+		manager.logger.Err(err).Msg("unable to restart mixer pipeline")
+	}
+}
+
+func (manager *StreamSrcManagerCtx) destroyMixer() {
+	if manager.mixer == nil {
+		return
+	}
+
+	manager.mixer.Destroy()
+	manager.mixer = nil
+
+	for _, codecName := range manager.mixerCodecs {
+		manager.pipelinesActive[codecName].Set(0)
+	}
+
+	manager.logger.Info().Msgf("destroying mixer pipeline")
+}
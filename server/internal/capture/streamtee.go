@@ -0,0 +1,352 @@
+package capture
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+
+	"github.com/m1k1o/neko/server/pkg/gst"
+	"github.com/m1k1o/neko/server/pkg/types"
+	"github.com/m1k1o/neko/server/pkg/types/codec"
+)
+
+// names of elements in branch of shared pipeline
+func teeAppsinkName(id string) string { return "appsink_" + id }
+func teeValveName(id string) string   { return "valve_" + id }
+
+// StreamTeeManagerCtx runs single pipeline, that captures the screen once and splits
+// it using tee to multiple encoders. Every branch is closed by a valve, that is opened
+// only when its stream sink is started, so that idle encoders do not use any CPU.
+type StreamTeeManagerCtx struct {
+	logger zerolog.Logger
+	mu     sync.Mutex
+
+	ids        []string
+	pipeline   gst.Pipeline
+	pipelineFn func() (string, error)
+
+	branches   map[string]*streamTeeBranch
+	branchesMu sync.Mutex
+
+	// restarts stalled or failed pipeline
+	watchdog *watchdog
+
+	// metrics
+	pipelinesCounter prometheus.Counter
+	pipelinesActive  prometheus.Gauge
+}
+
+func streamTeeNew(codec codec.RTPCodec, pipelineFn func() (string, error), ids []string) *StreamTeeManagerCtx {
+	logger := log.With().
+		Str("module", "capture").
+		Str("submodule", "stream-tee").
+		Logger()
+
+	return &StreamTeeManagerCtx{
+		logger:     logger,
+		ids:        ids,
+		pipelineFn: pipelineFn,
+		branches:   map[string]*streamTeeBranch{},
+
+		// metrics
+		pipelinesCounter: promauto.NewCounter(prometheus.CounterOpts{
+			Name:      "pipelines_total",
+			Namespace: "neko",
+			Subsystem: "capture",
+			Help:      "Total number of created pipelines.",
+			ConstLabels: map[string]string{
+				"submodule":  "streamtee",
+				"video_id":   "shared",
+				"codec_name": codec.Name,
+				"codec_type": codec.Type.String(),
+			},
+		}),
+		pipelinesActive: promauto.NewGauge(prometheus.GaugeOpts{
+			Name:      "pipelines_active",
+			Namespace: "neko",
+			Subsystem: "capture",
+			Help:      "Total number of active pipelines.",
+			ConstLabels: map[string]string{
+				"submodule":  "streamtee",
+				"video_id":   "shared",
+				"codec_name": codec.Name,
+				"codec_type": codec.Type.String(),
+			},
+		}),
+	}
+}
+
+// enableWatchdog restarts shared pipeline, when no open branch receives samples for the timeout or when it fails.
+func (manager *StreamTeeManagerCtx) enableWatchdog(timeout time.Duration) {
+	manager.watchdog = watchdogNew(timeout, func() bool {
+		manager.branchesMu.Lock()
+		defer manager.branchesMu.Unlock()
+
+		return len(manager.branches) > 0
+	}, func(err error) {
+		manager.restartPipeline(nil, err)
+	})
+}
+
+func (manager *StreamTeeManagerCtx) shutdown() {
+	manager.watchdog.shutdown()
+}
+
+// attach opens branch of the shared pipeline, and creates the pipeline if it is not running.
+func (manager *StreamTeeManagerCtx) attach(id string) (*streamTeeBranch, error) {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	manager.branchesMu.Lock()
+	_, ok := manager.branches[id]
+	manager.branchesMu.Unlock()
+
+	if ok {
+		return nil, types.ErrCapturePipelineAlreadyExists
+	}
+
+	created := false
+	if manager.pipeline == nil {
+		if err := manager.createPipeline(); err != nil {
+			return nil, err
+		}
+		created = true
+	}
+
+	branch := &streamTeeBranch{
+		manager: manager,
+		id:      id,
+		sample:  make(chan types.Sample, 4),
+	}
+
+	manager.branchesMu.Lock()
+	manager.branches[id] = branch
+	manager.branchesMu.Unlock()
+
+	// other branches might be attached, if the pipeline failed to restart
+	if created {
+		manager.openBranches()
+	} else {
+		manager.logger.Info().Str("video_id", id).Msg("opening branch")
+		manager.pipeline.SetPropInt(teeValveName(id), "drop", 0)
+	}
+
+	return branch, nil
+}
+
+// openBranches opens valves of all attached branches in a new pipeline.
+func (manager *StreamTeeManagerCtx) openBranches() {
+	manager.branchesMu.Lock()
+	defer manager.branchesMu.Unlock()
+
+	for id := range manager.branches {
+		manager.logger.Info().Str("video_id", id).Msg("opening branch")
+		manager.pipeline.SetPropInt(teeValveName(id), "drop", 0)
+	}
+}
+
+// detach closes branch of the shared pipeline, and destroys the pipeline if it was the last one.
+func (manager *StreamTeeManagerCtx) detach(id string) {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	manager.branchesMu.Lock()
+	branch, ok := manager.branches[id]
+	if ok {
+		delete(manager.branches, id)
+		branch.close()
+	}
+	remaining := len(manager.branches)
+	manager.branchesMu.Unlock()
+
+	if !ok {
+		return
+	}
+
+	if remaining == 0 {
+		manager.destroyPipeline()
+		return
+	}
+
+	// pipeline might have failed to restart
+	if manager.pipeline == nil {
+		return
+	}
+
+	manager.logger.Info().Str("video_id", id).Msg("closing branch")
+	manager.pipeline.SetPropInt(teeValveName(id), "drop", 1)
+}
+
+func (manager *StreamTeeManagerCtx) emitVideoKeyframe() bool {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	if manager.pipeline == nil {
+		return false
+	}
+
+	// keyframe is requested from all open branches
+	return manager.pipeline.EmitVideoKeyframe()
+}
+
+func (manager *StreamTeeManagerCtx) createPipeline() error {
+	pipelineStr, err := manager.pipelineFn()
+	if err != nil {
+		return err
+	}
+
+	manager.logger.Info().
+		Str("src", pipelineStr).
+		Msgf("creating pipeline")
+
+	pipeline, err := gst.CreatePipeline(pipelineStr)
+	if err != nil {
+		return err
+	}
+
+	if manager.watchdog != nil {
+		pipeline.OnError(func(err error) {
+			manager.restartPipeline(pipeline, err)
+		})
+	}
+
+	manager.pipeline = pipeline
+	manager.watchdog.touch()
+
+	for _, id := range manager.ids {
+		samples := manager.pipeline.AttachAppsinkChannel(teeAppsinkName(id))
+		go manager.forwardSamples(id, samples)
+	}
+
+	manager.pipeline.Play()
+
+	manager.pipelinesCounter.Inc()
+	manager.pipelinesActive.Set(1)
+
+	return nil
+}
+
+// forwardSamples sends samples from pipeline to the currently attached branch, until the pipeline is destroyed
+func (manager *StreamTeeManagerCtx) forwardSamples(id string, samples chan types.Sample) {
+	for sample := range samples {
+		manager.branchesMu.Lock()
+		branch, ok := manager.branches[id]
+		manager.branchesMu.Unlock()
+
+		if ok {
+			// pipeline is alive, even if the sample is dropped
+			manager.watchdog.touch()
+			branch.send(sample)
+		}
+	}
+}
+
+// restartPipeline replaces running pipeline with a new one and opens all attached branches
+// again, stream sinks keep receiving samples from the same branches. When failed pipeline
+// is set, it is restarted only if it is still running.
+func (manager *StreamTeeManagerCtx) restartPipeline(failed gst.Pipeline, reason error) {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	if failed != nil && manager.pipeline != failed {
+		return
+	}
+
+	manager.branchesMu.Lock()
+	attached := len(manager.branches)
+	manager.branchesMu.Unlock()
+
+	if attached == 0 {
+		return
+	}
+
+	manager.logger.Warn().Err(reason).Msg("restarting pipeline")
+
+	manager.destroyPipeline()
+	if err := manager.createPipeline(); err != nil {
+		manager.logger.Err(err).Msg("unable to restart pipeline")
+		return
+	}
+
+	manager.openBranches()
+	manager.pipeline.EmitVideoKeyframe()
+}
+
+func (manager *StreamTeeManagerCtx) destroyPipeline() {
+	if manager.pipeline == nil {
+		return
+	}
+
+	manager.pipeline.Destroy()
+	manager.logger.Info().Msgf("destroying pipeline")
+	manager.pipeline = nil
+
+	manager.pipelinesActive.Set(0)
+}
+
+// streamTeeBranch is the part of shared pipeline, that belongs to a single stream sink
+type streamTeeBranch struct {
+	manager *StreamTeeManagerCtx
+	id      string
+	sample  chan types.Sample
+	// sample channel is closed only when no sample is being sent to it
+	mu     sync.Mutex
+	closed bool
+	// samples depending on a dropped one are skipped until the next keyframe
+	skipping bool
+}
+
+// send does not block, so that a slow stream sink does not stall the pipeline
+// and other branches. When the channel is full, sample is dropped and following
+// samples are skipped until the next keyframe, that is requested immediately.
+func (branch *streamTeeBranch) send(sample types.Sample) {
+	branch.mu.Lock()
+	defer branch.mu.Unlock()
+
+	if branch.closed {
+		return
+	}
+
+	if branch.skipping {
+		if sample.DeltaUnit {
+			return
+		}
+		branch.skipping = false
+	}
+
+	select {
+	case branch.sample <- sample:
+	default:
+		branch.manager.logger.Debug().Str("video_id", branch.id).Msg("branch is full, skipping samples until keyframe")
+		branch.skipping = true
+		// pipeline lock might be held by restart waiting for samples to be forwarded
+		go branch.manager.emitVideoKeyframe()
+	}
+}
+
+func (branch *streamTeeBranch) close() {
+	branch.mu.Lock()
+	defer branch.mu.Unlock()
+
+	if !branch.closed {
+		branch.closed = true
+		close(branch.sample)
+	}
+}
+
+func (branch *streamTeeBranch) Sample() chan types.Sample {
+	return branch.sample
+}
+
+func (branch *streamTeeBranch) EmitVideoKeyframe() bool {
+	return branch.manager.emitVideoKeyframe()
+}
+
+func (branch *streamTeeBranch) Destroy() {
+	branch.manager.detach(branch.id)
+}
@@ -0,0 +1,118 @@
+package capture
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// how often at most is checked whether the pipeline stalled
+const watchdogMaxInterval = time.Second
+
+// watchdog restarts pipeline, that did not emit any sample for longer than timeout
+type watchdog struct {
+	wg      sync.WaitGroup
+	timeout time.Duration
+	stop    chan struct{}
+
+	// pipeline is expected to emit samples only when active
+	active  func() bool
+	restart func(err error)
+
+	lastSample atomic.Int64
+
+	// restart is not called while suspended, e.g. during screen size change
+	mu        sync.Mutex
+	suspended bool
+}
+
+// watchdogNew returns nil, when timeout is not set, all methods can be called on nil watchdog.
+func watchdogNew(timeout time.Duration, active func() bool, restart func(err error)) *watchdog {
+	if timeout <= 0 {
+		return nil
+	}
+
+	w := &watchdog{
+		timeout: timeout,
+		stop:    make(chan struct{}),
+		active:  active,
+		restart: restart,
+	}
+
+	w.touch()
+	w.wg.Go(func() {
+		ticker := time.NewTicker(min(timeout/2, watchdogMaxInterval))
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-w.stop:
+				return
+			case <-ticker.C:
+				w.check()
+			}
+		}
+	})
+
+	return w
+}
+
+func (w *watchdog) check() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.suspended || !w.active() {
+		w.touch()
+		return
+	}
+
+	since := time.Since(time.Unix(0, w.lastSample.Load()))
+	if since > w.timeout {
+		w.restart(fmt.Errorf("no samples received for %s", since.Truncate(time.Millisecond)))
+		// new pipeline gets the whole timeout to emit its first sample
+		w.touch()
+	}
+}
+
+// suspend waits for running restart to finish and prevents new ones until resumed
+func (w *watchdog) suspend() {
+	if w == nil {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.suspended = true
+}
+
+func (w *watchdog) resume() {
+	if w == nil {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.suspended = false
+	w.touch()
+}
+
+// touch is called on every sample, and when the pipeline is created
+func (w *watchdog) touch() {
+	if w == nil {
+		return
+	}
+
+	w.lastSample.Store(time.Now().UnixNano())
+}
+
+func (w *watchdog) shutdown() {
+	if w == nil {
+		return
+	}
+
+	close(w.stop)
+	w.wg.Wait()
+}
@@ -2,7 +2,9 @@ package config
 
 import (
 	"os"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/pion/webrtc/v4"
 	"github.com/rs/zerolog/log"
@@ -25,12 +27,20 @@ const (
 	HwEncNVENC
 )
 
+// VideoCodecConfig holds pipelines of an additional video codec, for the same video ids
+type VideoCodecConfig struct {
+	Codec     codec.RTPCodec
+	Pipelines map[string]types.VideoConfig
+}
+
 type Capture struct {
 	Display string
 
 	VideoCodec     codec.RTPCodec
 	VideoIDs       []string
 	VideoPipelines map[string]types.VideoConfig
+	VideoShared    bool
+	VideoCodecs    []VideoCodecConfig
 
 	AudioDevice   string
 	AudioCodec    codec.RTPCodec
@@ -43,11 +53,27 @@ type Capture struct {
 	BroadcastUrl          string
 	BroadcastAutostart    bool
 
+	RecordingDir         string
+	RecordingFormat      string
+	RecordingPipeline    string
+	RecordingMaxDuration time.Duration
+	RecordingMaxSize     int
+	RecordingMaxFiles    int
+	RecordingMaxAge      time.Duration
+
 	ScreencastEnabled  bool
 	ScreencastRate     string
 	ScreencastQuality  string
 	ScreencastPipeline string
 
+	HLSEnabled          bool
+	HLSSegmentDuration  time.Duration
+	HLSPlaylistLength   int
+	HLSPipeline         string
+	HLSMaxWebRTCViewers int
+
+	WatchdogTimeout time.Duration
+
 	WebcamEnabled bool
 	WebcamDevice  string
 	WebcamWidth   int
@@ -55,6 +81,7 @@ type Capture struct {
 
 	MicrophoneEnabled bool
 	MicrophoneDevice  string
+	MicrophoneMixing  bool
 }
 
 func (Capture) Init(cmd *cobra.Command) error {
@@ -100,6 +127,16 @@ func (Capture) Init(cmd *cobra.Command) error {
 		return err
 	}
 
+	cmd.PersistentFlags().String("capture.video.codecs", "{}", "additional video codecs with pipelines for the same video ids, chosen per peer based on codecs supported by the client")
+	if err := viper.BindPFlag("capture.video.codecs", cmd.PersistentFlags().Lookup("capture.video.codecs")); err != nil {
+		return err
+	}
+
+	cmd.PersistentFlags().Bool("capture.video.shared", false, "capture screen only once and feed it to encoders of all video pipelines")
+	if err := viper.BindPFlag("capture.video.shared", cmd.PersistentFlags().Lookup("capture.video.shared")); err != nil {
+		return err
+	}
+
 	// broadcast
 	cmd.PersistentFlags().Int("capture.broadcast.audio_bitrate", 128, "broadcast audio bitrate in KB/s")
 	if err := viper.BindPFlag("capture.broadcast.audio_bitrate", cmd.PersistentFlags().Lookup("capture.broadcast.audio_bitrate")); err != nil {
@@ -131,6 +168,42 @@ func (Capture) Init(cmd *cobra.Command) error {
 		return err
 	}
 
+	// recording
+	cmd.PersistentFlags().String("capture.recording.dir", "/home/neko/recordings", "directory where recordings are stored")
+	if err := viper.BindPFlag("capture.recording.dir", cmd.PersistentFlags().Lookup("capture.recording.dir")); err != nil {
+		return err
+	}
+
+	cmd.PersistentFlags().String("capture.recording.format", "mp4", "recording container format used by the default pipeline and as file extension: mp4, webm")
+	if err := viper.BindPFlag("capture.recording.format", cmd.PersistentFlags().Lookup("capture.recording.format")); err != nil {
+		return err
+	}
+
+	cmd.PersistentFlags().String("capture.recording.pipeline", "", "gstreamer pipeline used for recording")
+	if err := viper.BindPFlag("capture.recording.pipeline", cmd.PersistentFlags().Lookup("capture.recording.pipeline")); err != nil {
+		return err
+	}
+
+	cmd.PersistentFlags().Duration("capture.recording.max_duration", 0, "start a new recording file after this duration, 0 to disable")
+	if err := viper.BindPFlag("capture.recording.max_duration", cmd.PersistentFlags().Lookup("capture.recording.max_duration")); err != nil {
+		return err
+	}
+
+	cmd.PersistentFlags().Int("capture.recording.max_size", 0, "start a new recording file after it reaches this size in MB, 0 to disable")
+	if err := viper.BindPFlag("capture.recording.max_size", cmd.PersistentFlags().Lookup("capture.recording.max_size")); err != nil {
+		return err
+	}
+
+	cmd.PersistentFlags().Int("capture.recording.max_files", 0, "maximum number of recordings kept, the oldest are deleted, 0 to keep all")
+	if err := viper.BindPFlag("capture.recording.max_files", cmd.PersistentFlags().Lookup("capture.recording.max_files")); err != nil {
+		return err
+	}
+
+	cmd.PersistentFlags().Duration("capture.recording.max_age", 0, "delete recordings older than this duration, 0 to keep all")
+	if err := viper.BindPFlag("capture.recording.max_age", cmd.PersistentFlags().Lookup("capture.recording.max_age")); err != nil {
+		return err
+	}
+
 	// screencast
 	cmd.PersistentFlags().Bool("capture.screencast.enabled", false, "enable screencast")
 	if err := viper.BindPFlag("capture.screencast.enabled", cmd.PersistentFlags().Lookup("capture.screencast.enabled")); err != nil {
@@ -152,6 +225,38 @@ func (Capture) Init(cmd *cobra.Command) error {
 		return err
 	}
 
+	// hls
+	cmd.PersistentFlags().Bool("capture.hls.enabled", false, "enable HLS output for watch-only viewers")
+	if err := viper.BindPFlag("capture.hls.enabled", cmd.PersistentFlags().Lookup("capture.hls.enabled")); err != nil {
+		return err
+	}
+
+	cmd.PersistentFlags().Duration("capture.hls.segment_duration", 2*time.Second, "target duration of HLS segments")
+	if err := viper.BindPFlag("capture.hls.segment_duration", cmd.PersistentFlags().Lookup("capture.hls.segment_duration")); err != nil {
+		return err
+	}
+
+	cmd.PersistentFlags().Int("capture.hls.playlist_length", 6, "number of segments in HLS playlist")
+	if err := viper.BindPFlag("capture.hls.playlist_length", cmd.PersistentFlags().Lookup("capture.hls.playlist_length")); err != nil {
+		return err
+	}
+
+	cmd.PersistentFlags().String("capture.hls.pipeline", "", "gstreamer pipeline used for HLS")
+	if err := viper.BindPFlag("capture.hls.pipeline", cmd.PersistentFlags().Lookup("capture.hls.pipeline")); err != nil {
+		return err
+	}
+
+	cmd.PersistentFlags().Int("capture.hls.max_webrtc_viewers", 0, "maximum number of watch-only WebRTC viewers, others are redirected to HLS, 0 for unlimited")
+	if err := viper.BindPFlag("capture.hls.max_webrtc_viewers", cmd.PersistentFlags().Lookup("capture.hls.max_webrtc_viewers")); err != nil {
+		return err
+	}
+
+	// watchdog
+	cmd.PersistentFlags().Duration("capture.watchdog.timeout", 5*time.Second, "restart capture pipelines that did not produce any sample for this duration, 0 to disable")
+	if err := viper.BindPFlag("capture.watchdog.timeout", cmd.PersistentFlags().Lookup("capture.watchdog.timeout")); err != nil {
+		return err
+	}
+
 	// webcam
 	cmd.PersistentFlags().Bool("capture.webcam.enabled", false, "enable webcam stream")
 	if err := viper.BindPFlag("capture.webcam.enabled", cmd.PersistentFlags().Lookup("capture.webcam.enabled")); err != nil {
@@ -187,6 +292,11 @@ func (Capture) Init(cmd *cobra.Command) error {
 		return err
 	}
 
+	cmd.PersistentFlags().Bool("capture.microphone.mixing", false, "mix microphones of all participants instead of using only the last one")
+	if err := viper.BindPFlag("capture.microphone.mixing", cmd.PersistentFlags().Lookup("capture.microphone.mixing")); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -400,6 +510,50 @@ func (s *Capture) Set() {
 		log.Warn().Msg("you are setting both single video pipeline and multiple video pipelines, ignoring single video pipeline")
 	}
 
+	s.VideoShared = viper.GetBool("capture.video.shared")
+
+	videoCodecs := map[string]map[string]types.VideoConfig{}
+	if err := viper.UnmarshalKey("capture.video.codecs", &videoCodecs, viper.DecodeHook(
+		utils.JsonStringAutoDecode(videoCodecs),
+	)); err != nil {
+		log.Warn().Err(err).Msgf("unable to parse video codecs")
+	}
+
+	codecNames := make([]string, 0, len(videoCodecs))
+	for name := range videoCodecs {
+		codecNames = append(codecNames, name)
+	}
+	slices.Sort(codecNames)
+
+	for _, name := range codecNames {
+		videoCodec, ok := codec.ParseStr(name)
+		if !ok || !videoCodec.IsVideo() {
+			log.Warn().Str("codec", name).Msg("unknown video codec, ignoring its pipelines")
+			continue
+		}
+
+		if videoCodec.Name == s.VideoCodec.Name {
+			log.Warn().Str("codec", name).Msg("video codec is already used by default pipelines, ignoring its pipelines")
+			continue
+		}
+
+		// every codec must provide all video ids, so that peers can switch between them regardless of codec
+		pipelines := videoCodecs[name]
+		missing := slices.IndexFunc(s.VideoIDs, func(id string) bool {
+			_, ok := pipelines[id]
+			return !ok
+		})
+		if missing != -1 {
+			log.Warn().Str("codec", name).Str("video_id", s.VideoIDs[missing]).Msg("video codec is missing pipeline, ignoring its pipelines")
+			continue
+		}
+
+		s.VideoCodecs = append(s.VideoCodecs, VideoCodecConfig{
+			Codec:     videoCodec,
+			Pipelines: pipelines,
+		})
+	}
+
 	// audio
 	s.AudioDevice = viper.GetString("capture.audio.device")
 	s.AudioPipeline = viper.GetString("capture.audio.pipeline")
@@ -419,12 +573,41 @@ func (s *Capture) Set() {
 	s.BroadcastUrl = viper.GetString("capture.broadcast.url")
 	s.BroadcastAutostart = viper.GetBool("capture.broadcast.autostart")
 
+	// recording
+	s.RecordingDir = viper.GetString("capture.recording.dir")
+	s.RecordingFormat = viper.GetString("capture.recording.format")
+	s.RecordingPipeline = viper.GetString("capture.recording.pipeline")
+	// custom pipeline can use any format, it is used only as file extension
+	if s.RecordingPipeline == "" && s.RecordingFormat != "mp4" && s.RecordingFormat != "webm" {
+		log.Warn().Str("format", s.RecordingFormat).Msgf("unknown recording format, using mp4")
+		s.RecordingFormat = "mp4"
+	}
+	s.RecordingMaxDuration = viper.GetDuration("capture.recording.max_duration")
+	s.RecordingMaxSize = viper.GetInt("capture.recording.max_size")
+	s.RecordingMaxFiles = viper.GetInt("capture.recording.max_files")
+	s.RecordingMaxAge = viper.GetDuration("capture.recording.max_age")
+
 	// screencast
 	s.ScreencastEnabled = viper.GetBool("capture.screencast.enabled")
 	s.ScreencastRate = viper.GetString("capture.screencast.rate")
 	s.ScreencastQuality = viper.GetString("capture.screencast.quality")
 	s.ScreencastPipeline = viper.GetString("capture.screencast.pipeline")
 
+	// hls
+	s.HLSEnabled = viper.GetBool("capture.hls.enabled")
+	s.HLSSegmentDuration = viper.GetDuration("capture.hls.segment_duration")
+	// hlssink2 accepts only whole seconds
+	if s.HLSSegmentDuration < time.Second {
+		log.Warn().Dur("segment_duration", s.HLSSegmentDuration).Msgf("HLS segment duration too short, using 1s")
+		s.HLSSegmentDuration = time.Second
+	}
+	s.HLSPlaylistLength = viper.GetInt("capture.hls.playlist_length")
+	s.HLSPipeline = viper.GetString("capture.hls.pipeline")
+	s.HLSMaxWebRTCViewers = viper.GetInt("capture.hls.max_webrtc_viewers")
+
+	// watchdog
+	s.WatchdogTimeout = viper.GetDuration("capture.watchdog.timeout")
+
 	// webcam
 	s.WebcamEnabled = viper.GetBool("capture.webcam.enabled")
 	s.WebcamDevice = viper.GetString("capture.webcam.device")
@@ -434,6 +617,7 @@ func (s *Capture) Set() {
 	// microphone
 	s.MicrophoneEnabled = viper.GetBool("capture.microphone.enabled")
 	s.MicrophoneDevice = viper.GetString("capture.microphone.device")
+	s.MicrophoneMixing = viper.GetBool("capture.microphone.mixing")
 }
 
 func (s *Capture) SetV2() {
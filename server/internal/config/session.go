@@ -6,8 +6,29 @@ import (
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+
+	"github.com/m1k1o/neko/server/pkg/types"
+	"github.com/m1k1o/neko/server/pkg/utils"
 )
 
+// default rate limits for events sent by clients
+var defRateLimitEvents = map[string]types.RateLimit{
+	// also sent over data channel, releasing events are never limited
+	"control/move":        {Rate: 250, Burst: 500},
+	"control/scroll":      {Rate: 100, Burst: 200},
+	"control/keydown":     {Rate: 50, Burst: 100},
+	"control/buttondown":  {Rate: 20, Burst: 50},
+	"control/touchbegin":  {Rate: 20, Burst: 50},
+	"control/touchupdate": {Rate: 250, Burst: 500},
+	// websocket only
+	"control/keypress": {Rate: 50, Burst: 100},
+	"control/text":     {Rate: 10, Burst: 50},
+	"send/broadcast":   {Rate: 5, Burst: 20},
+	"send/unicast":     {Rate: 5, Burst: 20},
+	"chat/message":     {Rate: 2, Burst: 10},
+	"screen/set":       {Rate: 0.2, Burst: 2},
+}
+
 type SessionCookie struct {
 	Enabled    bool
 	Name       string
@@ -18,20 +39,31 @@ type SessionCookie struct {
 	Path       string
 }
 
+type SessionRateLimit struct {
+	Enabled bool
+	// token bucket per event type
+	Events map[string]types.RateLimit
+	// how many consecutive events can be dropped before the session is disconnected, 0 means never
+	Disconnect int
+}
+
 type Session struct {
 	File string
 
-	PrivateMode       bool
-	LockedLogins      bool
-	LockedControls    bool
-	ControlProtection bool
-	ImplicitHosting   bool
-	InactiveCursors   bool
-	MercifulReconnect bool
-	HeartbeatInterval int
-	APIToken          string
-
-	Cookie SessionCookie
+	PrivateMode        bool
+	LockedLogins       bool
+	LockedControls     bool
+	ControlProtection  bool
+	ImplicitHosting    bool
+	InactiveCursors    bool
+	MercifulReconnect  bool
+	HeartbeatInterval  int
+	HeartbeatGrace     time.Duration
+	APIToken           string
+	WSTicketExpiration time.Duration
+
+	Cookie    SessionCookie
+	RateLimit SessionRateLimit
 }
 
 func (Session) Init(cmd *cobra.Command) error {
@@ -80,11 +112,21 @@ func (Session) Init(cmd *cobra.Command) error {
 		return err
 	}
 
+	cmd.PersistentFlags().Duration("session.heartbeat_grace", 30*time.Second, "disconnect peers whose heartbeats stop for this long, 0 to disable")
+	if err := viper.BindPFlag("session.heartbeat_grace", cmd.PersistentFlags().Lookup("session.heartbeat_grace")); err != nil {
+		return err
+	}
+
 	cmd.PersistentFlags().String("session.api_token", "", "API token for interacting with external services")
 	if err := viper.BindPFlag("session.api_token", cmd.PersistentFlags().Lookup("session.api_token")); err != nil {
 		return err
 	}
 
+	cmd.PersistentFlags().Duration("session.ws_ticket_expiration", 10*time.Second, "how long single-use websocket connection tickets are valid")
+	if err := viper.BindPFlag("session.ws_ticket_expiration", cmd.PersistentFlags().Lookup("session.ws_ticket_expiration")); err != nil {
+		return err
+	}
+
 	// cookie
 	cmd.PersistentFlags().Bool("session.cookie.enabled", false, "whether cookies authentication should be enabled")
 	if err := viper.BindPFlag("session.cookie.enabled", cmd.PersistentFlags().Lookup("session.cookie.enabled")); err != nil {
@@ -121,6 +163,22 @@ func (Session) Init(cmd *cobra.Command) error {
 		return err
 	}
 
+	// rate limit
+	cmd.PersistentFlags().Bool("session.ratelimit.enabled", false, "whether events sent by clients should be rate limited")
+	if err := viper.BindPFlag("session.ratelimit.enabled", cmd.PersistentFlags().Lookup("session.ratelimit.enabled")); err != nil {
+		return err
+	}
+
+	cmd.PersistentFlags().String("session.ratelimit.events", "{}", "rate limits (events per second and burst) for each event type, defaults are used if empty")
+	if err := viper.BindPFlag("session.ratelimit.events", cmd.PersistentFlags().Lookup("session.ratelimit.events")); err != nil {
+		return err
+	}
+
+	cmd.PersistentFlags().Int("session.ratelimit.disconnect", 0, "disconnect session after this many consecutive rate limited events, 0 to disable")
+	if err := viper.BindPFlag("session.ratelimit.disconnect", cmd.PersistentFlags().Lookup("session.ratelimit.disconnect")); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -159,7 +217,9 @@ func (s *Session) Set() {
 	s.InactiveCursors = viper.GetBool("session.inactive_cursors")
 	s.MercifulReconnect = viper.GetBool("session.merciful_reconnect")
 	s.HeartbeatInterval = viper.GetInt("session.heartbeat_interval")
+	s.HeartbeatGrace = viper.GetDuration("session.heartbeat_grace")
 	s.APIToken = viper.GetString("session.api_token")
+	s.WSTicketExpiration = viper.GetDuration("session.ws_ticket_expiration")
 
 	s.Cookie.Enabled = viper.GetBool("session.cookie.enabled")
 	s.Cookie.Name = viper.GetString("session.cookie.name")
@@ -168,6 +228,20 @@ func (s *Session) Set() {
 	s.Cookie.HTTPOnly = viper.GetBool("session.cookie.http_only")
 	s.Cookie.Domain = viper.GetString("session.cookie.domain")
 	s.Cookie.Path = viper.GetString("session.cookie.path")
+
+	s.RateLimit.Enabled = viper.GetBool("session.ratelimit.enabled")
+	if err := viper.UnmarshalKey("session.ratelimit.events", &s.RateLimit.Events, viper.DecodeHook(
+		utils.JsonStringAutoDecode(s.RateLimit.Events),
+	)); err != nil {
+		log.Warn().Err(err).Msgf("unable to parse rate limit events")
+	}
+	s.RateLimit.Disconnect = viper.GetInt("session.ratelimit.disconnect")
+
+	// if no rate limits are set, use default
+	if s.RateLimit.Enabled && len(s.RateLimit.Events) == 0 {
+		log.Info().Msg("no rate limit events specified, using default")
+		s.RateLimit.Events = defRateLimitEvents
+	}
 }
 
 func (s *Session) SetV2() {
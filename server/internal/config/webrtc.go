@@ -8,6 +8,7 @@ import (
 
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 
 	"github.com/m1k1o/neko/server/pkg/types"
@@ -22,6 +23,10 @@ type WebRTCEstimator struct {
 	Passive        bool
 	Debug          bool
 	InitialBitrate int
+	// strategy deciding when to switch video streams
+	Strategy string
+	// directory where samples of every peer are recorded, to be replayed offline
+	TraceDir string
 
 	// how often to read and process bandwidth estimation reports
 	ReadInterval time.Duration
@@ -37,6 +42,57 @@ type WebRTCEstimator struct {
 	UpgradeBackoff time.Duration
 	// how bigger the difference between estimated and stream bitrate must be to trigger upgrade/downgrade
 	DiffThreshold float64
+	// fraction of lost packets considered as congestion by loss strategy
+	LossThreshold float64
+	// increase of round-trip time above its minimum considered as congestion by rtt strategy
+	RTTThreshold time.Duration
+}
+
+// Flags declares bandwidth estimator flags without binding them, so that they
+// can be shared with commands that only replay recorded estimator traces.
+func (WebRTCEstimator) Flags(flags *pflag.FlagSet) {
+	flags.Bool("webrtc.estimator.enabled", false, "enables the bandwidth estimator")
+	flags.Bool("webrtc.estimator.passive", false, "passive estimator mode, when it does not switch pipelines, only estimates")
+	flags.Bool("webrtc.estimator.debug", false, "enables debug logging for the bandwidth estimator")
+	flags.Int("webrtc.estimator.initial_bitrate", 1_000_000, "initial bitrate for the bandwidth estimator")
+	flags.String("webrtc.estimator.strategy", "trend", "strategy deciding when to switch video streams: trend, loss, rtt or remb")
+	flags.String("webrtc.estimator.trace_dir", "", "directory where estimator samples of every peer are recorded, to be replayed offline")
+	flags.Duration("webrtc.estimator.read_interval", 2*time.Second, "how often to read and process bandwidth estimation reports")
+	flags.Duration("webrtc.estimator.stable_duration", 12*time.Second, "how long to wait for stable connection (upward or neutral trend) before upgrading")
+	flags.Duration("webrtc.estimator.unstable_duration", 6*time.Second, "how long to wait for stalled connection (neutral trend with low bandwidth) before downgrading")
+	flags.Duration("webrtc.estimator.stalled_duration", 24*time.Second, "how long to wait for stalled bandwidth estimation before downgrading")
+	flags.Duration("webrtc.estimator.downgrade_backoff", 10*time.Second, "how long to wait before downgrading again after previous downgrade")
+	flags.Duration("webrtc.estimator.upgrade_backoff", 5*time.Second, "how long to wait before upgrading again after previous upgrade")
+	flags.Float64("webrtc.estimator.diff_threshold", 0.15, "how bigger the difference between estimated and stream bitrate must be to trigger upgrade/downgrade")
+	flags.Float64("webrtc.estimator.loss_threshold", 0.05, "fraction of lost packets considered as congestion by the loss strategy")
+	flags.Duration("webrtc.estimator.rtt_threshold", 150*time.Millisecond, "increase of round-trip time above its minimum considered as congestion by the rtt strategy")
+}
+
+func (s *WebRTCEstimator) SetFrom(v *viper.Viper) {
+	s.Enabled = v.GetBool("webrtc.estimator.enabled")
+	s.Passive = v.GetBool("webrtc.estimator.passive")
+	s.Debug = v.GetBool("webrtc.estimator.debug")
+	s.InitialBitrate = v.GetInt("webrtc.estimator.initial_bitrate")
+	s.Strategy = v.GetString("webrtc.estimator.strategy")
+	s.TraceDir = v.GetString("webrtc.estimator.trace_dir")
+	s.ReadInterval = v.GetDuration("webrtc.estimator.read_interval")
+	s.StableDuration = v.GetDuration("webrtc.estimator.stable_duration")
+	s.UnstableDuration = v.GetDuration("webrtc.estimator.unstable_duration")
+	s.StalledDuration = v.GetDuration("webrtc.estimator.stalled_duration")
+	s.DowngradeBackoff = v.GetDuration("webrtc.estimator.downgrade_backoff")
+	s.UpgradeBackoff = v.GetDuration("webrtc.estimator.upgrade_backoff")
+	s.DiffThreshold = v.GetFloat64("webrtc.estimator.diff_threshold")
+	s.LossThreshold = v.GetFloat64("webrtc.estimator.loss_threshold")
+	s.RTTThreshold = v.GetDuration("webrtc.estimator.rtt_threshold")
+}
+
+type WebRTCTURN struct {
+	Enabled bool
+	Port    int
+	// public address advertised to clients
+	Host string
+	// address of relayed candidates, must be reachable by the server itself
+	RelayAddress string
 }
 
 type WebRTC struct {
@@ -44,6 +100,7 @@ type WebRTC struct {
 	ICETrickle         bool
 	ICEServersFrontend []types.ICEServer
 	ICEServersBackend  []types.ICEServer
+	ICECredentialTTL   time.Duration
 	EphemeralMin       uint16
 	EphemeralMax       uint16
 	TCPMux             int
@@ -53,6 +110,7 @@ type WebRTC struct {
 	IpRetrievalUrl string
 
 	Estimator WebRTCEstimator
+	TURN      WebRTCTURN
 }
 
 func (WebRTC) Init(cmd *cobra.Command) error {
@@ -82,6 +140,11 @@ func (WebRTC) Init(cmd *cobra.Command) error {
 		return err
 	}
 
+	cmd.PersistentFlags().Duration("webrtc.ice_credential_ttl", 24*time.Hour, "validity of ephemeral credentials generated for ICE servers with shared secret")
+	if err := viper.BindPFlag("webrtc.ice_credential_ttl", cmd.PersistentFlags().Lookup("webrtc.ice_credential_ttl")); err != nil {
+		return err
+	}
+
 	cmd.PersistentFlags().String("webrtc.epr", "", "limits the pool of ephemeral ports that ICE UDP connections can allocate from")
 	if err := viper.BindPFlag("webrtc.epr", cmd.PersistentFlags().Lookup("webrtc.epr")); err != nil {
 		return err
@@ -107,60 +170,39 @@ func (WebRTC) Init(cmd *cobra.Command) error {
 		return err
 	}
 
-	// bandwidth estimator
-
-	cmd.PersistentFlags().Bool("webrtc.estimator.enabled", false, "enables the bandwidth estimator")
-	if err := viper.BindPFlag("webrtc.estimator.enabled", cmd.PersistentFlags().Lookup("webrtc.estimator.enabled")); err != nil {
-		return err
-	}
-
-	cmd.PersistentFlags().Bool("webrtc.estimator.passive", false, "passive estimator mode, when it does not switch pipelines, only estimates")
-	if err := viper.BindPFlag("webrtc.estimator.passive", cmd.PersistentFlags().Lookup("webrtc.estimator.passive")); err != nil {
-		return err
-	}
-
-	cmd.PersistentFlags().Bool("webrtc.estimator.debug", false, "enables debug logging for the bandwidth estimator")
-	if err := viper.BindPFlag("webrtc.estimator.debug", cmd.PersistentFlags().Lookup("webrtc.estimator.debug")); err != nil {
-		return err
-	}
+	// embedded turn server
 
-	cmd.PersistentFlags().Int("webrtc.estimator.initial_bitrate", 1_000_000, "initial bitrate for the bandwidth estimator")
-	if err := viper.BindPFlag("webrtc.estimator.initial_bitrate", cmd.PersistentFlags().Lookup("webrtc.estimator.initial_bitrate")); err != nil {
+	cmd.PersistentFlags().Bool("webrtc.turn.enabled", false, "runs embedded TURN server, that is advertised to clients with ephemeral credentials")
+	if err := viper.BindPFlag("webrtc.turn.enabled", cmd.PersistentFlags().Lookup("webrtc.turn.enabled")); err != nil {
 		return err
 	}
 
-	cmd.PersistentFlags().Duration("webrtc.estimator.read_interval", 2*time.Second, "how often to read and process bandwidth estimation reports")
-	if err := viper.BindPFlag("webrtc.estimator.read_interval", cmd.PersistentFlags().Lookup("webrtc.estimator.read_interval")); err != nil {
+	cmd.PersistentFlags().Int("webrtc.turn.port", 3478, "UDP and TCP port of the embedded TURN server")
+	if err := viper.BindPFlag("webrtc.turn.port", cmd.PersistentFlags().Lookup("webrtc.turn.port")); err != nil {
 		return err
 	}
 
-	cmd.PersistentFlags().Duration("webrtc.estimator.stable_duration", 12*time.Second, "how long to wait for stable connection (upward or neutral trend) before upgrading")
-	if err := viper.BindPFlag("webrtc.estimator.stable_duration", cmd.PersistentFlags().Lookup("webrtc.estimator.stable_duration")); err != nil {
+	cmd.PersistentFlags().String("webrtc.turn.host", "", "public host or IP address of the embedded TURN server advertised to clients, defaults to the first NAT 1:1 IP address")
+	if err := viper.BindPFlag("webrtc.turn.host", cmd.PersistentFlags().Lookup("webrtc.turn.host")); err != nil {
 		return err
 	}
 
-	cmd.PersistentFlags().Duration("webrtc.estimator.unstable_duration", 6*time.Second, "how long to wait for stalled connection (neutral trend with low bandwidth) before downgrading")
-	if err := viper.BindPFlag("webrtc.estimator.unstable_duration", cmd.PersistentFlags().Lookup("webrtc.estimator.unstable_duration")); err != nil {
+	cmd.PersistentFlags().String("webrtc.turn.relay_address", "127.0.0.1", "IP address of relayed candidates, must be reachable by the server itself")
+	if err := viper.BindPFlag("webrtc.turn.relay_address", cmd.PersistentFlags().Lookup("webrtc.turn.relay_address")); err != nil {
 		return err
 	}
 
-	cmd.PersistentFlags().Duration("webrtc.estimator.stalled_duration", 24*time.Second, "how long to wait for stalled bandwidth estimation before downgrading")
-	if err := viper.BindPFlag("webrtc.estimator.stalled_duration", cmd.PersistentFlags().Lookup("webrtc.estimator.stalled_duration")); err != nil {
-		return err
-	}
+	// bandwidth estimator
 
-	cmd.PersistentFlags().Duration("webrtc.estimator.downgrade_backoff", 10*time.Second, "how long to wait before downgrading again after previous downgrade")
-	if err := viper.BindPFlag("webrtc.estimator.downgrade_backoff", cmd.PersistentFlags().Lookup("webrtc.estimator.downgrade_backoff")); err != nil {
-		return err
-	}
+	WebRTCEstimator{}.Flags(cmd.PersistentFlags())
 
-	cmd.PersistentFlags().Duration("webrtc.estimator.upgrade_backoff", 5*time.Second, "how long to wait before upgrading again after previous upgrade")
-	if err := viper.BindPFlag("webrtc.estimator.upgrade_backoff", cmd.PersistentFlags().Lookup("webrtc.estimator.upgrade_backoff")); err != nil {
-		return err
-	}
-
-	cmd.PersistentFlags().Float64("webrtc.estimator.diff_threshold", 0.15, "how bigger the difference between estimated and stream bitrate must be to trigger upgrade/downgrade")
-	if err := viper.BindPFlag("webrtc.estimator.diff_threshold", cmd.PersistentFlags().Lookup("webrtc.estimator.diff_threshold")); err != nil {
+	var err error
+	cmd.PersistentFlags().VisitAll(func(flag *pflag.Flag) {
+		if err == nil && strings.HasPrefix(flag.Name, "webrtc.estimator.") {
+			err = viper.BindPFlag(flag.Name, flag)
+		}
+	})
+	if err != nil {
 		return err
 	}
 
@@ -254,6 +296,8 @@ func (s *WebRTC) Set() {
 		s.ICEServersBackend = append(s.ICEServersBackend, iceServers...)
 	}
 
+	s.ICECredentialTTL = viper.GetDuration("webrtc.ice_credential_ttl")
+
 	s.TCPMux = viper.GetInt("webrtc.tcpmux")
 	s.UDPMux = viper.GetInt("webrtc.udpmux")
 
@@ -302,19 +346,23 @@ func (s *WebRTC) Set() {
 		}
 	}
 
+	// embedded turn server
+
+	s.TURN.Enabled = viper.GetBool("webrtc.turn.enabled")
+	s.TURN.Port = viper.GetInt("webrtc.turn.port")
+	s.TURN.Host = viper.GetString("webrtc.turn.host")
+	s.TURN.RelayAddress = viper.GetString("webrtc.turn.relay_address")
+	if s.TURN.Enabled && s.TURN.Host == "" {
+		if len(s.NAT1To1IPs) > 0 {
+			s.TURN.Host = s.NAT1To1IPs[0]
+		} else {
+			log.Warn().Msgf("embedded TURN server is enabled, but its host is unknown and will not be advertised")
+		}
+	}
+
 	// bandwidth estimator
 
-	s.Estimator.Enabled = viper.GetBool("webrtc.estimator.enabled")
-	s.Estimator.Passive = viper.GetBool("webrtc.estimator.passive")
-	s.Estimator.Debug = viper.GetBool("webrtc.estimator.debug")
-	s.Estimator.InitialBitrate = viper.GetInt("webrtc.estimator.initial_bitrate")
-	s.Estimator.ReadInterval = viper.GetDuration("webrtc.estimator.read_interval")
-	s.Estimator.StableDuration = viper.GetDuration("webrtc.estimator.stable_duration")
-	s.Estimator.UnstableDuration = viper.GetDuration("webrtc.estimator.unstable_duration")
-	s.Estimator.StalledDuration = viper.GetDuration("webrtc.estimator.stalled_duration")
-	s.Estimator.DowngradeBackoff = viper.GetDuration("webrtc.estimator.downgrade_backoff")
-	s.Estimator.UpgradeBackoff = viper.GetDuration("webrtc.estimator.upgrade_backoff")
-	s.Estimator.DiffThreshold = viper.GetFloat64("webrtc.estimator.diff_threshold")
+	s.Estimator.SetFrom(viper.GetViper())
 }
 
 func (s *WebRTC) SetV2() {
@@ -23,6 +23,12 @@ const (
 
 	// debounce duration for key events
 	debounceDuration = 10 * time.Second
+
+	// how often to check whether pointer is grabbed by an application
+	pointerGrabFrequency = 500 * time.Millisecond
+
+	// for how long after the last pointer input to keep checking pointer grab
+	pointerGrabIdle = 2 * time.Second
 )
 
 var mu = sync.Mutex{}
@@ -36,6 +42,11 @@ type DesktopManagerCtx struct {
 	screenSize types.ScreenSize // cached screen size
 	input      xinput.Driver
 
+	// whether pointer is grabbed by an application, e.g. a game using pointer lock
+	pointerGrabbed atomic.Bool
+	// when was the last pointer input, in unix nanoseconds
+	pointerInputAt atomic.Int64
+
 	// Clipboard process holding the most recent clipboard data.
 	// It must remain running to allow pasting clipboard data.
 	// The last command is kept running until it is replaced or shutdown.
@@ -122,6 +133,34 @@ func (manager *DesktopManagerCtx) Start() {
 			}
 		}
 	})
+
+	manager.wg.Go(func() {
+		ticker := time.NewTicker(pointerGrabFrequency)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-manager.shutdown:
+				return
+			case <-ticker.C:
+				// checking grab briefly grabs the pointer itself, that other applications
+				// notice, so it is done only while someone is using the pointer. Applications
+				// grab or release pointer usually as a response to the pointer input.
+				if time.Since(time.Unix(0, manager.pointerInputAt.Load())) > pointerGrabIdle {
+					continue
+				}
+
+				// previous state is kept while a button is held
+				grabbed, ok := xorg.IsPointerGrabbed()
+				if !ok || manager.pointerGrabbed.Swap(grabbed) == grabbed {
+					continue
+				}
+
+				manager.logger.Debug().Bool("grabbed", grabbed).Msg("pointer grab changed")
+				manager.emmiter.Emit("pointer_grab_changed", grabbed)
+			}
+		}
+	})
 }
 
 func (manager *DesktopManagerCtx) OnBeforeScreenSizeChange(listener func()) {
@@ -136,6 +175,16 @@ func (manager *DesktopManagerCtx) OnAfterScreenSizeChange(listener func()) {
 	})
 }
 
+func (manager *DesktopManagerCtx) OnPointerGrabChanged(listener func(grabbed bool)) {
+	manager.emmiter.On("pointer_grab_changed", func(payload ...any) {
+		listener(payload[0].(bool))
+	})
+}
+
+func (manager *DesktopManagerCtx) IsPointerGrabbed() bool {
+	return manager.pointerGrabbed.Load()
+}
+
 func (manager *DesktopManagerCtx) Shutdown() error {
 	manager.logger.Info().Msgf("shutdown")
 
@@ -10,10 +10,21 @@ import (
 	"github.com/m1k1o/neko/server/pkg/xorg"
 )
 
+// pointerInput records pointer input, so that pointer grab is checked only while it is used.
+func (manager *DesktopManagerCtx) pointerInput() {
+	manager.pointerInputAt.Store(time.Now().UnixNano())
+}
+
 func (manager *DesktopManagerCtx) Move(x, y int) {
+	manager.pointerInput()
 	xorg.Move(x, y)
 }
 
+func (manager *DesktopManagerCtx) MoveRelative(deltaX, deltaY int) {
+	manager.pointerInput()
+	xorg.MoveRelative(deltaX, deltaY)
+}
+
 func (manager *DesktopManagerCtx) GetCursorPosition() (int, int) {
 	return xorg.GetCursorPosition()
 }
@@ -23,6 +34,7 @@ func (manager *DesktopManagerCtx) Scroll(deltaX, deltaY int, controlKey bool) {
 }
 
 func (manager *DesktopManagerCtx) ButtonDown(code uint32) error {
+	manager.pointerInput()
 	return xorg.ButtonDown(code)
 }
 
@@ -31,6 +43,7 @@ func (manager *DesktopManagerCtx) KeyDown(code uint32) error {
 }
 
 func (manager *DesktopManagerCtx) ButtonUp(code uint32) error {
+	manager.pointerInput()
 	return xorg.ButtonUp(code)
 }
 
@@ -39,6 +52,7 @@ func (manager *DesktopManagerCtx) KeyUp(code uint32) error {
 }
 
 func (manager *DesktopManagerCtx) ButtonPress(code uint32) error {
+	manager.pointerInput()
 	xorg.ResetKeys()
 	defer xorg.ResetKeys()
 
@@ -62,6 +76,10 @@ func (manager *DesktopManagerCtx) KeyPress(codes ...uint32) error {
 	return nil
 }
 
+func (manager *DesktopManagerCtx) TypeText(text string) error {
+	return xorg.TypeText(text)
+}
+
 func (manager *DesktopManagerCtx) ResetKeys() {
 	xorg.ResetKeys()
 }
@@ -655,6 +655,11 @@ func (s *session) wsToClient(msg []byte) error {
 			return err
 		}
 
+		// legacy client knows only single broadcast
+		if request.ID != "" && request.ID != types.BroadcastDefaultID {
+			return nil
+		}
+
 		return s.toClient(&oldMessage.BroadcastStatus{
 			Event:    oldEvent.BROADCAST_STATUS,
 			URL:      request.URL,
@@ -107,6 +107,12 @@ func (m *Manager) Route(r types.Router) {
 	r.With(auth.AdminsOnly).Post("/", m.sendMessageHandler)
 }
 
+// ClientEvents are handled by the websocket handler,
+// values describe their payloads, nil means no payload.
+var ClientEvents = map[string]any{
+	CHAT_MESSAGE: Content{},
+}
+
 func (m *Manager) WebSocketHandler(session types.Session, msg types.WebSocketMessage) bool {
 	switch msg.Event {
 	case CHAT_MESSAGE:
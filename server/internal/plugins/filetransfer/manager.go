@@ -272,6 +272,12 @@ func (m *Manager) Route(r types.Router) {
 	r.Delete("/", m.deleteFileHandler)
 }
 
+// ClientEvents are handled by the websocket handler,
+// values describe their payloads, nil means no payload.
+var ClientEvents = map[string]any{
+	FILETRANSFER_UPDATE: nil,
+}
+
 func (m *Manager) WebSocketHandler(session types.Session, msg types.WebSocketMessage) bool {
 	switch msg.Event {
 	case FILETRANSFER_UPDATE:
@@ -0,0 +1,131 @@
+package session
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	webSocketRTT = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name:      "websocket_rtt_seconds",
+		Namespace: "neko",
+		Subsystem: "session",
+		Help:      "Round-trip time of the last websocket heartbeat.",
+	}, []string{"session_id"})
+
+	webRTCRTT = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name:      "webrtc_rtt_seconds",
+		Namespace: "neko",
+		Subsystem: "session",
+		Help:      "Round-trip time of the last webrtc heartbeat.",
+	}, []string{"session_id"})
+
+	heartbeatTimeouts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name:      "heartbeat_timeouts_total",
+		Namespace: "neko",
+		Subsystem: "session",
+		Help:      "Total number of peers disconnected because their heartbeats stopped.",
+	}, []string{"session_id", "transport"})
+)
+
+type heartbeat struct {
+	mu          sync.Mutex
+	wsTimer     *time.Timer
+	webrtcTimer *time.Timer
+}
+
+// WebSocketHeartbeat marks websocket peer as alive and records its latency, if measured.
+func (session *SessionCtx) WebSocketHeartbeat(rtt time.Duration) {
+	if rtt > 0 {
+		session.state.WebSocketLatency = float64(rtt) / float64(time.Millisecond)
+		webSocketRTT.WithLabelValues(session.id).Set(rtt.Seconds())
+	}
+
+	session.heartbeat.mu.Lock()
+	defer session.heartbeat.mu.Unlock()
+
+	if session.heartbeat.wsTimer != nil {
+		session.heartbeat.wsTimer.Reset(session.manager.config.HeartbeatGrace)
+	}
+}
+
+// WebRTCHeartbeat marks webrtc peer as alive and records its latency, if measured.
+func (session *SessionCtx) WebRTCHeartbeat(rtt time.Duration) {
+	if rtt > 0 {
+		session.state.WebRTCLatency = float64(rtt) / float64(time.Millisecond)
+		webRTCRTT.WithLabelValues(session.id).Set(rtt.Seconds())
+	}
+
+	grace := session.manager.config.HeartbeatGrace
+	if grace <= 0 {
+		return
+	}
+
+	session.heartbeat.mu.Lock()
+	defer session.heartbeat.mu.Unlock()
+
+	if session.heartbeat.webrtcTimer != nil {
+		session.heartbeat.webrtcTimer.Reset(grace)
+		return
+	}
+
+	// clients not sending heartbeats over webrtc are never timed out,
+	// so the timer is only armed once the first heartbeat arrives
+	session.heartbeat.webrtcTimer = time.AfterFunc(grace, func() {
+		webrtcPeer := session.GetWebRTCPeer()
+		if webrtcPeer == nil {
+			return
+		}
+
+		session.logger.Warn().Msg("webrtc heartbeat timeout, destroying peer")
+		heartbeatTimeouts.WithLabelValues(session.id, "webrtc").Inc()
+		webrtcPeer.Destroy()
+	})
+}
+
+func (session *SessionCtx) startWebSocketHeartbeat() {
+	grace := session.manager.config.HeartbeatGrace
+	if grace <= 0 {
+		return
+	}
+
+	session.heartbeat.mu.Lock()
+	defer session.heartbeat.mu.Unlock()
+
+	if session.heartbeat.wsTimer != nil {
+		session.heartbeat.wsTimer.Stop()
+	}
+
+	session.heartbeat.wsTimer = time.AfterFunc(grace, func() {
+		session.logger.Warn().Msg("websocket heartbeat timeout, destroying peer")
+		heartbeatTimeouts.WithLabelValues(session.id, "websocket").Inc()
+		session.DestroyWebSocketPeer("heartbeat timeout")
+	})
+}
+
+func (session *SessionCtx) stopWebSocketHeartbeat() {
+	session.heartbeat.mu.Lock()
+	if session.heartbeat.wsTimer != nil {
+		session.heartbeat.wsTimer.Stop()
+		session.heartbeat.wsTimer = nil
+	}
+	session.heartbeat.mu.Unlock()
+
+	session.state.WebSocketLatency = 0
+	webSocketRTT.DeleteLabelValues(session.id)
+}
+
+func (session *SessionCtx) stopWebRTCHeartbeat() {
+	session.heartbeat.mu.Lock()
+	if session.heartbeat.webrtcTimer != nil {
+		session.heartbeat.webrtcTimer.Stop()
+		session.heartbeat.webrtcTimer = nil
+	}
+	session.heartbeat.mu.Unlock()
+
+	session.state.WebRTCLatency = 0
+	webRTCRTT.DeleteLabelValues(session.id)
+}
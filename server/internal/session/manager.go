@@ -31,6 +31,7 @@ func New(config *config.Session) *SessionManagerCtx {
 			HeartbeatInterval: config.HeartbeatInterval,
 		},
 		tokens:   make(map[string]string),
+		tickets:  make(map[string]ticket),
 		sessions: make(map[string]*SessionCtx),
 		cursors:  make(map[types.Session][]types.Cursor),
 		emmiter:  events.New(),
@@ -74,6 +75,9 @@ type SessionManagerCtx struct {
 	sessions   map[string]*SessionCtx
 	sessionsMu sync.Mutex
 
+	tickets   map[string]ticket
+	ticketsMu sync.Mutex
+
 	hostId atomic.Value
 
 	cursors   map[types.Session][]types.Cursor
@@ -0,0 +1,106 @@
+package session
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"golang.org/x/time/rate"
+
+	"github.com/m1k1o/neko/server/pkg/types/event"
+)
+
+// releasing events are never limited, pressed key or button would stay stuck otherwise
+var rateLimitExempt = map[string]bool{
+	event.CONTROL_KEYUP:    true,
+	event.CONTROL_BUTTONUP: true,
+	event.CONTROL_TOUCHEND: true,
+}
+
+var (
+	rateLimitViolations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name:      "ratelimit_violations_total",
+		Namespace: "neko",
+		Subsystem: "session",
+		Help:      "Total number of events dropped because of rate limiting.",
+	}, []string{"session_id", "event"})
+
+	rateLimitDisconnects = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name:      "ratelimit_disconnects_total",
+		Namespace: "neko",
+		Subsystem: "session",
+		Help:      "Total number of sessions disconnected because of rate limiting.",
+	}, []string{"session_id"})
+)
+
+type rateLimiter struct {
+	mu         sync.Mutex
+	limiters   map[string]*rate.Limiter
+	violations int
+}
+
+// AllowEvent reports whether an event sent by the client should be processed.
+// Events without configured limit and releasing events are always allowed.
+func (session *SessionCtx) AllowEvent(event string) bool {
+	config := session.manager.config.RateLimit
+	if !config.Enabled || rateLimitExempt[event] {
+		return true
+	}
+
+	limit, ok := config.Events[event]
+	if !ok || limit.Rate <= 0 {
+		return true
+	}
+
+	session.rateLimiter.mu.Lock()
+	defer session.rateLimiter.mu.Unlock()
+
+	if session.rateLimiter.limiters == nil {
+		session.rateLimiter.limiters = make(map[string]*rate.Limiter)
+	}
+
+	limiter, ok := session.rateLimiter.limiters[event]
+	if !ok {
+		burst := limit.Burst
+		if burst < 1 {
+			burst = 1
+		}
+
+		limiter = rate.NewLimiter(rate.Limit(limit.Rate), burst)
+		session.rateLimiter.limiters[event] = limiter
+	}
+
+	if limiter.Allow() {
+		session.rateLimiter.violations = 0
+		return true
+	}
+
+	session.rateLimiter.violations++
+	rateLimitViolations.WithLabelValues(session.id, event).Inc()
+
+	if config.Disconnect > 0 && session.rateLimiter.violations == config.Disconnect {
+		session.logger.Warn().
+			Str("event", event).
+			Int("violations", session.rateLimiter.violations).
+			Msg("rate limit exceeded, disconnecting session")
+
+		rateLimitDisconnects.WithLabelValues(session.id).Inc()
+
+		// disconnect asynchronously, caller might be holding connection resources
+		go func() {
+			if err := session.manager.Disconnect(session.id); err != nil {
+				session.logger.Err(err).Msg("unable to disconnect rate limited session")
+			}
+		}()
+	}
+
+	return false
+}
+
+// buckets are kept across reconnects, so that reconnecting does not refill them
+func (session *SessionCtx) resetRateLimitViolations() {
+	session.rateLimiter.mu.Lock()
+	defer session.rateLimiter.mu.Unlock()
+
+	session.rateLimiter.violations = 0
+}
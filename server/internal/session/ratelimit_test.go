@@ -0,0 +1,150 @@
+package session
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/m1k1o/neko/server/internal/config"
+	"github.com/m1k1o/neko/server/pkg/types"
+)
+
+func newRateLimitedSession(t *testing.T, id string, rateLimit config.SessionRateLimit) *SessionCtx {
+	manager := New(&config.Session{
+		RateLimit: rateLimit,
+	})
+
+	session, _, err := manager.Create(id, types.MemberProfile{})
+	if err != nil {
+		t.Fatalf("manager.Create() returned error: %s", err)
+	}
+
+	return session.(*SessionCtx)
+}
+
+func TestSessionCtx_AllowEvent(t *testing.T) {
+	tests := []struct {
+		name      string
+		rateLimit config.SessionRateLimit
+		event     string
+		calls     int
+		want      int
+	}{
+		{
+			name: "disabled",
+			rateLimit: config.SessionRateLimit{
+				Enabled: false,
+				Events:  map[string]types.RateLimit{"chat/message": {Rate: 0.001, Burst: 1}},
+			},
+			event: "chat/message",
+			calls: 10,
+			want:  10,
+		},
+		{
+			name: "event without limit",
+			rateLimit: config.SessionRateLimit{
+				Enabled: true,
+				Events:  map[string]types.RateLimit{"chat/message": {Rate: 0.001, Burst: 1}},
+			},
+			event: "control/keypress",
+			calls: 10,
+			want:  10,
+		},
+		{
+			name: "zero rate",
+			rateLimit: config.SessionRateLimit{
+				Enabled: true,
+				Events:  map[string]types.RateLimit{"chat/message": {Rate: 0, Burst: 1}},
+			},
+			event: "chat/message",
+			calls: 10,
+			want:  10,
+		},
+		{
+			name: "release event",
+			rateLimit: config.SessionRateLimit{
+				Enabled: true,
+				Events:  map[string]types.RateLimit{"control/keyup": {Rate: 0.001, Burst: 1}},
+			},
+			event: "control/keyup",
+			calls: 10,
+			want:  10,
+		},
+		{
+			name: "burst",
+			rateLimit: config.SessionRateLimit{
+				Enabled: true,
+				Events:  map[string]types.RateLimit{"chat/message": {Rate: 0.001, Burst: 3}},
+			},
+			event: "chat/message",
+			calls: 10,
+			want:  3,
+		},
+		{
+			name: "minimal burst",
+			rateLimit: config.SessionRateLimit{
+				Enabled: true,
+				Events:  map[string]types.RateLimit{"chat/message": {Rate: 0.001, Burst: 0}},
+			},
+			event: "chat/message",
+			calls: 10,
+			want:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := newRateLimitedSession(t, "test", tt.rateLimit)
+
+			allowed := 0
+			for i := 0; i < tt.calls; i++ {
+				if session.AllowEvent(tt.event) {
+					allowed++
+				}
+			}
+
+			if allowed != tt.want {
+				t.Errorf("AllowEvent() allowed %d events, want %d", allowed, tt.want)
+			}
+		})
+	}
+}
+
+func TestSessionCtx_AllowEvent_disconnect(t *testing.T) {
+	session := newRateLimitedSession(t, "test-disconnect", config.SessionRateLimit{
+		Enabled: true,
+		Events: map[string]types.RateLimit{
+			"chat/message": {Rate: 0.001, Burst: 1},
+			"send/unicast": {Rate: 0.001, Burst: 1},
+		},
+		Disconnect: 3,
+	})
+
+	disconnects := func() float64 {
+		return testutil.ToFloat64(rateLimitDisconnects.WithLabelValues(session.id))
+	}
+
+	session.AllowEvent("chat/message")
+	session.AllowEvent("chat/message")
+	session.AllowEvent("chat/message")
+
+	// allowed event resets consecutive violations
+	session.AllowEvent("send/unicast")
+
+	session.AllowEvent("chat/message")
+	session.AllowEvent("chat/message")
+	if got := disconnects(); got != 0 {
+		t.Fatalf("session disconnected %v times after 2 consecutive violations, want 0", got)
+	}
+
+	session.AllowEvent("chat/message")
+	if got := disconnects(); got != 1 {
+		t.Fatalf("session disconnected %v times after 3 consecutive violations, want 1", got)
+	}
+
+	// session is disconnected only once, when the threshold is reached
+	session.AllowEvent("chat/message")
+	if got := disconnects(); got != 1 {
+		t.Fatalf("session disconnected %v times after 4 consecutive violations, want 1", got)
+	}
+}
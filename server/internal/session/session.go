@@ -31,6 +31,9 @@ type SessionCtx struct {
 
 	webrtcPeer types.WebRTCPeer
 	webrtcMu   sync.Mutex
+
+	rateLimiter rateLimiter
+	heartbeat   heartbeat
 }
 
 func (session *SessionCtx) ID() string {
@@ -125,6 +128,8 @@ func (session *SessionCtx) ConnectWebSocketPeer(websocketPeer types.WebSocketPee
 	}
 
 	session.logger.Info().Msg("set websocket connected")
+	session.resetRateLimitViolations()
+	session.startWebSocketHeartbeat()
 
 	// update state
 	now := time.Now()
@@ -193,6 +198,7 @@ func (session *SessionCtx) DisconnectWebSocketPeer(websocketPeer types.WebSocket
 	//
 
 	session.logger.Info().Msg("set websocket disconnected")
+	session.stopWebSocketHeartbeat()
 
 	now := time.Now()
 	session.state.IsConnected = false
@@ -305,6 +311,7 @@ func (session *SessionCtx) SetWebRTCConnected(webrtcPeer types.WebRTCPeer, conne
 	session.webrtcMu.Unlock()
 
 	if isCurrentPeer {
+		session.stopWebRTCHeartbeat()
 		session.Send(event.SIGNAL_CLOSE, nil)
 	}
 }
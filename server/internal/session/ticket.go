@@ -0,0 +1,65 @@
+package session
+
+import (
+	"time"
+
+	"github.com/m1k1o/neko/server/pkg/types"
+	"github.com/m1k1o/neko/server/pkg/utils"
+)
+
+type ticket struct {
+	sessionId string
+	expiresAt time.Time
+}
+
+// CreateTicket issues a single-use ticket that can be used instead of the session
+// token when connecting, so that long-lived tokens do not need to appear in URLs.
+func (manager *SessionManagerCtx) CreateTicket(session types.Session) (string, time.Time, error) {
+	value, err := utils.NewUID(32)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(manager.config.WSTicketExpiration)
+
+	manager.ticketsMu.Lock()
+	defer manager.ticketsMu.Unlock()
+
+	// remove expired tickets, so that unused ones do not pile up
+	for key, t := range manager.tickets {
+		if now.After(t.expiresAt) {
+			delete(manager.tickets, key)
+		}
+	}
+
+	manager.tickets[value] = ticket{
+		sessionId: session.ID(),
+		expiresAt: expiresAt,
+	}
+
+	return value, expiresAt, nil
+}
+
+// AuthenticateTicket consumes a ticket and returns session it was issued for.
+func (manager *SessionManagerCtx) AuthenticateTicket(value string) (types.Session, error) {
+	manager.ticketsMu.Lock()
+	t, ok := manager.tickets[value]
+	delete(manager.tickets, value)
+	manager.ticketsMu.Unlock()
+
+	if !ok || time.Now().After(t.expiresAt) {
+		return nil, types.ErrSessionTicketInvalid
+	}
+
+	session, ok := manager.Get(t.sessionId)
+	if !ok {
+		return nil, types.ErrSessionNotFound
+	}
+
+	if !session.Profile().CanLogin {
+		return nil, types.ErrSessionLoginDisabled
+	}
+
+	return session, nil
+}
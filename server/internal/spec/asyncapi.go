@@ -0,0 +1,111 @@
+package spec
+
+import (
+	"strings"
+)
+
+type AsyncAPI struct {
+	AsyncAPI   string             `json:"asyncapi"`
+	Info       Info               `json:"info"`
+	Channels   map[string]Channel `json:"channels"`
+	Components AsyncAPIComponents `json:"components"`
+}
+
+type AsyncAPIComponents struct {
+	Messages map[string]*Message `json:"messages"`
+	Schemas  map[string]*Schema  `json:"schemas"`
+}
+
+type Channel struct {
+	Description string            `json:"description,omitempty"`
+	Publish     *ChannelOperation `json:"publish,omitempty"`
+	Subscribe   *ChannelOperation `json:"subscribe,omitempty"`
+}
+
+type ChannelOperation struct {
+	OperationID string          `json:"operationId"`
+	Summary     string          `json:"summary,omitempty"`
+	Message     ChannelMessages `json:"message"`
+}
+
+type ChannelMessages struct {
+	OneOf []*Schema `json:"oneOf"`
+}
+
+type Message struct {
+	Name    string  `json:"name"`
+	Payload *Schema `json:"payload"`
+}
+
+// NewAsyncAPI describes all events exchanged over the websocket connection at path,
+// every message is wrapped in an envelope containing event name and payload.
+func NewAsyncAPI(version, path string) *AsyncAPI {
+	schemas := newSchemas("#/components/schemas/")
+
+	doc := &AsyncAPI{
+		AsyncAPI: "2.6.0",
+		Info: Info{
+			Title:   "Neko WebSocket API",
+			Version: version,
+		},
+		Channels: map[string]Channel{},
+		Components: AsyncAPIComponents{
+			Messages: map[string]*Message{},
+			Schemas:  schemas.components,
+		},
+	}
+
+	// in asyncapi 2, publish means that clients publish messages to the server
+	publish := &ChannelOperation{
+		OperationID: "clientEvents",
+		Summary:     "Events sent by the client.",
+	}
+
+	subscribe := &ChannelOperation{
+		OperationID: "serverEvents",
+		Summary:     "Events sent by the server.",
+	}
+
+	for _, list := range []struct {
+		prefix    string
+		events    []eventMessage
+		operation *ChannelOperation
+	}{
+		{"client.", clientEvents(), publish},
+		{"server.", serverEvents, subscribe},
+	} {
+		for _, e := range list.events {
+			name := list.prefix + strings.ReplaceAll(e.Event, "/", ".")
+
+			envelope := &Schema{
+				Type: "object",
+				Properties: map[string]*Schema{
+					"event": {Type: "string", Enum: []any{e.Event}},
+				},
+				Required: []string{"event"},
+			}
+
+			if payload := schemas.of(e.Payload); payload != nil {
+				envelope.Properties["payload"] = payload
+				envelope.Required = append(envelope.Required, "payload")
+			}
+
+			doc.Components.Messages[name] = &Message{
+				Name:    e.Event,
+				Payload: envelope,
+			}
+
+			list.operation.Message.OneOf = append(list.operation.Message.OneOf, &Schema{
+				Ref: "#/components/messages/" + name,
+			})
+		}
+	}
+
+	doc.Channels[path] = Channel{
+		Description: "WebSocket connection, authenticated the same way as the REST API.",
+		Publish:     publish,
+		Subscribe:   subscribe,
+	}
+
+	return doc
+}
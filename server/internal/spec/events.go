@@ -0,0 +1,92 @@
+package spec
+
+import (
+	"sort"
+
+	"github.com/m1k1o/neko/server/internal/plugins/chat"
+	"github.com/m1k1o/neko/server/internal/plugins/filetransfer"
+	"github.com/m1k1o/neko/server/internal/plugins/openinapp"
+	"github.com/m1k1o/neko/server/internal/websocket/handler"
+	"github.com/m1k1o/neko/server/pkg/types"
+	"github.com/m1k1o/neko/server/pkg/types/event"
+	"github.com/m1k1o/neko/server/pkg/types/message"
+)
+
+type eventMessage struct {
+	Event string
+	// go value describing the payload, nil means no payload
+	Payload any
+}
+
+// events sent by clients, taken from handlers registered by the server
+func clientEvents() []eventMessage {
+	handlers := []map[string]any{
+		handler.Events(),
+		// plugins
+		chat.ClientEvents,
+		filetransfer.ClientEvents,
+	}
+
+	events := []eventMessage{}
+	for _, handled := range handlers {
+		for name, payload := range handled {
+			events = append(events, eventMessage{name, payload})
+		}
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Event < events[j].Event
+	})
+
+	return events
+}
+
+// events sent by the server to clients, tests check them against all send calls
+var serverEvents = []eventMessage{
+	{event.SYSTEM_INIT, message.SystemInit{}},
+	{event.SYSTEM_ADMIN, message.SystemAdmin{}},
+	{event.SYSTEM_SETTINGS, message.SystemSettingsUpdate{}},
+	{event.SYSTEM_DISCONNECT, message.SystemDisconnect{}},
+	{event.SYSTEM_HEARTBEAT, nil},
+
+	{event.SIGNAL_PROVIDE, message.SignalProvide{}},
+	{event.SIGNAL_RESTART, message.SignalDescription{}},
+	{event.SIGNAL_OFFER, message.SignalDescription{}},
+	{event.SIGNAL_ANSWER, message.SignalDescription{}},
+	{event.SIGNAL_CANDIDATE, message.SignalCandidate{}},
+	{event.SIGNAL_VIDEO, types.PeerVideo{}},
+	{event.SIGNAL_AUDIO, types.PeerAudio{}},
+	{event.SIGNAL_CLOSE, nil},
+	{event.SIGNAL_STATS, message.SignalStats{}},
+	{event.SIGNAL_HLS, message.SignalHLS{}},
+
+	{event.SESSION_CREATED, message.SessionData{}},
+	{event.SESSION_DELETED, message.SessionID{}},
+	{event.SESSION_PROFILE, message.MemberProfile{}},
+	{event.SESSION_STATE, message.SessionState{}},
+	{event.SESSION_CURSORS, []message.SessionCursors{}},
+
+	{event.CONTROL_HOST, message.ControlHost{}},
+	{event.CONTROL_REQUEST, message.SessionID{}},
+	{event.CONTROL_POINTERGRAB, message.ControlPointerGrab{}},
+
+	{event.SCREEN_UPDATED, message.ScreenSizeUpdate{}},
+	{event.SCREENCAST_FRAME, message.ScreencastFrame{}},
+	{event.CLIPBOARD_UPDATED, message.ClipboardData{}},
+	{event.BROADCAST_STATUS, message.BroadcastStatus{}},
+	{event.RECORDING_STATUS, message.RecordingStatus{}},
+	{event.MICROPHONE_INPUTS, message.MicrophoneInputs{}},
+	{event.MICROPHONE_MUTED, message.MicrophoneMuted{}},
+
+	{event.SEND_UNICAST, message.SendUnicast{}},
+	{event.SEND_BROADCAST, message.SendBroadcast{}},
+
+	{event.FILE_CHOOSER_DIALOG_OPENED, message.SessionID{}},
+	{event.FILE_CHOOSER_DIALOG_CLOSED, message.SessionID{}},
+
+	// plugins
+	{chat.CHAT_INIT, chat.Init{}},
+	{chat.CHAT_MESSAGE, chat.Message{}},
+	{filetransfer.FILETRANSFER_UPDATE, filetransfer.Message{}},
+	{openinapp.OPENINAPP_INIT, openinapp.Init{}},
+}
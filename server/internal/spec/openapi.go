@@ -0,0 +1,200 @@
+package spec
+
+import (
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/m1k1o/neko/server/pkg/types"
+	"github.com/m1k1o/neko/server/pkg/utils"
+)
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type OpenAPI struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       Info                             `json:"info"`
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components OpenAPIComponents                `json:"components"`
+	Security   []map[string][]string            `json:"security"`
+}
+
+type OpenAPIComponents struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes"`
+}
+
+type SecurityScheme struct {
+	Type   string `json:"type"`
+	In     string `json:"in,omitempty"`
+	Name   string `json:"name,omitempty"`
+	Scheme string `json:"scheme,omitempty"`
+}
+
+type Operation struct {
+	Tags        []string             `json:"tags,omitempty"`
+	Summary     string               `json:"summary,omitempty"`
+	OperationID string               `json:"operationId"`
+	Parameters  []*Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+	// nil means global security, empty means public
+	Security    *[]map[string][]string `json:"security,omitempty"`
+	Middlewares []string               `json:"x-middlewares,omitempty"`
+}
+
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required,omitempty"`
+	Schema   *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+var pathParamRegex = regexp.MustCompile(`\{([^}:]+)(:[^}]*)?\}`)
+
+// NewOpenAPI describes all routes registered by route function mounted at prefix,
+// routes behind authenticate middleware require one of security schemes.
+func NewOpenAPI(version, prefix string, route func(types.Router), authenticate types.MiddlewareHandler) *OpenAPI {
+	schemas := newSchemas("#/components/schemas/")
+
+	router := newRouter(prefix)
+	route(router)
+
+	authName := funcName(authenticate)
+
+	doc := &OpenAPI{
+		OpenAPI: "3.0.0",
+		Info: Info{
+			Title:   "Neko API Reference",
+			Version: version,
+		},
+		Paths: map[string]map[string]*Operation{},
+		Components: OpenAPIComponents{
+			Schemas: schemas.components,
+			SecuritySchemes: map[string]*SecurityScheme{
+				"CookieAuth": {Type: "apiKey", In: "cookie", Name: "NEKO_SESSION"},
+				"BearerAuth": {Type: "http", Scheme: "bearer"},
+				"TokenAuth":  {Type: "apiKey", In: "query", Name: "token"},
+			},
+		},
+		Security: []map[string][]string{
+			{"BearerAuth": {}},
+			{"CookieAuth": {}},
+			{"TokenAuth": {}},
+		},
+	}
+
+	for _, r := range router.Routes() {
+		op := operations[r.Method+" "+r.Path]
+
+		operation := &Operation{
+			Summary:     op.Summary,
+			OperationID: strings.ToLower(r.Handler[:1]) + r.Handler[1:],
+			Responses:   map[string]*Response{},
+		}
+
+		if op.Tag != "" {
+			operation.Tags = []string{op.Tag}
+		}
+
+		for _, m := range pathParamRegex.FindAllStringSubmatch(r.Path, -1) {
+			operation.Parameters = append(operation.Parameters, &Parameter{
+				Name:     m[1],
+				In:       "path",
+				Required: true,
+				Schema:   &Schema{Type: "string"},
+			})
+		}
+
+		for _, name := range op.Query {
+			operation.Parameters = append(operation.Parameters, &Parameter{
+				Name:   name,
+				In:     "query",
+				Schema: &Schema{Type: "string"},
+			})
+		}
+
+		if op.Request != nil {
+			operation.RequestBody = &RequestBody{
+				Required: true,
+				Content:  mediaType(op.RequestType, schemas.of(op.Request)),
+			}
+		}
+
+		status := op.Status
+		switch {
+		case op.Response != nil, op.ResponseType != "":
+			if status == 0 {
+				status = http.StatusOK
+			}
+		case status == 0:
+			status = http.StatusNoContent
+		}
+
+		response := &Response{
+			Description: http.StatusText(status),
+		}
+
+		switch {
+		case op.Response != nil:
+			response.Content = mediaType(op.ResponseType, schemas.of(op.Response))
+		case op.ResponseType != "":
+			response.Content = mediaType(op.ResponseType, &Schema{Type: "string", Format: "binary"})
+		}
+
+		operation.Responses[strconv.Itoa(status)] = response
+
+		operation.Responses["default"] = &Response{
+			Description: "Error",
+			Content:     mediaType("", schemas.of(utils.HTTPError{})),
+		}
+
+		for _, name := range r.Middlewares {
+			if name != authName {
+				operation.Middlewares = append(operation.Middlewares, name)
+			}
+		}
+
+		if !slices.Contains(r.Middlewares, authName) {
+			operation.Security = &[]map[string][]string{}
+		}
+
+		path := pathParamRegex.ReplaceAllString(r.Path, "{$1}")
+		if _, ok := doc.Paths[path]; !ok {
+			doc.Paths[path] = map[string]*Operation{}
+		}
+		doc.Paths[path][strings.ToLower(r.Method)] = operation
+	}
+
+	return doc
+}
+
+func mediaType(contentType string, schema *Schema) map[string]*MediaType {
+	if contentType == "" {
+		contentType = contentJSON
+	}
+
+	return map[string]*MediaType{
+		contentType: {Schema: schema},
+	}
+}
@@ -0,0 +1,175 @@
+package spec
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/m1k1o/neko/server/internal/api"
+	"github.com/m1k1o/neko/server/internal/api/members"
+	"github.com/m1k1o/neko/server/internal/api/room"
+	"github.com/m1k1o/neko/server/internal/api/sessions"
+	"github.com/m1k1o/neko/server/internal/plugins/chat"
+	"github.com/m1k1o/neko/server/internal/plugins/openinapp"
+	"github.com/m1k1o/neko/server/pkg/types"
+)
+
+const (
+	contentJSON      = "application/json"
+	contentMultipart = "multipart/form-data"
+	contentJPEG      = "image/jpeg"
+	contentPNG       = "image/png"
+	contentBinary    = "application/octet-stream"
+	contentSDP       = "application/sdp"
+	contentHLS       = "application/vnd.apple.mpegurl"
+	contentMJPEG     = "multipart/x-mixed-replace"
+	contentMPEGTS    = "video/mp2t"
+)
+
+type operation struct {
+	Tag     string
+	Summary string
+	Query   []string
+
+	// go values describing request and response body, nil means no body
+	Request  any
+	Response any
+
+	// content types, json is used if empty
+	RequestType  string
+	ResponseType string
+
+	// success status code, 200 or 204 is used if empty
+	Status int
+}
+
+func multipartFiles(fields map[string]*Schema) *Schema {
+	schema := &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"files": {Type: "array", Items: &Schema{Type: "string", Format: "binary"}},
+		},
+		Required: []string{"files"},
+	}
+	for name, field := range fields {
+		schema.Properties[name] = field
+		schema.Required = append(schema.Required, name)
+	}
+	sort.Strings(schema.Required)
+	return schema
+}
+
+// request and response bodies of all routes registered by the api manager,
+// routes are keyed by method and path as registered in the router,
+// tests check bodies against the ones used by route handlers
+var operations = map[string]operation{
+	// current session
+	"POST /api/login":     {Tag: "current-session", Summary: "User Login", Request: api.SessionLoginPayload{}, Response: api.SessionDataPayload{}},
+	"POST /api/logout":    {Tag: "current-session", Summary: "User Logout", Response: true},
+	"GET /api/whoami":     {Tag: "current-session", Summary: "Get Current User", Response: api.SessionDataPayload{}},
+	"POST /api/profile":   {Tag: "current-session", Summary: "Update Profile", Request: types.MemberProfile{}, Response: true},
+	"GET /api/stats":      {Tag: "general", Summary: "Get Stats", Response: types.Stats{}},
+	"POST /api/ws-ticket": {Tag: "current-session", Summary: "Create WebSocket Ticket", Response: api.WebSocketTicketPayload{}},
+
+	// sessions
+	"GET /api/sessions":                         {Tag: "sessions", Summary: "List Sessions", Response: []sessions.SessionDataPayload{}},
+	"GET /api/sessions/{sessionId}":             {Tag: "sessions", Summary: "Get Session", Response: sessions.SessionDataPayload{}},
+	"DELETE /api/sessions/{sessionId}":          {Tag: "sessions", Summary: "Remove Session"},
+	"POST /api/sessions/{sessionId}/disconnect": {Tag: "sessions", Summary: "Disconnect Session"},
+	"GET /api/sessions/{sessionId}/webrtc":      {Tag: "sessions", Summary: "Get Session WebRTC Stats", Response: types.WebRTCStats{}},
+
+	// members
+	"GET /api/members":                      {Tag: "members", Summary: "List Members", Query: []string{"limit", "offset"}, Response: []members.MemberDataPayload{}},
+	"POST /api/members":                     {Tag: "members", Summary: "Create Member", Request: members.MemberCreatePayload{}, Response: members.MemberDataPayload{}},
+	"GET /api/members/{memberId}":           {Tag: "members", Summary: "Get Member Profile", Response: types.MemberProfile{}},
+	"POST /api/members/{memberId}":          {Tag: "members", Summary: "Update Member Profile", Request: types.MemberProfile{}},
+	"POST /api/members/{memberId}/password": {Tag: "members", Summary: "Update Member Password", Request: members.MemberPasswordPayload{}},
+	"DELETE /api/members/{memberId}":        {Tag: "members", Summary: "Remove Member"},
+	"POST /api/members_bulk/update":         {Tag: "members", Summary: "Bulk Update Members", Request: members.MemberBulkUpdatePayload{}},
+	"POST /api/members_bulk/delete":         {Tag: "members", Summary: "Bulk Delete Members", Request: members.MemberBulkDeletePayload{}},
+
+	// room settings
+	"GET /api/room/settings":  {Tag: "room-settings", Summary: "Get Room Settings", Response: types.Settings{}},
+	"POST /api/room/settings": {Tag: "room-settings", Summary: "Update Room Settings", Request: types.Settings{}},
+
+	// room broadcast
+	"GET /api/room/broadcast":        {Tag: "room-broadcast", Summary: "Get Broadcast Status", Response: room.BroadcastStatusPayload{}},
+	"POST /api/room/broadcast/start": {Tag: "room-broadcast", Summary: "Start Broadcast", Request: room.BroadcastStatusPayload{}},
+	"POST /api/room/broadcast/stop":  {Tag: "room-broadcast", Summary: "Stop Broadcast"},
+
+	"GET /api/room/broadcast/destinations":                    {Tag: "room-broadcast", Summary: "List Broadcast Destinations", Response: []types.BroadcastDestination{}},
+	"POST /api/room/broadcast/destinations":                   {Tag: "room-broadcast", Summary: "Start Broadcast Destination", Request: room.BroadcastDestinationPayload{}},
+	"DELETE /api/room/broadcast/destinations/{destinationId}": {Tag: "room-broadcast", Summary: "Stop Broadcast Destination"},
+
+	// room recording
+	"GET /api/room/recording":                          {Tag: "room-recording", Summary: "Get Recording Status", Response: room.RecordingStatusPayload{}},
+	"POST /api/room/recording/start":                   {Tag: "room-recording", Summary: "Start Recording"},
+	"POST /api/room/recording/stop":                    {Tag: "room-recording", Summary: "Stop Recording"},
+	"GET /api/room/recording/list":                     {Tag: "room-recording", Summary: "List Recordings", Response: []types.Recording{}},
+	"GET /api/room/recording/download/{recordingName}": {Tag: "room-recording", Summary: "Download Recording", ResponseType: contentBinary},
+
+	// room forward
+	"GET /api/room/forward":                 {Tag: "room-forward", Summary: "List Forwards", Response: []types.Forward{}},
+	"POST /api/room/forward":                {Tag: "room-forward", Summary: "Start Forward", Request: types.Forward{}, Response: types.Forward{}},
+	"GET /api/room/forward/{forwardId}":     {Tag: "room-forward", Summary: "Get Forward", Response: types.Forward{}},
+	"DELETE /api/room/forward/{forwardId}":  {Tag: "room-forward", Summary: "Stop Forward"},
+	"GET /api/room/forward/{forwardId}/sdp": {Tag: "room-forward", Summary: "Get Forward Session Description", ResponseType: contentSDP, Response: &Schema{Type: "string"}},
+
+	// room microphone
+	"GET /api/room/microphone":                     {Tag: "room-microphone", Summary: "Get Microphone Status", Response: room.MicrophoneStatusPayload{}},
+	"POST /api/room/microphone/mute/{sessionId}":   {Tag: "room-microphone", Summary: "Mute Microphone Input"},
+	"POST /api/room/microphone/unmute/{sessionId}": {Tag: "room-microphone", Summary: "Unmute Microphone Input"},
+
+	// room clipboard
+	"GET /api/room/clipboard":           {Tag: "room-clipboard", Summary: "Get Clipboard Content", Response: room.ClipboardPayload{}},
+	"POST /api/room/clipboard":          {Tag: "room-clipboard", Summary: "Set Clipboard Content", Request: room.ClipboardPayload{}},
+	"GET /api/room/clipboard/image.png": {Tag: "room-clipboard", Summary: "Get Clipboard Image", ResponseType: contentPNG},
+
+	// room keyboard
+	"GET /api/room/keyboard/map":        {Tag: "room-keyboard", Summary: "Get Keyboard Map", Response: types.KeyboardMap{}},
+	"POST /api/room/keyboard/map":       {Tag: "room-keyboard", Summary: "Set Keyboard Map", Request: types.KeyboardMap{}},
+	"GET /api/room/keyboard/modifiers":  {Tag: "room-keyboard", Summary: "Get Keyboard Modifiers", Response: types.KeyboardModifiers{}},
+	"POST /api/room/keyboard/modifiers": {Tag: "room-keyboard", Summary: "Set Keyboard Modifiers", Request: types.KeyboardModifiers{}},
+
+	// room control
+	"GET /api/room/control":                   {Tag: "room-control", Summary: "Get Control Status", Response: room.ControlStatusPayload{}},
+	"POST /api/room/control/request":          {Tag: "room-control", Summary: "Request Control"},
+	"POST /api/room/control/release":          {Tag: "room-control", Summary: "Release Control"},
+	"POST /api/room/control/take":             {Tag: "room-control", Summary: "Take Control"},
+	"POST /api/room/control/give/{sessionId}": {Tag: "room-control", Summary: "Give Control"},
+	"POST /api/room/control/reset":            {Tag: "room-control", Summary: "Reset Control"},
+
+	// room screen
+	"GET /api/room/screen":                {Tag: "room-screen", Summary: "Get Screen Configuration", Response: types.ScreenSize{}},
+	"POST /api/room/screen":               {Tag: "room-screen", Summary: "Change Screen Configuration", Request: types.ScreenSize{}, Response: types.ScreenSize{}},
+	"GET /api/room/screen/configurations": {Tag: "room-screen", Summary: "Get List of Screen Configurations", Response: []types.ScreenSize{}},
+	"GET /api/room/screen/cast.jpg":       {Tag: "room-screen", Summary: "Get Screencast Image", ResponseType: contentJPEG},
+	"GET /api/room/screen/cast.mjpeg":     {Tag: "room-screen", Summary: "Stream Screencast Images", Query: []string{"rate"}, ResponseType: contentMJPEG},
+	"GET /api/room/screen/shot.jpg":       {Tag: "room-screen", Summary: "Get Screenshot Image", Query: []string{"quality"}, ResponseType: contentJPEG},
+
+	// room hls
+	"GET /api/room/hls/playlist.m3u8": {Tag: "room-hls", Summary: "Get HLS Playlist", ResponseType: contentHLS},
+	"GET /api/room/hls/{segmentName}": {Tag: "room-hls", Summary: "Get HLS Segment", ResponseType: contentMPEGTS},
+
+	// room upload
+	"POST /api/room/upload/drop": {Tag: "room-upload", Summary: "Upload and Drop File", RequestType: contentMultipart, Request: multipartFiles(map[string]*Schema{
+		"x": {Type: "integer", Format: "int64"},
+		"y": {Type: "integer", Format: "int64"},
+	})},
+	"POST /api/room/upload/dialog":   {Tag: "room-upload", Summary: "Upload File to Dialog", RequestType: contentMultipart, Request: multipartFiles(nil)},
+	"DELETE /api/room/upload/dialog": {Tag: "room-upload", Summary: "Close File Chooser Dialog"},
+
+	// whep
+	"POST /api/whep":                {Tag: "whep", Summary: "Create WHEP Session", Query: []string{"video", "audio"}, RequestType: contentSDP, Request: &Schema{Type: "string"}, ResponseType: contentSDP, Response: &Schema{Type: "string"}, Status: http.StatusCreated},
+	"DELETE /api/whep/{resourceId}": {Tag: "whep", Summary: "Delete WHEP Session"},
+
+	// whip
+	"POST /api/whip":                {Tag: "whip", Summary: "Create WHIP Session", RequestType: contentSDP, Request: &Schema{Type: "string"}, ResponseType: contentSDP, Response: &Schema{Type: "string"}, Status: http.StatusCreated},
+	"DELETE /api/whip/{resourceId}": {Tag: "whip", Summary: "Delete WHIP Session"},
+
+	// plugins
+	"POST /api/chat":               {Tag: "chat", Summary: "Send Chat Message", Request: chat.Content{}},
+	"GET /api/filetransfer":        {Tag: "filetransfer", Summary: "Download File", Query: []string{"filename"}, ResponseType: contentBinary},
+	"POST /api/filetransfer":       {Tag: "filetransfer", Summary: "Upload Files", RequestType: contentMultipart, Request: multipartFiles(nil)},
+	"DELETE /api/filetransfer":     {Tag: "filetransfer", Summary: "Delete File", Query: []string{"filename"}},
+	"POST /api/openinapp/openlink": {Tag: "openinapp", Summary: "Open Link in Application", Request: openinapp.Url{}},
+}
@@ -0,0 +1,126 @@
+package spec
+
+import (
+	"net/http"
+	"reflect"
+	"runtime"
+	"strings"
+
+	"github.com/m1k1o/neko/server/pkg/types"
+)
+
+type route struct {
+	Method      string
+	Path        string
+	Handler     string
+	Package     string
+	Middlewares []string
+}
+
+// router only records registered routes, it does not serve them
+type router struct {
+	prefix      string
+	middlewares []string
+	routes      *[]route
+}
+
+func newRouter(prefix string) *router {
+	return &router{
+		prefix: prefix,
+		routes: &[]route{},
+	}
+}
+
+func (r *router) Routes() []route {
+	return *r.routes
+}
+
+func (r *router) sub(pattern string) *router {
+	return &router{
+		prefix:      joinPath(r.prefix, pattern),
+		middlewares: append([]string{}, r.middlewares...),
+		routes:      r.routes,
+	}
+}
+
+func (r *router) add(method, pattern string, fn types.RouterHandler) {
+	*r.routes = append(*r.routes, route{
+		Method:      method,
+		Path:        joinPath(r.prefix, pattern),
+		Handler:     funcName(fn),
+		Package:     funcPackage(fn),
+		Middlewares: append([]string{}, r.middlewares...),
+	})
+}
+
+func (r *router) Group(fn func(types.Router)) {
+	fn(r.sub(""))
+}
+
+func (r *router) Route(pattern string, fn func(types.Router)) {
+	fn(r.sub(pattern))
+}
+
+func (r *router) Get(pattern string, fn types.RouterHandler) {
+	r.add(http.MethodGet, pattern, fn)
+}
+
+func (r *router) Post(pattern string, fn types.RouterHandler) {
+	r.add(http.MethodPost, pattern, fn)
+}
+
+func (r *router) Put(pattern string, fn types.RouterHandler) {
+	r.add(http.MethodPut, pattern, fn)
+}
+
+func (r *router) Patch(pattern string, fn types.RouterHandler) {
+	r.add(http.MethodPatch, pattern, fn)
+}
+
+func (r *router) Delete(pattern string, fn types.RouterHandler) {
+	r.add(http.MethodDelete, pattern, fn)
+}
+
+func (r *router) With(fn types.MiddlewareHandler) types.Router {
+	sub := r.sub("")
+	sub.Use(fn)
+	return sub
+}
+
+func (r *router) Use(fn types.MiddlewareHandler) {
+	r.middlewares = append(r.middlewares, funcName(fn))
+}
+
+func (r *router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	http.NotFound(w, req)
+}
+
+// joins path segments the same way chi does, without trailing slash
+func joinPath(prefix, pattern string) string {
+	path := strings.TrimSuffix(prefix, "/") + "/" + strings.TrimPrefix(pattern, "/")
+	if path != "/" {
+		path = strings.TrimSuffix(path, "/")
+	}
+	return path
+}
+
+// import path of the package declaring a function or a method
+func funcPackage(fn any) string {
+	name := runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name()
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		if j := strings.Index(name[i:], "."); j >= 0 {
+			return name[:i+j]
+		}
+	}
+	return name
+}
+
+// short name of a function or a method, e.g. AdminsOnly or sessionsList
+func funcName(fn any) string {
+	name := runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name()
+	name = strings.TrimSuffix(name, "-fm")
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
@@ -0,0 +1,186 @@
+package spec
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+}
+
+var (
+	timeType          = reflect.TypeFor[time.Time]()
+	durationType      = reflect.TypeFor[time.Duration]()
+	rawMessageType    = reflect.TypeFor[json.RawMessage]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+// schemas generates JSON schemas from go types, named structs
+// are stored as components and referenced by their name
+type schemas struct {
+	refPrefix  string
+	components map[string]*Schema
+}
+
+func newSchemas(refPrefix string) *schemas {
+	return &schemas{
+		refPrefix:  refPrefix,
+		components: map[string]*Schema{},
+	}
+}
+
+// returns schema for a go value, nil means no content
+func (s *schemas) of(v any) *Schema {
+	if v == nil {
+		return nil
+	}
+
+	if schema, ok := v.(*Schema); ok {
+		return schema
+	}
+
+	return s.typeOf(reflect.TypeOf(v))
+}
+
+func (s *schemas) typeOf(t reflect.Type) *Schema {
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case durationType:
+		return &Schema{Type: "integer", Format: "int64"}
+	case rawMessageType:
+		return &Schema{}
+	}
+
+	// types encoded as text, such as enums
+	if t.Kind() != reflect.Pointer && t.Implements(textMarshalerType) {
+		return &Schema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32", Minimum: new(float64)}
+	case reflect.Uint, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64", Minimum: new(float64)}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Interface:
+		return &Schema{}
+	case reflect.Pointer:
+		schema := s.typeOf(t.Elem())
+		if schema.Ref == "" {
+			schema.Nullable = true
+		}
+		return schema
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: s.typeOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: s.typeOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.structOf(t)
+		}
+
+		name := typeName(t)
+		if _, ok := s.components[name]; !ok {
+			// reserve name first, so that recursive types terminate
+			s.components[name] = &Schema{}
+			*s.components[name] = *s.structOf(t)
+		}
+
+		return &Schema{Ref: s.refPrefix + name}
+	}
+
+	// channels, functions and complex numbers are not serializable
+	return &Schema{}
+}
+
+func (s *schemas) structOf(t reflect.Type) *Schema {
+	schema := &Schema{
+		Type:       "object",
+		Properties: map[string]*Schema{},
+	}
+
+	s.fieldsOf(t, schema)
+	return schema
+}
+
+func (s *schemas) fieldsOf(t reflect.Type, schema *Schema) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(tag, ",")
+
+		// embedded structs without name are flattened
+		if field.Anonymous && name == "" {
+			ft := field.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				s.fieldsOf(ft, schema)
+				continue
+			}
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		schema.Properties[name] = s.typeOf(field.Type)
+		if !strings.Contains(opts, "omitempty") && field.Type.Kind() != reflect.Pointer {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
+
+// name of the type including its package, e.g. message.SystemInit
+func typeName(t reflect.Type) string {
+	pkg := t.PkgPath()
+	if i := strings.LastIndex(pkg, "/"); i >= 0 {
+		pkg = pkg[i+1:]
+	}
+
+	// generic types contain brackets, that are not allowed in component names
+	name := strings.NewReplacer("[", "_", "]", "", "/", "_", "*", "").Replace(t.Name())
+	if pkg == "" {
+		return name
+	}
+
+	return pkg + "." + name
+}
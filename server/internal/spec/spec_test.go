@@ -0,0 +1,442 @@
+package spec
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/m1k1o/neko/server/internal/api"
+	"github.com/m1k1o/neko/server/internal/config"
+	"github.com/m1k1o/neko/server/internal/plugins"
+	"github.com/m1k1o/neko/server/internal/session"
+	"github.com/m1k1o/neko/server/internal/websocket"
+)
+
+// every registered route must be described and every description must match a route
+func TestOperations(t *testing.T) {
+	sessions := session.New(&config.Session{})
+	apiManager := api.New(sessions, nil, nil, nil, nil)
+	plugins.New(&config.Plugins{}).Start(sessions, websocket.New(sessions, nil, nil, nil), apiManager)
+
+	router := newRouter("/api")
+	apiManager.Route(router)
+
+	registered := map[string]bool{}
+	handlers := map[string]string{}
+	for _, r := range router.Routes() {
+		key := r.Method + " " + r.Path
+		registered[key] = true
+
+		// handler names are used as operation ids
+		if other, ok := handlers[r.Handler]; ok {
+			t.Errorf("routes %q and %q share handler name %q", other, key, r.Handler)
+		}
+		handlers[r.Handler] = key
+
+		if _, ok := operations[key]; !ok {
+			t.Errorf("route %q is not described in operations", key)
+		}
+	}
+
+	for key := range operations {
+		if !registered[key] {
+			t.Errorf("operation %q does not match any registered route", key)
+		}
+	}
+}
+
+// every event constant must be described as sent by client, server or both
+func TestEvents(t *testing.T) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "../../pkg/types/event/events.go", nil, 0)
+	if err != nil {
+		t.Fatalf("unable to parse events: %v", err)
+	}
+
+	described := map[string]bool{}
+	for _, e := range append(clientEvents(), serverEvents...) {
+		described[e.Event] = true
+	}
+
+	ast.Inspect(file, func(n ast.Node) bool {
+		lit, ok := n.(*ast.BasicLit)
+		if !ok || lit.Kind != token.STRING {
+			return true
+		}
+
+		name, err := strconv.Unquote(lit.Value)
+		if err != nil {
+			t.Errorf("unable to unquote %s: %v", lit.Value, err)
+			return true
+		}
+
+		if !described[name] {
+			t.Errorf("event %q is not described in client or server events", name)
+		}
+		return true
+	})
+}
+
+func TestJoinPath(t *testing.T) {
+	tests := []struct {
+		prefix  string
+		pattern string
+		want    string
+	}{
+		{"", "/", "/"},
+		{"/api", "/", "/api"},
+		{"/api", "", "/api"},
+		{"/api/", "/login", "/api/login"},
+		{"/api/sessions", "/{sessionId}", "/api/sessions/{sessionId}"},
+	}
+
+	for _, tt := range tests {
+		if got := joinPath(tt.prefix, tt.pattern); got != tt.want {
+			t.Errorf("joinPath(%q, %q) = %q, want %q", tt.prefix, tt.pattern, got, tt.want)
+		}
+	}
+}
+
+const module = "github.com/m1k1o/neko/server"
+
+type goPackage struct {
+	fset    *token.FileSet
+	name    string
+	files   []*ast.File
+	imports map[*ast.File]map[string]string
+}
+
+// parses non-test go files of a package, rel is its path relative to the module root
+func parsePackage(t *testing.T, rel string) *goPackage {
+	t.Helper()
+
+	dir := filepath.Join("../..", rel)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("unable to read package %s: %v", rel, err)
+	}
+
+	pkg := &goPackage{
+		fset:    token.NewFileSet(),
+		imports: map[*ast.File]map[string]string{},
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+
+		file, err := parser.ParseFile(pkg.fset, filepath.Join(dir, name), nil, 0)
+		if err != nil {
+			t.Fatalf("unable to parse %s: %v", name, err)
+		}
+
+		pkg.name = file.Name.Name
+		pkg.files = append(pkg.files, file)
+
+		imports := map[string]string{}
+		for _, spec := range file.Imports {
+			importPath, _ := strconv.Unquote(spec.Path.Value)
+			alias := importPath[strings.LastIndex(importPath, "/")+1:]
+			if spec.Name != nil {
+				alias = spec.Name.Name
+			}
+			imports[alias] = importPath
+		}
+		pkg.imports[file] = imports
+	}
+
+	return pkg
+}
+
+// string constants declared in a package
+func (pkg *goPackage) constants() map[string]string {
+	constants := map[string]string{}
+	for _, file := range pkg.files {
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.CONST {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				value := spec.(*ast.ValueSpec)
+				for i, name := range value.Names {
+					if i >= len(value.Values) {
+						continue
+					}
+					if lit, ok := value.Values[i].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+						constants[name.Name], _ = strconv.Unquote(lit.Value)
+					}
+				}
+			}
+		}
+	}
+	return constants
+}
+
+// name of a type expression qualified by package name, e.g. message.SystemInit
+func (pkg *goPackage) typeName(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		if e.Name == "bool" || e.Name == "string" {
+			return e.Name
+		}
+		return pkg.name + "." + e.Name
+	case *ast.SelectorExpr:
+		if x, ok := e.X.(*ast.Ident); ok {
+			return x.Name + "." + e.Sel.Name
+		}
+	case *ast.StarExpr:
+		return pkg.typeName(e.X)
+	case *ast.ArrayType:
+		if name := pkg.typeName(e.Elt); name != "" {
+			return "[]" + name
+		}
+	}
+	return ""
+}
+
+// type of a value if it can be told from the source, empty string otherwise,
+// local variables are looked up in the enclosing function body
+func (pkg *goPackage) valueType(expr ast.Expr, body *ast.BlockStmt) string {
+	switch e := expr.(type) {
+	case *ast.UnaryExpr:
+		if e.Op == token.AND {
+			return pkg.valueType(e.X, body)
+		}
+	case *ast.CompositeLit:
+		return pkg.typeName(e.Type)
+	case *ast.Ident:
+		if e.Name == "true" || e.Name == "false" {
+			return "bool"
+		}
+		if body == nil {
+			return ""
+		}
+
+		var name string
+		ast.Inspect(body, func(n ast.Node) bool {
+			switch s := n.(type) {
+			case *ast.AssignStmt:
+				if s.Tok != token.DEFINE || len(s.Lhs) != len(s.Rhs) {
+					return true
+				}
+				for i, lhs := range s.Lhs {
+					if id, ok := lhs.(*ast.Ident); ok && id.Name == e.Name {
+						name = pkg.valueType(s.Rhs[i], nil)
+					}
+				}
+			case *ast.ValueSpec:
+				for _, id := range s.Names {
+					if id.Name == e.Name && s.Type != nil {
+						name = pkg.typeName(s.Type)
+					}
+				}
+			}
+			return name == ""
+		})
+		return name
+	}
+	return ""
+}
+
+// name of the type of a go value describing a payload, qualified by package name
+func valueTypeName(v any) string {
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Slice {
+		return "[]" + valueTypeName(reflect.Zero(t.Elem()).Interface())
+	}
+	if t.PkgPath() == "" {
+		return t.Name()
+	}
+	return path.Base(t.PkgPath()) + "." + t.Name()
+}
+
+// every event sent by the server must be described with the payload it is sent with
+func TestServerEvents(t *testing.T) {
+	described := map[string]any{}
+	for _, e := range serverEvents {
+		described[e.Event] = e.Payload
+	}
+
+	eventPath := module + "/pkg/types/event"
+	constants := map[string]map[string]string{
+		eventPath: parsePackage(t, "pkg/types/event").constants(),
+	}
+
+	err := filepath.WalkDir("../../internal", func(dir string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return err
+		}
+
+		rel, _ := filepath.Rel("../..", dir)
+		pkg := parsePackage(t, rel)
+		pkgPath := module + "/" + filepath.ToSlash(rel)
+		constants[pkgPath] = pkg.constants()
+
+		for _, file := range pkg.files {
+			ast.Inspect(file, func(n ast.Node) bool {
+				fn, ok := n.(*ast.FuncDecl)
+				if !ok || fn.Body == nil {
+					return true
+				}
+
+				ast.Inspect(fn.Body, func(n ast.Node) bool {
+					call, ok := n.(*ast.CallExpr)
+					if !ok || len(call.Args) < 2 {
+						return true
+					}
+
+					sel, ok := call.Fun.(*ast.SelectorExpr)
+					if !ok {
+						return true
+					}
+					switch sel.Sel.Name {
+					case "Send", "Broadcast", "AdminBroadcast", "InactiveCursorsBroadcast":
+					default:
+						return true
+					}
+
+					// event is either an event constant or a constant of the package itself
+					var name string
+					switch arg := call.Args[0].(type) {
+					case *ast.SelectorExpr:
+						if x, ok := arg.X.(*ast.Ident); ok && pkg.imports[file][x.Name] == eventPath {
+							name = constants[eventPath][arg.Sel.Name]
+						}
+					case *ast.Ident:
+						name = constants[pkgPath][arg.Name]
+					}
+					if name == "" {
+						return true
+					}
+
+					pos := pkg.fset.Position(call.Pos())
+					payload, ok := described[name]
+					if !ok {
+						t.Errorf("%s: event %q is sent but not described in server events", pos, name)
+						return true
+					}
+
+					if id, ok := call.Args[1].(*ast.Ident); ok && id.Name == "nil" {
+						if payload != nil {
+							t.Errorf("%s: event %q is sent without payload, described with %s", pos, name, valueTypeName(payload))
+						}
+						return true
+					}
+
+					sent := pkg.valueType(call.Args[1], fn.Body)
+					if sent == "" {
+						return true
+					}
+					if payload == nil {
+						t.Errorf("%s: event %q is sent with %s, described without payload", pos, name, sent)
+					} else if want := valueTypeName(payload); sent != want {
+						t.Errorf("%s: event %q is sent with %s, described with %s", pos, name, sent, want)
+					}
+					return true
+				})
+				return false
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unable to walk packages: %v", err)
+	}
+}
+
+// request and response bodies decoded and encoded by route handlers must match their operations,
+// bodies are only compared if their type can be told from the source of the handler
+func TestOperationPayloads(t *testing.T) {
+	sessions := session.New(&config.Session{})
+	apiManager := api.New(sessions, nil, nil, nil, nil)
+	plugins.New(&config.Plugins{}).Start(sessions, websocket.New(sessions, nil, nil, nil), apiManager)
+
+	router := newRouter("/api")
+	apiManager.Route(router)
+
+	packages := map[string]*goPackage{}
+	for _, r := range router.Routes() {
+		key := r.Method + " " + r.Path
+		op, ok := operations[key]
+		if !ok || !strings.HasPrefix(r.Package, module+"/") {
+			continue
+		}
+
+		pkg, ok := packages[r.Package]
+		if !ok {
+			pkg = parsePackage(t, strings.TrimPrefix(r.Package, module+"/"))
+			packages[r.Package] = pkg
+		}
+
+		var fn *ast.FuncDecl
+		for _, file := range pkg.files {
+			for _, decl := range file.Decls {
+				if d, ok := decl.(*ast.FuncDecl); ok && d.Name.Name == r.Handler && d.Body != nil {
+					fn = d
+				}
+			}
+		}
+		if fn == nil {
+			t.Errorf("route %q: handler %s not found in %s", key, r.Handler, r.Package)
+			continue
+		}
+
+		var requests, responses []string
+		ast.Inspect(fn.Body, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			sel, ok := call.Fun.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+
+			switch {
+			case sel.Sel.Name == "HttpJsonRequest" && len(call.Args) == 3:
+				if name := pkg.valueType(call.Args[2], fn.Body); name != "" {
+					requests = append(requests, name)
+				}
+			case sel.Sel.Name == "HttpSuccess" && len(call.Args) == 2:
+				if name := pkg.valueType(call.Args[1], fn.Body); name != "" {
+					responses = append(responses, name)
+				}
+			}
+			return true
+		})
+
+		for _, body := range []struct {
+			name   string
+			value  any
+			actual []string
+		}{
+			{"request", op.Request, requests},
+			{"response", op.Response, responses},
+		} {
+			if _, ok := body.value.(*Schema); ok || len(body.actual) == 0 {
+				continue
+			}
+			if body.value == nil {
+				t.Errorf("route %q: handler uses %s %v, operation describes none", key, body.name, body.actual)
+				continue
+			}
+			if want := valueTypeName(body.value); !slices.Contains(body.actual, want) {
+				t.Errorf("route %q: handler uses %s %v, operation describes %s", key, body.name, body.actual, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package estimator
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/m1k1o/neko/server/internal/config"
+)
+
+const DefaultStrategy = "trend"
+
+type Decision int
+
+const (
+	Hold Decision = iota
+	Upgrade
+	Downgrade
+)
+
+func (d Decision) String() string {
+	switch d {
+	case Hold:
+		return "hold"
+	case Upgrade:
+		return "upgrade"
+	case Downgrade:
+		return "downgrade"
+	default:
+		return fmt.Sprintf("%d", int(d))
+	}
+}
+
+func (d Decision) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// Sample is a single reading of the estimator, taken every read interval.
+type Sample struct {
+	Time time.Time `json:"time"`
+
+	// current video stream and its bitrate, zero when it is not known yet
+	StreamID      string `json:"stream_id"`
+	StreamBitrate uint64 `json:"stream_bitrate"`
+
+	// bitrates in bits per second, estimated by the congestion controller and reported by the receiver
+	TargetBitrate  int     `json:"target_bitrate"`
+	MaximumBitrate float64 `json:"maximum_bitrate"`
+
+	// from last receiver report and selected candidate pair, rtt is in milliseconds
+	FractionLost float64 `json:"fraction_lost"`
+	RTT          float64 `json:"rtt"`
+}
+
+// Strategy decides when video stream should be switched. It keeps its own state and
+// uses only time of samples, so that recorded traces can be replayed offline.
+type Strategy interface {
+	Next(sample Sample) Decision
+}
+
+var strategies = map[string]func(conf config.WebRTCEstimator, logger zerolog.Logger) Strategy{
+	"trend": newTrend,
+	"loss":  newLoss,
+	"rtt":   newRTT,
+	"remb":  newREMB,
+}
+
+func Strategies() []string {
+	names := make([]string, 0, len(strategies))
+	for name := range strategies {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func New(conf config.WebRTCEstimator, logger zerolog.Logger) (Strategy, error) {
+	fn, ok := strategies[conf.Strategy]
+	if !ok {
+		return nil, fmt.Errorf("unknown estimator strategy %q", conf.Strategy)
+	}
+
+	return fn(conf, logger), nil
+}
@@ -0,0 +1,225 @@
+package estimator
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/m1k1o/neko/server/internal/config"
+)
+
+var testConf = config.WebRTCEstimator{
+	StableDuration:   3 * time.Second,
+	UnstableDuration: 2 * time.Second,
+	StalledDuration:  5 * time.Second,
+	DowngradeBackoff: 5 * time.Second,
+	UpgradeBackoff:   5 * time.Second,
+	DiffThreshold:    0.15,
+	LossThreshold:    0.1,
+	RTTThreshold:     50 * time.Millisecond,
+}
+
+var testStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// run feeds samples to a new strategy one second apart and returns its decisions
+func run(t *testing.T, name string, samples []Sample) []Decision {
+	conf := testConf
+	conf.Strategy = name
+
+	strategy, err := New(conf, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("New() returned error: %s", err)
+	}
+
+	decisions := make([]Decision, 0, len(samples))
+	for i, sample := range samples {
+		sample.Time = testStart.Add(time.Duration(i) * time.Second)
+		decisions = append(decisions, strategy.Next(sample))
+	}
+
+	return decisions
+}
+
+// repeat returns n copies of the sample
+func repeat(sample Sample, n int) []Sample {
+	samples := make([]Sample, n)
+	for i := range samples {
+		samples[i] = sample
+	}
+	return samples
+}
+
+func TestNew(t *testing.T) {
+	for _, name := range Strategies() {
+		if _, err := New(config.WebRTCEstimator{Strategy: name}, zerolog.Nop()); err != nil {
+			t.Errorf("New(%q) returned error: %s", name, err)
+		}
+	}
+
+	if _, err := New(config.WebRTCEstimator{Strategy: "unknown"}, zerolog.Nop()); err == nil {
+		t.Errorf("New(%q) did not return error", "unknown")
+	}
+}
+
+func TestStrategies(t *testing.T) {
+	const H, U, D = Hold, Upgrade, Downgrade
+
+	// target bitrates decreasing by 100 kbps every second, from 1800 kbps
+	downward := []Sample{}
+	for i := 0; i < 10; i++ {
+		downward = append(downward, Sample{StreamID: "hd", StreamBitrate: 1000, TargetBitrate: 1800 - i*100})
+	}
+
+	tests := []struct {
+		name     string
+		strategy string
+		samples  []Sample
+		want     []Decision
+	}{
+		// trend
+		{
+			name:     "trend upgrades when stable with headroom",
+			strategy: "trend",
+			samples:  repeat(Sample{StreamID: "hd", StreamBitrate: 1000, TargetBitrate: 2000}, 5),
+			want:     []Decision{H, H, H, U, H},
+		},
+		{
+			name:     "trend downgrades when stalled without headroom",
+			strategy: "trend",
+			samples:  repeat(Sample{StreamID: "hd", StreamBitrate: 1000, TargetBitrate: 1000}, 6),
+			want:     []Decision{D, H, H, H, H, D},
+		},
+		{
+			name:     "trend downgrades on downward trend",
+			strategy: "trend",
+			samples:  downward,
+			want:     []Decision{H, H, H, U, H, H, H, H, D, H},
+		},
+		{
+			name:     "trend holds without stream",
+			strategy: "trend",
+			samples:  repeat(Sample{TargetBitrate: 2000}, 5),
+			want:     []Decision{H, H, H, H, H},
+		},
+		{
+			name:     "trend holds without stream bitrate",
+			strategy: "trend",
+			samples:  repeat(Sample{StreamID: "hd", TargetBitrate: 2000}, 5),
+			want:     []Decision{H, H, H, H, H},
+		},
+		// loss
+		{
+			name:     "loss downgrades after unstable duration with backoff",
+			strategy: "loss",
+			samples:  repeat(Sample{StreamID: "hd", FractionLost: 0.5}, 8),
+			want:     []Decision{H, H, D, H, H, H, H, D},
+		},
+		{
+			name:     "loss upgrades after stable duration with backoff",
+			strategy: "loss",
+			samples:  repeat(Sample{StreamID: "hd"}, 9),
+			want:     []Decision{H, H, H, U, H, H, H, H, U},
+		},
+		{
+			name:     "loss holds with loss below threshold",
+			strategy: "loss",
+			samples:  repeat(Sample{StreamID: "hd", FractionLost: 0.07}, 5),
+			want:     []Decision{H, H, H, H, H},
+		},
+		{
+			name:     "loss holds when congestion does not last",
+			strategy: "loss",
+			samples: []Sample{
+				{StreamID: "hd", FractionLost: 0.5},
+				{StreamID: "hd", FractionLost: 0.5},
+				{StreamID: "hd", FractionLost: 0},
+				{StreamID: "hd", FractionLost: 0.5},
+				{StreamID: "hd", FractionLost: 0.5},
+			},
+			want: []Decision{H, H, H, H, H},
+		},
+		{
+			name:     "loss holds without stream",
+			strategy: "loss",
+			samples:  repeat(Sample{FractionLost: 0.5}, 5),
+			want:     []Decision{H, H, H, H, H},
+		},
+		// rtt
+		{
+			name:     "rtt downgrades when rtt grows above minimum",
+			strategy: "rtt",
+			samples: []Sample{
+				{StreamID: "hd", StreamBitrate: 1000, TargetBitrate: 1000, RTT: 20},
+				{StreamID: "hd", StreamBitrate: 1000, TargetBitrate: 1000, RTT: 100},
+				{StreamID: "hd", StreamBitrate: 1000, TargetBitrate: 1000, RTT: 100},
+				{StreamID: "hd", StreamBitrate: 1000, TargetBitrate: 1000, RTT: 100},
+			},
+			want: []Decision{H, H, H, D},
+		},
+		{
+			name:     "rtt holds when rtt grows below threshold",
+			strategy: "rtt",
+			samples: []Sample{
+				{StreamID: "hd", StreamBitrate: 1000, TargetBitrate: 1000, RTT: 20},
+				{StreamID: "hd", StreamBitrate: 1000, TargetBitrate: 1000, RTT: 60},
+				{StreamID: "hd", StreamBitrate: 1000, TargetBitrate: 1000, RTT: 60},
+				{StreamID: "hd", StreamBitrate: 1000, TargetBitrate: 1000, RTT: 60},
+			},
+			want: []Decision{H, H, H, H},
+		},
+		{
+			name:     "rtt upgrades with target headroom",
+			strategy: "rtt",
+			samples:  repeat(Sample{StreamID: "hd", StreamBitrate: 1000, TargetBitrate: 2000, RTT: 20}, 4),
+			want:     []Decision{H, H, H, U},
+		},
+		{
+			name:     "rtt holds without target headroom",
+			strategy: "rtt",
+			samples:  repeat(Sample{StreamID: "hd", StreamBitrate: 1000, TargetBitrate: 1100, RTT: 20}, 4),
+			want:     []Decision{H, H, H, H},
+		},
+		{
+			name:     "rtt holds without rtt",
+			strategy: "rtt",
+			samples:  repeat(Sample{StreamID: "hd", StreamBitrate: 1000, TargetBitrate: 2000}, 4),
+			want:     []Decision{H, H, H, H},
+		},
+		// remb
+		{
+			name:     "remb downgrades below stream bitrate",
+			strategy: "remb",
+			samples:  repeat(Sample{StreamID: "hd", StreamBitrate: 1000, MaximumBitrate: 500}, 3),
+			want:     []Decision{H, H, D},
+		},
+		{
+			name:     "remb upgrades above diff threshold",
+			strategy: "remb",
+			samples:  repeat(Sample{StreamID: "hd", StreamBitrate: 1000, MaximumBitrate: 2000}, 4),
+			want:     []Decision{H, H, H, U},
+		},
+		{
+			name:     "remb holds within diff threshold",
+			strategy: "remb",
+			samples:  repeat(Sample{StreamID: "hd", StreamBitrate: 1000, MaximumBitrate: 1100}, 4),
+			want:     []Decision{H, H, H, H},
+		},
+		{
+			name:     "remb holds without remb",
+			strategy: "remb",
+			samples:  repeat(Sample{StreamID: "hd", StreamBitrate: 1000}, 4),
+			want:     []Decision{H, H, H, H},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := run(t, tt.strategy, tt.samples)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("decisions = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
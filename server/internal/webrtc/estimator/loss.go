@@ -0,0 +1,40 @@
+package estimator
+
+import (
+	"github.com/rs/zerolog"
+
+	"github.com/m1k1o/neko/server/internal/config"
+)
+
+// loss considers connection congested when receiver reports packet loss above the threshold,
+// so that it works even when the client does not provide any bandwidth estimation.
+type loss struct {
+	switcher
+}
+
+func newLoss(conf config.WebRTCEstimator, logger zerolog.Logger) Strategy {
+	return &loss{
+		switcher: switcher{
+			logger: logger,
+			conf:   conf,
+		},
+	}
+}
+
+func (s *loss) Next(sample Sample) Decision {
+	if sample.StreamID == "" {
+		return Hold
+	}
+
+	congested := sample.FractionLost > s.conf.LossThreshold
+	// upgrade only without any noticeable loss
+	headroom := sample.FractionLost <= s.conf.LossThreshold/2
+
+	s.logger.Info().
+		Float64("fraction_lost", sample.FractionLost).
+		Float64("threshold", s.conf.LossThreshold).
+		Bool("congested", congested).
+		Msg("got packet loss from receiver")
+
+	return s.next(sample.Time, congested, headroom)
+}
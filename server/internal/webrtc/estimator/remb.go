@@ -0,0 +1,42 @@
+package estimator
+
+import (
+	"github.com/rs/zerolog"
+
+	"github.com/m1k1o/neko/server/internal/config"
+)
+
+// remb relies only on maximum bitrate reported by the receiver, connection is congested
+// when it cannot accomodate current stream and there is headroom when it exceeds current
+// stream by the diff threshold.
+type remb struct {
+	switcher
+}
+
+func newREMB(conf config.WebRTCEstimator, logger zerolog.Logger) Strategy {
+	return &remb{
+		switcher: switcher{
+			logger: logger,
+			conf:   conf,
+		},
+	}
+}
+
+func (s *remb) Next(sample Sample) Decision {
+	// receiver might not support remb at all
+	if sample.StreamID == "" || sample.StreamBitrate == 0 || sample.MaximumBitrate == 0 {
+		return Hold
+	}
+
+	diff := sample.MaximumBitrate / float64(sample.StreamBitrate)
+	congested := diff < 1
+	headroom := diff > 1+s.conf.DiffThreshold
+
+	s.logger.Info().
+		Float64("diff", diff).
+		Float64("maximum_bitrate", sample.MaximumBitrate).
+		Uint64("stream_bitrate", sample.StreamBitrate).
+		Msg("got bitrate from receiver")
+
+	return s.next(sample.Time, congested, headroom)
+}
@@ -0,0 +1,128 @@
+package estimator
+
+import (
+	"cmp"
+	"slices"
+	"time"
+)
+
+type ReplayStream struct {
+	ID      string `json:"id"`
+	Bitrate uint64 `json:"bitrate"`
+}
+
+type ReplayEvent struct {
+	Time     time.Time `json:"time"`
+	Decision Decision  `json:"decision"`
+	From     string    `json:"from"`
+	To       string    `json:"to"`
+}
+
+type ReplayResult struct {
+	Streams []ReplayStream `json:"streams"`
+	Events  []ReplayEvent  `json:"events"`
+	// how long was each stream selected, in seconds
+	Durations map[string]float64 `json:"durations"`
+}
+
+// TraceStreams returns streams with their average bitrate recorded in the trace. Streams are
+// ordered by ids, from the highest to the lowest, or by their bitrate if ids are not given.
+func TraceStreams(trace []Sample, ids []string) []ReplayStream {
+	sums := map[string]uint64{}
+	counts := map[string]uint64{}
+	seen := []string{}
+	for _, sample := range trace {
+		if sample.StreamID == "" || sample.StreamBitrate == 0 {
+			continue
+		}
+		if _, ok := counts[sample.StreamID]; !ok {
+			seen = append(seen, sample.StreamID)
+		}
+		sums[sample.StreamID] += sample.StreamBitrate
+		counts[sample.StreamID]++
+	}
+
+	bitrate := func(id string) uint64 {
+		if counts[id] == 0 {
+			return 0
+		}
+		return sums[id] / counts[id]
+	}
+
+	if len(ids) == 0 {
+		ids = seen
+		slices.SortStableFunc(ids, func(a, b string) int {
+			return cmp.Compare(bitrate(b), bitrate(a))
+		})
+	}
+
+	streams := make([]ReplayStream, 0, len(ids))
+	for _, id := range ids {
+		streams = append(streams, ReplayStream{
+			ID:      id,
+			Bitrate: bitrate(id),
+		})
+	}
+
+	return streams
+}
+
+// Replay feeds recorded trace through the strategy, while it simulates switching between
+// streams ordered from the highest to the lowest. Recorded stream and its bitrate are
+// replaced by the simulated ones, but recorded estimates do not react to the switches.
+func Replay(strategy Strategy, trace []Sample, streams []ReplayStream) ReplayResult {
+	result := ReplayResult{
+		Streams:   streams,
+		Events:    []ReplayEvent{},
+		Durations: map[string]float64{},
+	}
+
+	current := -1
+	var currentSince time.Time
+
+	for _, sample := range trace {
+		// start with the stream, that was recorded first
+		if current == -1 {
+			current = slices.IndexFunc(streams, func(stream ReplayStream) bool {
+				return stream.ID == sample.StreamID
+			})
+			currentSince = sample.Time
+		}
+
+		if current != -1 {
+			sample.StreamID = streams[current].ID
+			sample.StreamBitrate = streams[current].Bitrate
+		}
+
+		decision := strategy.Next(sample)
+		if decision == Hold || current == -1 {
+			continue
+		}
+
+		next := current - 1
+		if decision == Downgrade {
+			next = current + 1
+		}
+
+		// already on the highest or the lowest stream
+		if next < 0 || next >= len(streams) {
+			continue
+		}
+
+		result.Events = append(result.Events, ReplayEvent{
+			Time:     sample.Time,
+			Decision: decision,
+			From:     streams[current].ID,
+			To:       streams[next].ID,
+		})
+
+		result.Durations[streams[current].ID] += sample.Time.Sub(currentSince).Seconds()
+		current, currentSince = next, sample.Time
+	}
+
+	if current != -1 && len(trace) > 0 {
+		result.Durations[streams[current].ID] += trace[len(trace)-1].Time.Sub(currentSince).Seconds()
+	}
+
+	return result
+}
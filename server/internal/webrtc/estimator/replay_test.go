@@ -0,0 +1,202 @@
+package estimator
+
+import (
+	"bytes"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// scripted returns given decisions, one for each sample, and records samples it got
+type scripted struct {
+	decisions []Decision
+	samples   []Sample
+}
+
+func (s *scripted) Next(sample Sample) Decision {
+	s.samples = append(s.samples, sample)
+	if len(s.samples) > len(s.decisions) {
+		return Hold
+	}
+	return s.decisions[len(s.samples)-1]
+}
+
+// trace returns samples one second apart, all recorded with the same stream
+func trace(id string, bitrate uint64, n int) []Sample {
+	samples := make([]Sample, n)
+	for i := range samples {
+		samples[i] = Sample{
+			Time:          testStart.Add(time.Duration(i) * time.Second),
+			StreamID:      id,
+			StreamBitrate: bitrate,
+		}
+	}
+	return samples
+}
+
+func TestTraceStreams(t *testing.T) {
+	samples := []Sample{
+		{StreamID: "sd", StreamBitrate: 900},
+		{StreamID: "hd", StreamBitrate: 2000},
+		{StreamID: "sd", StreamBitrate: 1100},
+		{StreamID: "hd", StreamBitrate: 0},
+		{StreamID: "", StreamBitrate: 500},
+		{StreamID: "hd", StreamBitrate: 3000},
+	}
+
+	tests := []struct {
+		name string
+		ids  []string
+		want []ReplayStream
+	}{
+		{
+			name: "ordered by bitrate",
+			want: []ReplayStream{
+				{ID: "hd", Bitrate: 2500},
+				{ID: "sd", Bitrate: 1000},
+			},
+		},
+		{
+			name: "ordered by ids",
+			ids:  []string{"sd", "hd", "ld"},
+			want: []ReplayStream{
+				{ID: "sd", Bitrate: 1000},
+				{ID: "hd", Bitrate: 2500},
+				{ID: "ld", Bitrate: 0},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := TraceStreams(samples, tt.ids)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("TraceStreams() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplay(t *testing.T) {
+	const H, U, D = Hold, Upgrade, Downgrade
+
+	streams := []ReplayStream{
+		{ID: "hd", Bitrate: 2000},
+		{ID: "md", Bitrate: 1000},
+		{ID: "sd", Bitrate: 500},
+	}
+
+	tests := []struct {
+		name      string
+		trace     []Sample
+		decisions []Decision
+		events    []ReplayEvent
+		durations map[string]float64
+	}{
+		{
+			name:      "switches between streams",
+			trace:     trace("md", 1200, 6),
+			decisions: []Decision{H, D, H, U, U, H},
+			events: []ReplayEvent{
+				{Time: testStart.Add(1 * time.Second), Decision: D, From: "md", To: "sd"},
+				{Time: testStart.Add(3 * time.Second), Decision: U, From: "sd", To: "md"},
+				{Time: testStart.Add(4 * time.Second), Decision: U, From: "md", To: "hd"},
+			},
+			durations: map[string]float64{"md": 2, "sd": 2, "hd": 1},
+		},
+		{
+			name:      "stays on the highest stream",
+			trace:     trace("hd", 2000, 3),
+			decisions: []Decision{U, U, H},
+			events:    []ReplayEvent{},
+			durations: map[string]float64{"hd": 2},
+		},
+		{
+			name:      "stays on the lowest stream",
+			trace:     trace("md", 1000, 4),
+			decisions: []Decision{D, D, D, H},
+			events: []ReplayEvent{
+				{Time: testStart, Decision: D, From: "md", To: "sd"},
+			},
+			durations: map[string]float64{"md": 0, "sd": 3},
+		},
+		{
+			name:      "ignores unknown stream",
+			trace:     trace("ld", 100, 3),
+			decisions: []Decision{D, U, D},
+			events:    []ReplayEvent{},
+			durations: map[string]float64{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Replay(&scripted{decisions: tt.decisions}, tt.trace, streams)
+			if !reflect.DeepEqual(result.Events, tt.events) {
+				t.Errorf("events = %v, want %v", result.Events, tt.events)
+			}
+			if !maps.Equal(result.Durations, tt.durations) {
+				t.Errorf("durations = %v, want %v", result.Durations, tt.durations)
+			}
+		})
+	}
+}
+
+func TestReplay_simulatedStream(t *testing.T) {
+	streams := []ReplayStream{
+		{ID: "hd", Bitrate: 2000},
+		{ID: "sd", Bitrate: 500},
+	}
+
+	strategy := &scripted{decisions: []Decision{Downgrade}}
+	Replay(strategy, trace("hd", 1800, 2), streams)
+
+	// recorded stream is replaced by the simulated one after the switch
+	want := []Sample{
+		{Time: testStart, StreamID: "hd", StreamBitrate: 2000},
+		{Time: testStart.Add(time.Second), StreamID: "sd", StreamBitrate: 500},
+	}
+	if !slices.Equal(strategy.samples, want) {
+		t.Errorf("samples = %v, want %v", strategy.samples, want)
+	}
+}
+
+func TestTrace(t *testing.T) {
+	samples := []Sample{
+		{Time: testStart, StreamID: "hd", StreamBitrate: 2000, TargetBitrate: 2500, MaximumBitrate: 3000, FractionLost: 0.01, RTT: 20},
+		{Time: testStart.Add(time.Second), StreamID: "sd", StreamBitrate: 500, TargetBitrate: 600},
+	}
+
+	var buf bytes.Buffer
+	writer := NewTraceWriter(&buf)
+	for _, sample := range samples {
+		if err := writer.Write(sample); err != nil {
+			t.Fatalf("Write() returned error: %s", err)
+		}
+	}
+
+	if lines := strings.Count(buf.String(), "\n"); lines != len(samples) {
+		t.Errorf("trace has %d lines, want %d", lines, len(samples))
+	}
+
+	got, err := ReadTrace(&buf)
+	if err != nil {
+		t.Fatalf("ReadTrace() returned error: %s", err)
+	}
+	if !slices.EqualFunc(got, samples, func(a, b Sample) bool {
+		return a.Time.Equal(b.Time) && a.StreamID == b.StreamID && a.StreamBitrate == b.StreamBitrate &&
+			a.TargetBitrate == b.TargetBitrate && a.MaximumBitrate == b.MaximumBitrate &&
+			a.FractionLost == b.FractionLost && a.RTT == b.RTT
+	}) {
+		t.Errorf("ReadTrace() = %v, want %v", got, samples)
+	}
+}
+
+func TestReadTrace_invalid(t *testing.T) {
+	if _, err := ReadTrace(strings.NewReader("{\"stream_id\":\"hd\"}\nnot json\n")); err == nil {
+		t.Errorf("ReadTrace() did not return error")
+	}
+}
@@ -0,0 +1,51 @@
+package estimator
+
+import (
+	"github.com/rs/zerolog"
+
+	"github.com/m1k1o/neko/server/internal/config"
+)
+
+// rtt considers connection congested when round-trip time grows above its minimum, which
+// happens when queues on the path fill up, usually before any packets are lost. Upgrade
+// requires target bitrate to accomodate current stream with the diff threshold.
+type rtt struct {
+	switcher
+
+	// lowest round-trip time seen, in milliseconds
+	minRTT float64
+}
+
+func newRTT(conf config.WebRTCEstimator, logger zerolog.Logger) Strategy {
+	return &rtt{
+		switcher: switcher{
+			logger: logger,
+			conf:   conf,
+		},
+	}
+}
+
+func (s *rtt) Next(sample Sample) Decision {
+	if sample.StreamID == "" || sample.StreamBitrate == 0 || sample.RTT == 0 {
+		return Hold
+	}
+
+	if s.minRTT == 0 || sample.RTT < s.minRTT {
+		s.minRTT = sample.RTT
+	}
+
+	threshold := float64(s.conf.RTTThreshold.Milliseconds())
+	congested := sample.RTT-s.minRTT > threshold
+
+	diff := float64(sample.TargetBitrate) / float64(sample.StreamBitrate)
+	headroom := diff > 1+s.conf.DiffThreshold
+
+	s.logger.Info().
+		Float64("rtt", sample.RTT).
+		Float64("min_rtt", s.minRTT).
+		Float64("diff", diff).
+		Bool("congested", congested).
+		Msg("got round-trip time from connection")
+
+	return s.next(sample.Time, congested, headroom)
+}
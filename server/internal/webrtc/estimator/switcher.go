@@ -0,0 +1,80 @@
+package estimator
+
+import (
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/m1k1o/neko/server/internal/config"
+)
+
+// switcher downgrades once congestion lasts for unstable duration and upgrades once there
+// was no congestion for stable duration and there is headroom, with backoff after each switch.
+type switcher struct {
+	logger zerolog.Logger
+	conf   config.WebRTCEstimator
+
+	// since when are we congested or not, zero when in the other state
+	congestedSince time.Time
+	clearSince     time.Time
+	// when was the last upgrade/downgrade
+	lastUpgradeTime   time.Time
+	lastDowngradeTime time.Time
+}
+
+func (s *switcher) next(now time.Time, congested, headroom bool) Decision {
+	conf := s.conf
+
+	if congested {
+		s.clearSince = time.Time{}
+		if s.congestedSince.IsZero() {
+			s.congestedSince = now
+		}
+
+		if now.Sub(s.congestedSince) < conf.UnstableDuration {
+			s.logger.Debug().
+				Time("congested_since", s.congestedSince).
+				Msgf("we are not congested long enough, waiting for at least %v", conf.UnstableDuration)
+			return Hold
+		}
+
+		if now.Sub(s.lastDowngradeTime) < conf.DowngradeBackoff {
+			s.logger.Debug().
+				Time("last_downgrade", s.lastDowngradeTime).
+				Msgf("downgraded recently, waiting for at least %v", conf.DowngradeBackoff)
+			return Hold
+		}
+
+		// lower stream needs its own time to show whether it is congested too
+		s.congestedSince = now
+		s.lastDowngradeTime = now
+		return Downgrade
+	}
+
+	s.congestedSince = time.Time{}
+	if s.clearSince.IsZero() {
+		s.clearSince = now
+	}
+
+	if now.Sub(s.clearSince) < conf.StableDuration {
+		s.logger.Debug().
+			Time("clear_since", s.clearSince).
+			Msgf("we are not stable long enough, waiting for at least %v", conf.StableDuration)
+		return Hold
+	}
+
+	if now.Sub(s.lastUpgradeTime) < conf.UpgradeBackoff {
+		s.logger.Debug().
+			Time("last_upgrade", s.lastUpgradeTime).
+			Msgf("upgraded recently, waiting for at least %v", conf.UpgradeBackoff)
+		return Hold
+	}
+
+	if !headroom {
+		s.logger.Debug().Msg("looks like we don't have enough headroom to accomodate higher stream")
+		return Hold
+	}
+
+	s.lastUpgradeTime = now
+	return Upgrade
+}
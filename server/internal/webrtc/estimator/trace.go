@@ -0,0 +1,40 @@
+package estimator
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// TraceWriter records samples as JSON lines.
+type TraceWriter struct {
+	enc *json.Encoder
+}
+
+func NewTraceWriter(w io.Writer) *TraceWriter {
+	return &TraceWriter{
+		enc: json.NewEncoder(w),
+	}
+}
+
+func (t *TraceWriter) Write(sample Sample) error {
+	return t.enc.Encode(sample)
+}
+
+func ReadTrace(r io.Reader) ([]Sample, error) {
+	dec := json.NewDecoder(r)
+
+	trace := []Sample{}
+	for {
+		var sample Sample
+		err := dec.Decode(&sample)
+		if errors.Is(err, io.EOF) {
+			return trace, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		trace = append(trace, sample)
+	}
+}
@@ -0,0 +1,168 @@
+package estimator
+
+import (
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/m1k1o/neko/server/internal/config"
+	"github.com/m1k1o/neko/server/pkg/utils"
+)
+
+// trend follows direction of target bitrate estimated by the congestion controller,
+// it downgrades on downward trend or when stalled and upgrades when stable.
+type trend struct {
+	logger zerolog.Logger
+	conf   config.WebRTCEstimator
+	trend  *utils.TrendDetector
+
+	started bool
+	// since when is the estimate stable/unstable
+	stableSince   time.Time
+	unstableSince time.Time
+	// since when are we neutral but cannot accomodate current bitrate
+	// we migt be stalled or estimator just reached zer (very bad connection)
+	stalledSince time.Time
+	// when was the last upgrade/downgrade
+	lastUpgradeTime   time.Time
+	lastDowngradeTime time.Time
+}
+
+func newTrend(conf config.WebRTCEstimator, logger zerolog.Logger) Strategy {
+	return &trend{
+		logger: logger,
+		conf:   conf,
+		trend: utils.NewTrendDetector(
+			utils.TrendDetectorParams{
+				// Probing
+				//RequiredSamples:        3,
+				//DownwardTrendThreshold: 0.0,
+				//CollapseValues:         false,
+				// Non-Probing
+				RequiredSamples:        8,
+				DownwardTrendThreshold: -0.5,
+				CollapseValues:         true,
+			}),
+	}
+}
+
+func (s *trend) Next(sample Sample) Decision {
+	conf, now := s.conf, sample.Time
+
+	// we asume stable at start
+	if !s.started {
+		s.started = true
+		s.stableSince = now
+	}
+
+	// get trend direction to decide if we should upgrade or downgrade
+	s.trend.AddValue(int64(sample.TargetBitrate))
+	direction := s.trend.GetDirection()
+
+	if sample.StreamID == "" {
+		s.logger.Warn().Msg("looks like we don't have a stream yet, skipping bitrate estimation")
+		return Hold
+	}
+
+	// if stream bitrate is 0, we need to wait for some time until we get a valid value
+	if sample.StreamBitrate == 0 {
+		s.logger.Warn().Msg("looks like stream bitrate is 0, we need to wait for some time")
+		return Hold
+	}
+
+	// check whats the difference between target and stream bitrate
+	diff := float64(sample.TargetBitrate) / float64(sample.StreamBitrate)
+
+	s.logger.Info().
+		Float64("diff", diff).
+		Int("target_bitrate", sample.TargetBitrate).
+		Uint64("stream_bitrate", sample.StreamBitrate).
+		Str("direction", direction.String()).
+		Msg("got bitrate from estimator")
+
+	// if we can accomodate current stream or we are not netural anymore,
+	// we are not stalled so we reset the stalled time
+	if direction != utils.TrendDirectionNeutral || diff > 1+conf.DiffThreshold {
+		s.stalledSince = now
+	}
+
+	// if we are neutral and stalled for too long, we might be congesting
+	stalled := direction == utils.TrendDirectionNeutral && now.Sub(s.stalledSince) > conf.StalledDuration
+	if stalled {
+		s.logger.Warn().
+			Time("stalled_since", s.stalledSince).
+			Msgf("it looks like we are stalled")
+	}
+
+	// if we have an downward trend or are stalled, we might be congesting
+	if direction == utils.TrendDirectionDownward || stalled {
+		// we reset the stable time because we are congesting
+		s.stableSince = now
+
+		// if we downgraded recently, we wait for some more time
+		if now.Sub(s.lastDowngradeTime) < conf.DowngradeBackoff {
+			s.logger.Debug().
+				Time("last_downgrade", s.lastDowngradeTime).
+				Msgf("downgraded recently, waiting for at least %v", conf.DowngradeBackoff)
+			return Hold
+		}
+
+		// if we are not unstable but we fluctuate we should wait for some more time
+		if now.Sub(s.unstableSince) < conf.UnstableDuration {
+			s.logger.Debug().
+				Time("unstable_since", s.unstableSince).
+				Msgf("we are not unstable long enough, waiting for at least %v", conf.UnstableDuration)
+			return Hold
+		}
+
+		// if we still have a big difference between target and stream bitrate, we wait for some more time
+		if conf.DiffThreshold >= 0 && diff > 1+conf.DiffThreshold {
+			s.logger.Debug().
+				Float64("diff", diff).
+				Float64("threshold", conf.DiffThreshold).
+				Msgf("we still have a big difference between target and stream bitrate, " +
+					"therefore we still should be able to accomodate current stream")
+			return Hold
+		}
+
+		s.lastDowngradeTime = now
+		return Downgrade
+	}
+
+	// we reset the unstable time because we are not congesting
+	s.unstableSince = now
+
+	// if we have a neutral or upward trend, that means our estimate is stable
+	// if we are on the highest stream, we don't need to do anything
+	// but if there is a higher stream, we should try to upgrade and see if it works
+
+	// if we upgraded recently, we wait for some more time
+	if now.Sub(s.lastUpgradeTime) < conf.UpgradeBackoff {
+		s.logger.Debug().
+			Time("last_upgrade", s.lastUpgradeTime).
+			Msgf("upgraded recently, waiting for at least %v", conf.UpgradeBackoff)
+		return Hold
+	}
+
+	// if we are not stable for long enough, we wait for some more time
+	// because bandwidth estimation might fluctuate
+	if now.Sub(s.stableSince) < conf.StableDuration {
+		s.logger.Debug().
+			Time("stable_since", s.stableSince).
+			Msgf("we are not stable long enough, waiting for at least %v", conf.StableDuration)
+		return Hold
+	}
+
+	// upgrade only if estimated bitrate passed the threshold
+	if conf.DiffThreshold >= 0 && diff < 1+conf.DiffThreshold {
+		s.logger.Debug().
+			Float64("diff", diff).
+			Float64("threshold", conf.DiffThreshold).
+			Msgf("looks like we don't have enough bitrate to accomodate higher stream, " +
+				"therefore we should wait for some more time")
+		return Hold
+	}
+
+	s.lastUpgradeTime = now
+	return Upgrade
+}
@@ -3,16 +3,35 @@ package webrtc
 import (
 	"bytes"
 	"encoding/binary"
+	"io"
 	"math"
 	"time"
 
 	"github.com/m1k1o/neko/server/internal/webrtc/payload"
 	"github.com/m1k1o/neko/server/pkg/types"
+	"github.com/m1k1o/neko/server/pkg/types/event"
 
 	"github.com/pion/webrtc/v4"
 	"github.com/rs/zerolog"
 )
 
+// data channel opcodes mapped to their websocket event equivalents, used for rate limiting,
+// releasing events are mapped too but they are never limited
+var opEvents = map[uint8]string{
+	payload.OP_MOVE:         event.CONTROL_MOVE,
+	payload.OP_SCROLL:       event.CONTROL_SCROLL,
+	payload.OP_KEY_DOWN:     event.CONTROL_KEYDOWN,
+	payload.OP_KEY_UP:       event.CONTROL_KEYUP,
+	payload.OP_BTN_DOWN:     event.CONTROL_BUTTONDOWN,
+	payload.OP_BTN_UP:       event.CONTROL_BUTTONUP,
+	payload.OP_TOUCH_BEGIN:  event.CONTROL_TOUCHBEGIN,
+	payload.OP_TOUCH_UPDATE: event.CONTROL_TOUCHUPDATE,
+	payload.OP_TOUCH_END:    event.CONTROL_TOUCHEND,
+	payload.OP_TEXT:         event.CONTROL_TEXT,
+	// shares rate limit with absolute movement
+	payload.OP_MOVE_RELATIVE: event.CONTROL_MOVE,
+}
+
 func (manager *WebRTCManagerCtx) handle(
 	logger zerolog.Logger, data []byte,
 	dataChannel *webrtc.DataChannel,
@@ -31,6 +50,11 @@ func (manager *WebRTCManagerCtx) handle(
 		return err
 	}
 
+	// drop events exceeding rate limit
+	if name, ok := opEvents[header.Event]; ok && !session.AllowEvent(name) {
+		return nil
+	}
+
 	//
 	// parse body
 	//
@@ -89,6 +113,15 @@ func (manager *WebRTCManagerCtx) handle(
 		}
 
 		return dataChannel.Send(buffer.Bytes())
+	} else if header.Event == payload.OP_HEARTBEAT_ACK {
+		heartbeat := &payload.Heartbeat{}
+		if err := binary.Read(buffer, binary.BigEndian, heartbeat); err != nil {
+			return err
+		}
+
+		sent := time.UnixMilli(int64(heartbeat.ServerTs()))
+		session.WebRTCHeartbeat(time.Since(sent))
+		return nil
 	}
 
 	// continue only if session is host
@@ -97,6 +130,21 @@ func (manager *WebRTCManagerCtx) handle(
 	}
 
 	switch header.Event {
+	case payload.OP_MOVE_RELATIVE:
+		payload := &payload.MoveRelative{}
+		if err := binary.Read(buffer, binary.BigEndian, payload); err != nil {
+			return err
+		}
+
+		manager.desktop.MoveRelative(int(payload.DeltaX), int(payload.DeltaY))
+
+		// resulting position is clamped by the screen, so it is read back
+		manager.curPosition.Set(manager.desktop.GetCursorPosition())
+
+		logger.Trace().
+			Int16("deltaX", payload.DeltaX).
+			Int16("deltaY", payload.DeltaY).
+			Msg("move relative")
 	case payload.OP_SCROLL:
 		// TODO: remove this once the client is fixed
 		if header.Length == 4 {
@@ -200,6 +248,17 @@ func (manager *WebRTCManagerCtx) handle(
 		} else {
 			logger.Trace().Uint32("touchId", payload.TouchId).Msg("touch end")
 		}
+	case payload.OP_TEXT:
+		text := make([]byte, header.Length)
+		if _, err := io.ReadFull(buffer, text); err != nil {
+			return err
+		}
+
+		if err := manager.desktop.TypeText(string(text)); err != nil {
+			logger.Warn().Err(err).Int("length", len(text)).Msg("text failed")
+		} else {
+			logger.Trace().Int("length", len(text)).Msg("text")
+		}
 	}
 
 	return nil
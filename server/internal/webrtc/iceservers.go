@@ -0,0 +1,74 @@
+package webrtc
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/pion/webrtc/v4"
+
+	"github.com/m1k1o/neko/server/pkg/types"
+)
+
+// user id for credentials of backend ice servers
+const backendUserId = "neko"
+
+// ICEServers returns ice servers sent to the client, including embedded turn server.
+// Servers with shared secret get ephemeral credentials bound to the session.
+func (manager *WebRTCManagerCtx) ICEServers(session types.Session) []types.ICEServer {
+	servers := make([]types.ICEServer, 0, len(manager.config.ICEServersFrontend)+len(manager.turnICEServers))
+	servers = append(servers, manager.config.ICEServersFrontend...)
+	servers = append(servers, manager.turnICEServers...)
+	return iceServersWithCredentials(servers, session.ID(), manager.config.ICECredentialTTL)
+}
+
+func (manager *WebRTCManagerCtx) backendICEServers() []webrtc.ICEServer {
+	servers := iceServersWithCredentials(manager.config.ICEServersBackend, backendUserId, manager.config.ICECredentialTTL)
+
+	ICEServers := []webrtc.ICEServer{}
+	for _, server := range servers {
+		var credential any
+		if server.Credential != "" {
+			credential = server.Credential
+		} else {
+			credential = false
+		}
+
+		ICEServers = append(ICEServers, webrtc.ICEServer{
+			URLs:       server.URLs,
+			Username:   server.Username,
+			Credential: credential,
+		})
+	}
+
+	return ICEServers
+}
+
+func iceServersWithCredentials(servers []types.ICEServer, userId string, ttl time.Duration) []types.ICEServer {
+	expires := time.Now().Add(ttl)
+
+	result := make([]types.ICEServer, 0, len(servers))
+	for _, server := range servers {
+		if server.Secret != "" {
+			server.Username, server.Credential = turnCredentials(server.Secret, userId, expires)
+		}
+		result = append(result, server)
+	}
+
+	return result
+}
+
+// turnCredentials generates time-limited credentials as in TURN REST API, that is
+// supported by coturn with use-auth-secret. Username consists of expiration timestamp
+// and user id, credential is base64 encoded HMAC-SHA1 of the username.
+func turnCredentials(secret, userId string, expires time.Time) (username, credential string) {
+	username = fmt.Sprintf("%d:%s", expires.Unix(), userId)
+
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write([]byte(username))
+	credential = base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	return username, credential
+}
@@ -0,0 +1,99 @@
+package webrtc
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/pion/webrtc/v4"
+	"github.com/rs/zerolog"
+
+	"github.com/m1k1o/neko/server/pkg/types"
+	"github.com/m1k1o/neko/server/pkg/types/codec"
+)
+
+// codecs that have webcam or microphone pipeline
+var ingestCodecs = []codec.RTPCodec{
+	codec.VP8(),
+	codec.VP9(),
+	codec.H264(),
+	codec.Opus(),
+	codec.G722(),
+}
+
+type WebRTCIngestPeerCtx struct {
+	logger     zerolog.Logger
+	connection *webrtc.PeerConnection
+
+	done     chan struct{}
+	doneOnce sync.Once
+}
+
+// CreateIngestPeer answers remote offer, such as from a WHIP publisher, with a peer that only
+// receives media. Candidates are not trickled, so that the answer contains all of them.
+func (manager *WebRTCManagerCtx) CreateIngestPeer(session types.Session, offer webrtc.SessionDescription) (*webrtc.SessionDescription, types.WebRTCIngestPeer, error) {
+	id := atomic.AddInt32(&manager.peerId, 1)
+
+	logger := manager.logger.With().Str("session_id", session.ID()).Int32("peer_id", id).Str("peer", "ingest").Logger()
+	logger.Info().Msg("creating webrtc ingest peer")
+
+	connection, _, err := manager.newPeerConnection(logger, ingestCodecs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	peer := &WebRTCIngestPeerCtx{
+		logger:     logger,
+		connection: connection,
+		done:       make(chan struct{}),
+	}
+
+	connection.OnTrack(func(track *webrtc.TrackRemote, receiver *webrtc.RTPReceiver) {
+		manager.handleRemoteTrack(logger, session, connection, track, receiver)
+	})
+
+	connection.OnConnectionStateChange(func(state webrtc.PeerConnectionState) {
+		switch state {
+		case webrtc.PeerConnectionStateDisconnected,
+			webrtc.PeerConnectionStateFailed:
+			peer.Destroy()
+		case webrtc.PeerConnectionStateClosed:
+			peer.doneOnce.Do(func() {
+				close(peer.done)
+			})
+		}
+	})
+
+	if err := connection.SetRemoteDescription(offer); err != nil {
+		peer.Destroy()
+		return nil, nil, err
+	}
+
+	answer, err := connection.CreateAnswer(nil)
+	if err != nil {
+		peer.Destroy()
+		return nil, nil, err
+	}
+
+	gatherComplete := webrtc.GatheringCompletePromise(connection)
+	if err := connection.SetLocalDescription(answer); err != nil {
+		peer.Destroy()
+		return nil, nil, err
+	}
+	<-gatherComplete
+
+	return connection.LocalDescription(), peer, nil
+}
+
+func (peer *WebRTCIngestPeerCtx) Done() <-chan struct{} {
+	return peer.done
+}
+
+func (peer *WebRTCIngestPeerCtx) Destroy() {
+	err := peer.connection.Close()
+	peer.logger.Err(err).Msg("peer connection destroyed")
+
+	// closed state is not reported when connection was never established
+	peer.doneOnce.Do(func() {
+		close(peer.done)
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"slices"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -15,6 +16,7 @@ import (
 	"github.com/pion/interceptor/pkg/cc"
 	"github.com/pion/interceptor/pkg/gcc"
 	"github.com/pion/rtcp"
+	"github.com/pion/turn/v4"
 	"github.com/pion/webrtc/v4"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
@@ -22,12 +24,12 @@ import (
 
 	"github.com/m1k1o/neko/server/internal/config"
 	"github.com/m1k1o/neko/server/internal/webrtc/cursor"
+	"github.com/m1k1o/neko/server/internal/webrtc/estimator"
 	"github.com/m1k1o/neko/server/internal/webrtc/pionlog"
 	"github.com/m1k1o/neko/server/pkg/types"
 	"github.com/m1k1o/neko/server/pkg/types/codec"
 	"github.com/m1k1o/neko/server/pkg/types/event"
 	"github.com/m1k1o/neko/server/pkg/types/message"
-	"github.com/m1k1o/neko/server/pkg/utils"
 )
 
 const (
@@ -48,6 +50,16 @@ const (
 
 	// send a PLI on an interval so that the publisher is pushing a keyframe every rtcpPLIInterval
 	rtcpPLIInterval = 3 * time.Second
+
+	// how often a heartbeat is sent over data channel to measure its round-trip time
+	heartbeatInterval = 10 * time.Second
+
+	// how many times ice restart is attempted before the peer is destroyed
+	iceRestartAttempts = 4
+
+	// delay before first ice restart, it doubles with every attempt up to iceRestartMaxBackoff
+	iceRestartBackoff    = 1 * time.Second
+	iceRestartMaxBackoff = 8 * time.Second
 )
 
 func New(desktop types.DesktopManager, capture types.CaptureManager, config *config.WebRTC) *WebRTCManagerCtx {
@@ -57,26 +69,6 @@ func New(desktop types.DesktopManager, capture types.CaptureManager, config *con
 		SDPSemantics: webrtc.SDPSemanticsUnifiedPlan,
 	}
 
-	if !config.ICELite {
-		ICEServers := []webrtc.ICEServer{}
-		for _, server := range config.ICEServersBackend {
-			var credential any
-			if server.Credential != "" {
-				credential = server.Credential
-			} else {
-				credential = false
-			}
-
-			ICEServers = append(ICEServers, webrtc.ICEServer{
-				URLs:       server.URLs,
-				Username:   server.Username,
-				Credential: credential,
-			})
-		}
-
-		configuration.ICEServers = ICEServers
-	}
-
 	return &WebRTCManagerCtx{
 		logger:  logger,
 		config:  config,
@@ -88,6 +80,8 @@ func New(desktop types.DesktopManager, capture types.CaptureManager, config *con
 		capture:     capture,
 		curImage:    cursor.NewImage(logger, desktop),
 		curPosition: cursor.NewPosition(logger),
+
+		micStops: map[string]*func(){},
 	}
 }
 
@@ -107,7 +101,14 @@ type WebRTCManagerCtx struct {
 	tcpMux ice.TCPMux
 	udpMux ice.UDPMux
 
+	turnServer     *turn.Server
+	turnICEServers []types.ICEServer
+
 	camStop, micStop *func()
+
+	// when microphone mixing is enabled, every session has its own input
+	micStops   map[string]*func()
+	micStopsMu sync.Mutex
 }
 
 func (manager *WebRTCManagerCtx) Start() {
@@ -146,6 +147,21 @@ func (manager *WebRTCManagerCtx) Start() {
 		}
 	}
 
+	// add embedded TURN server
+	if manager.config.TURN.Enabled {
+		if err := manager.startTURN(logger); err != nil {
+			manager.logger.Fatal().Err(err).Msg("unable to setup embedded TURN server")
+		}
+	}
+
+	// unknown strategy would leave every peer without estimator
+	if manager.config.Estimator.Enabled && !slices.Contains(estimator.Strategies(), manager.config.Estimator.Strategy) {
+		manager.logger.Warn().
+			Str("strategy", manager.config.Estimator.Strategy).
+			Msgf("unknown estimator strategy, using %s", estimator.DefaultStrategy)
+		manager.config.Estimator.Strategy = estimator.DefaultStrategy
+	}
+
 	manager.logger.Info().
 		Bool("icelite", manager.config.ICELite).
 		Bool("icetrickle", manager.config.ICETrickle).
@@ -155,6 +171,7 @@ func (manager *WebRTCManagerCtx) Start() {
 		Str("epr", fmt.Sprintf("%d-%d", manager.config.EphemeralMin, manager.config.EphemeralMax)).
 		Int("tcpmux", manager.config.TCPMux).
 		Int("udpmux", manager.config.UDPMux).
+		Bool("turn", manager.turnServer != nil).
 		Msg("webrtc starting")
 }
 
@@ -164,11 +181,11 @@ func (manager *WebRTCManagerCtx) Shutdown() error {
 	manager.curImage.Shutdown()
 	manager.curPosition.Shutdown()
 
-	return nil
-}
+	if manager.turnServer != nil {
+		return manager.turnServer.Close()
+	}
 
-func (manager *WebRTCManagerCtx) ICEServers() []types.ICEServer {
-	return manager.config.ICEServersFrontend
+	return nil
 }
 
 func (manager *WebRTCManagerCtx) newPeerConnection(logger zerolog.Logger, codecs []codec.RTPCodec) (*webrtc.PeerConnection, cc.BandwidthEstimator, error) {
@@ -251,6 +268,13 @@ func (manager *WebRTCManagerCtx) newPeerConnection(logger zerolog.Logger, codecs
 		estimatorChan <- nil
 	}
 
+	// audio level of received microphones is shown to admins
+	if manager.capture.Microphone().Mixing() {
+		if err := engine.RegisterHeaderExtension(webrtc.RTPHeaderExtensionCapability{URI: audioLevelURI}, webrtc.RTPCodecTypeAudio); err != nil {
+			return nil, nil, err
+		}
+	}
+
 	if err := webrtc.RegisterDefaultInterceptors(engine, registry); err != nil {
 		return nil, nil, err
 	}
@@ -264,11 +288,75 @@ func (manager *WebRTCManagerCtx) newPeerConnection(logger zerolog.Logger, codecs
 
 	// create new peer connection
 	configuration := manager.webrtcConfiguration
+	if !manager.config.ICELite {
+		configuration.ICEServers = manager.backendICEServers()
+	}
+
 	connection, err := api.NewPeerConnection(configuration)
 	return connection, <-estimatorChan, err
 }
 
-func (manager *WebRTCManagerCtx) CreatePeer(session types.Session) (*webrtc.SessionDescription, types.WebRTCPeer, error) {
+// videoForCodecs returns video of the first codec, that is supported by the client. Codecs
+// are in order of client's preference, default video is returned if none of them matches.
+func (manager *WebRTCManagerCtx) videoForCodecs(codecs []string) types.StreamSelectorManager {
+	videos := manager.capture.Videos()
+	for _, name := range codecs {
+		for _, video := range videos {
+			if strings.EqualFold(video.Codec().Name, name) {
+				return video
+			}
+		}
+	}
+
+	return manager.capture.Video()
+}
+
+// offerVideoCodecs returns names of video codecs in the offer, in order of preference
+func offerVideoCodecs(offer webrtc.SessionDescription) []string {
+	desc, err := offer.Unmarshal()
+	if err != nil {
+		return nil
+	}
+
+	codecs := []string{}
+	for _, media := range desc.MediaDescriptions {
+		if media.MediaName.Media != "video" {
+			continue
+		}
+
+		// rtpmap value is "<payload type> <encoding name>/<clock rate>"
+		names := map[string]string{}
+		for _, attr := range media.Attributes {
+			if attr.Key != "rtpmap" {
+				continue
+			}
+
+			payloadType, encoding, ok := strings.Cut(attr.Value, " ")
+			if !ok {
+				continue
+			}
+
+			name, _, _ := strings.Cut(encoding, "/")
+			names[payloadType] = name
+		}
+
+		// formats are payload types ordered by preference
+		for _, payloadType := range media.MediaName.Formats {
+			if name, ok := names[payloadType]; ok {
+				codecs = append(codecs, name)
+			}
+		}
+	}
+
+	return codecs
+}
+
+// CreatePeer creates a new peer for the session. Without a remote offer, local offer is created and
+// the peer is negotiated over websocket. With a remote offer, such as from a WHEP player, an answer
+// is returned instead. Such peer has no data channel, does not trickle candidates, does not
+// renegotiate on its own and does not replace the session peer, so that its lifetime is managed
+// by the caller. Video codec is chosen from the given codecs, or from the remote offer.
+func (manager *WebRTCManagerCtx) CreatePeer(session types.Session, offer *webrtc.SessionDescription, videoCodecs []string) (*webrtc.SessionDescription, types.WebRTCPeer, error) {
 	id := atomic.AddInt32(&manager.peerId, 1)
 
 	// get metrics for session
@@ -283,9 +371,13 @@ func (manager *WebRTCManagerCtx) CreatePeer(session types.Session) (*webrtc.Sess
 	audio := manager.capture.Audio()
 	audioCodec := audio.Codec()
 
-	// all videos must have the same codec
-	video := manager.capture.Video()
+	// all videos must have the same codec, it is chosen per peer
+	if len(videoCodecs) == 0 && offer != nil {
+		videoCodecs = offerVideoCodecs(*offer)
+	}
+	video := manager.videoForCodecs(videoCodecs)
 	videoCodec := video.Codec()
+	logger.Debug().Str("video_codec", videoCodec.Name).Msg("chosen video codec")
 
 	connection, estimator, err := manager.newPeerConnection(
 		logger, []codec.RTPCodec{audioCodec, videoCodec})
@@ -293,8 +385,11 @@ func (manager *WebRTCManagerCtx) CreatePeer(session types.Session) (*webrtc.Sess
 		return nil, nil, err
 	}
 
+	// candidates are trickled only over websocket
+	iceTrickle := manager.config.ICETrickle && offer == nil
+
 	// asynchronously send local ICE Candidates
-	if manager.config.ICETrickle {
+	if iceTrickle {
 		connection.OnICECandidate(func(candidate *webrtc.ICECandidate) {
 			if candidate == nil {
 				logger.Debug().Msg("all local ice candidates sent")
@@ -337,9 +432,12 @@ func (manager *WebRTCManagerCtx) CreatePeer(session types.Session) (*webrtc.Sess
 
 	// data channel
 
-	dataChannel, err := connection.CreateDataChannel("data", nil)
-	if err != nil {
-		return nil, nil, err
+	var dataChannel *webrtc.DataChannel
+	if offer == nil {
+		dataChannel, err = connection.CreateDataChannel("data", nil)
+		if err != nil {
+			return nil, nil, err
+		}
 	}
 
 	peer := &WebRTCPeerCtx{
@@ -349,17 +447,6 @@ func (manager *WebRTCManagerCtx) CreatePeer(session types.Session) (*webrtc.Sess
 		connection: connection,
 		// bandwidth estimator
 		estimator: estimator,
-		estimateTrend: utils.NewTrendDetector(
-			utils.TrendDetectorParams{
-				// Probing
-				//RequiredSamples:        3,
-				//DownwardTrendThreshold: 0.0,
-				//CollapseValues:         false,
-				// Non-Probing
-				RequiredSamples:        8,
-				DownwardTrendThreshold: -0.5,
-				CollapseValues:         true,
-			}),
 		// stream selectors
 		video: video,
 		audio: audio,
@@ -369,109 +456,15 @@ func (manager *WebRTCManagerCtx) CreatePeer(session types.Session) (*webrtc.Sess
 		dataChannel: dataChannel,
 		rtcpChannel: videoRtcp,
 		// config
-		iceTrickle:      manager.config.ICETrickle,
+		iceTrickle:      iceTrickle,
+		iceRestart:      offer == nil,
 		estimatorConfig: manager.config.Estimator,
 		audioDisabled:   true, // we disable audio by default manually
+		done:            make(chan struct{}),
 	}
 
 	connection.OnTrack(func(track *webrtc.TrackRemote, receiver *webrtc.RTPReceiver) {
-		logger := logger.With().
-			Str("kind", track.Kind().String()).
-			Str("mime", track.Codec().RTPCodecCapability.MimeType).
-			Logger()
-
-		logger.Info().Msgf("received new remote track")
-
-		if !session.Profile().CanShareMedia {
-			err := receiver.Stop()
-			logger.Warn().Err(err).Msg("media sharing is disabled for this session")
-			return
-		}
-
-		// parse codec from remote track
-		codec, ok := codec.ParseRTC(track.Codec())
-		if !ok {
-			err := receiver.Stop()
-			logger.Warn().Err(err).Msg("remote track with unknown codec")
-			return
-		}
-
-		var srcManager types.StreamSrcManager
-
-		stopped := false
-		stopFn := func() {
-			if stopped {
-				return
-			}
-
-			stopped = true
-			err := receiver.Stop()
-			srcManager.Stop()
-			logger.Err(err).Msg("remote track stopped")
-		}
-
-		if track.Kind() == webrtc.RTPCodecTypeAudio {
-			// audio -> microphone
-			srcManager = manager.capture.Microphone()
-			defer stopFn()
-
-			if manager.micStop != nil {
-				(*manager.micStop)()
-			}
-			manager.micStop = &stopFn
-		} else if track.Kind() == webrtc.RTPCodecTypeVideo {
-			// video -> webcam
-			srcManager = manager.capture.Webcam()
-			defer stopFn()
-
-			if manager.camStop != nil {
-				(*manager.camStop)()
-			}
-			manager.camStop = &stopFn
-		} else {
-			err := receiver.Stop()
-			logger.Warn().Err(err).Msg("remote track with unsupported codec type")
-			return
-		}
-
-		err := srcManager.Start(codec)
-		if err != nil {
-			logger.Err(err).Msg("failed to start pipeline")
-			return
-		}
-
-		ticker := time.NewTicker(rtcpPLIInterval)
-		defer ticker.Stop()
-
-		go func() {
-			for range ticker.C {
-				err := connection.WriteRTCP([]rtcp.Packet{
-					&rtcp.PictureLossIndication{
-						MediaSSRC: uint32(track.SSRC()),
-					},
-				})
-
-				if err != nil {
-					logger.Err(err).Msg("remote track rtcp send err")
-				}
-			}
-		}()
-
-		buf := make([]byte, 1400)
-		for {
-			i, _, err := track.Read(buf)
-			if err != nil {
-				// if the error is not io.EOF, log it. Otherwise, it's a normal closure of the track.
-				if !errors.Is(err, io.EOF) {
-					logger.Warn().Err(err).Msg("failed read from remote track")
-				}
-				break
-			}
-
-			srcManager.Push(buf[:i])
-		}
-
-		logger.Info().Msg("remote track data finished")
+		manager.handleRemoteTrack(logger, session, connection, track, receiver)
 	})
 
 	connection.OnDataChannel(func(dc *webrtc.DataChannel) {
@@ -499,11 +492,19 @@ func (manager *WebRTCManagerCtx) CreatePeer(session types.Session) (*webrtc.Sess
 	connection.OnConnectionStateChange(func(state webrtc.PeerConnectionState) {
 		switch state {
 		case webrtc.PeerConnectionStateConnected:
+			peer.recovered()
 			session.SetWebRTCConnected(peer, true)
 		case webrtc.PeerConnectionStateDisconnected,
 			webrtc.PeerConnectionStateFailed:
-			peer.Destroy()
+			// without signaling, ice cannot be restarted by the server
+			if peer.iceRestart {
+				peer.recover()
+			} else {
+				peer.Destroy()
+			}
 		case webrtc.PeerConnectionStateClosed:
+			peer.stopRecovery()
+
 			// ensure we only run this once
 			once.Do(func() {
 				session.SetWebRTCConnected(peer, false)
@@ -514,75 +515,115 @@ func (manager *WebRTCManagerCtx) CreatePeer(session types.Session) (*webrtc.Sess
 				videoTrack.Shutdown()
 				close(videoRtcp)
 			})
+
+			peer.doneOnce.Do(func() {
+				close(peer.done)
+			})
 		}
 
 		metrics.SetState(state)
 	})
 
-	dataChannel.OnOpen(func() {
-		manager.curImage.AddListener(peer)
-		manager.curPosition.AddListener(peer)
+	if dataChannel != nil {
+		heartbeatStop := make(chan struct{})
+
+		dataChannel.OnOpen(func() {
+			go func() {
+				ticker := time.NewTicker(heartbeatInterval)
+				defer ticker.Stop()
+
+				for {
+					select {
+					case <-heartbeatStop:
+						return
+					case <-ticker.C:
+						if err := peer.sendHeartbeat(); err != nil {
+							logger.Err(err).Msg("failed to send heartbeat")
+						}
+					}
+				}
+			}()
 
-		// send initial cursor image
-		cur, img, err := manager.curImage.GetCurrent()
-		if err == nil {
-			err := peer.SendCursorImage(cur, img)
-			if err != nil {
-				logger.Err(err).Msg("failed to set cursor image")
+			manager.curImage.AddListener(peer)
+			manager.curPosition.AddListener(peer)
+
+			// send initial cursor image
+			cur, img, err := manager.curImage.GetCurrent()
+			if err == nil {
+				err := peer.SendCursorImage(cur, img)
+				if err != nil {
+					logger.Err(err).Msg("failed to set cursor image")
+				}
+			} else {
+				logger.Err(err).Msg("failed to get cursor image")
 			}
-		} else {
-			logger.Err(err).Msg("failed to get cursor image")
-		}
 
-		// send initial cursor position
-		x, y := manager.desktop.GetCursorPosition()
-		err = peer.SendCursorPosition(x, y)
-		if err != nil {
-			logger.Err(err).Msg("failed to set cursor position")
-		}
-	})
+			// send initial cursor position
+			x, y := manager.desktop.GetCursorPosition()
+			err = peer.SendCursorPosition(x, y)
+			if err != nil {
+				logger.Err(err).Msg("failed to set cursor position")
+			}
+		})
 
-	dataChannel.OnClose(func() {
-		manager.curImage.RemoveListener(peer)
-		manager.curPosition.RemoveListener(peer)
-	})
+		dataChannel.OnClose(func() {
+			close(heartbeatStop)
 
-	dataChannel.OnMessage(func(message webrtc.DataChannelMessage) {
-		if err := manager.handle(logger, message.Data, dataChannel, session); err != nil {
-			logger.Err(err).Msg("data handle failed")
-		}
-	})
-
-	session.SetWebRTCPeer(peer)
+			manager.curImage.RemoveListener(peer)
+			manager.curPosition.RemoveListener(peer)
+		})
 
-	offer, err := peer.CreateOffer(false)
-	if err != nil {
-		return nil, nil, err
+		dataChannel.OnMessage(func(message webrtc.DataChannelMessage) {
+			if err := manager.handle(logger, message.Data, dataChannel, session); err != nil {
+				logger.Err(err).Msg("data handle failed")
+			}
+		})
 	}
 
-	// on negotiation needed handler must be registered after creating initial
-	// offer, otherwise it can fire and intercept sucessful negotiation
+	var description *webrtc.SessionDescription
 
-	connection.OnNegotiationNeeded(func() {
-		logger.Warn().Msg("negotiation is needed")
+	if offer != nil {
+		if err := peer.SetRemoteDescription(*offer); err != nil {
+			peer.Destroy()
+			return nil, nil, err
+		}
 
-		if connection.SignalingState() != webrtc.SignalingStateStable {
-			logger.Warn().Msg("connection isn't stable yet; postponing...")
-			return
+		description, err = peer.CreateAnswer()
+		if err != nil {
+			peer.Destroy()
+			return nil, nil, err
 		}
+	} else {
+		session.SetWebRTCPeer(peer)
 
-		offer, err := peer.CreateOffer(false)
+		description, err = peer.CreateOffer(false)
 		if err != nil {
-			logger.Err(err).Msg("sdp offer failed")
-			return
+			return nil, nil, err
 		}
 
-		session.Send(
-			event.SIGNAL_OFFER,
-			message.SignalDescription{
-				SDP: offer.SDP,
-			})
-	})
+		// on negotiation needed handler must be registered after creating initial
+		// offer, otherwise it can fire and intercept sucessful negotiation
+		connection.OnNegotiationNeeded(func() {
+			logger.Warn().Msg("negotiation is needed")
+
+			if connection.SignalingState() != webrtc.SignalingStateStable {
+				logger.Warn().Msg("connection isn't stable yet; postponing...")
+				return
+			}
+
+			offer, err := peer.CreateOffer(false)
+			if err != nil {
+				logger.Err(err).Msg("sdp offer failed")
+				return
+			}
+
+			session.Send(
+				event.SIGNAL_OFFER,
+				message.SignalDescription{
+					SDP: offer.SDP,
+				})
+		})
+	}
 
 	// start metrics collectors
 	go metrics.rtcpReceiver(videoRtcp)
@@ -591,9 +632,116 @@ func (manager *WebRTCManagerCtx) CreatePeer(session types.Session) (*webrtc.Sess
 	// start estimator reader
 	go peer.estimatorReader()
 
-	return offer, peer, nil
+	return description, peer, nil
 }
 
 func (manager *WebRTCManagerCtx) SetCursorPosition(x, y int) {
 	manager.curPosition.Set(x, y)
 }
+
+// handleRemoteTrack forwards media received from the client to the webcam or microphone.
+func (manager *WebRTCManagerCtx) handleRemoteTrack(logger zerolog.Logger, session types.Session, connection *webrtc.PeerConnection, track *webrtc.TrackRemote, receiver *webrtc.RTPReceiver) {
+	logger = logger.With().
+		Str("kind", track.Kind().String()).
+		Str("mime", track.Codec().RTPCodecCapability.MimeType).
+		Logger()
+
+	logger.Info().Msgf("received new remote track")
+
+	if !session.Profile().CanShareMedia {
+		err := receiver.Stop()
+		logger.Warn().Err(err).Msg("media sharing is disabled for this session")
+		return
+	}
+
+	// parse codec from remote track
+	codec, ok := codec.ParseRTC(track.Codec())
+	if !ok {
+		err := receiver.Stop()
+		logger.Warn().Err(err).Msg("remote track with unknown codec")
+		return
+	}
+
+	// audio -> microphone mixer
+	if track.Kind() == webrtc.RTPCodecTypeAudio && manager.capture.Microphone().Mixing() {
+		manager.handleMixedTrack(logger, session, track, receiver, codec)
+		return
+	}
+
+	var srcManager types.StreamSrcManager
+
+	stopped := false
+	stopFn := func() {
+		if stopped {
+			return
+		}
+
+		stopped = true
+		err := receiver.Stop()
+		srcManager.Stop()
+		logger.Err(err).Msg("remote track stopped")
+	}
+
+	if track.Kind() == webrtc.RTPCodecTypeAudio {
+		// audio -> microphone
+		srcManager = manager.capture.Microphone()
+		defer stopFn()
+
+		if manager.micStop != nil {
+			(*manager.micStop)()
+		}
+		manager.micStop = &stopFn
+	} else if track.Kind() == webrtc.RTPCodecTypeVideo {
+		// video -> webcam
+		srcManager = manager.capture.Webcam()
+		defer stopFn()
+
+		if manager.camStop != nil {
+			(*manager.camStop)()
+		}
+		manager.camStop = &stopFn
+	} else {
+		err := receiver.Stop()
+		logger.Warn().Err(err).Msg("remote track with unsupported codec type")
+		return
+	}
+
+	err := srcManager.Start(codec)
+	if err != nil {
+		logger.Err(err).Msg("failed to start pipeline")
+		return
+	}
+
+	ticker := time.NewTicker(rtcpPLIInterval)
+	defer ticker.Stop()
+
+	go func() {
+		for range ticker.C {
+			err := connection.WriteRTCP([]rtcp.Packet{
+				&rtcp.PictureLossIndication{
+					MediaSSRC: uint32(track.SSRC()),
+				},
+			})
+
+			if err != nil {
+				logger.Err(err).Msg("remote track rtcp send err")
+			}
+		}
+	}()
+
+	buf := make([]byte, 1400)
+	for {
+		i, _, err := track.Read(buf)
+		if err != nil {
+			// if the error is not io.EOF, log it. Otherwise, it's a normal closure of the track.
+			if !errors.Is(err, io.EOF) {
+				logger.Warn().Err(err).Msg("failed read from remote track")
+			}
+			break
+		}
+
+		srcManager.Push(buf[:i])
+	}
+
+	logger.Info().Msg("remote track data finished")
+}
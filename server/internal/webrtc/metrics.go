@@ -17,6 +17,13 @@ const (
 	connectionStatsInterval = 5 * time.Second
 )
 
+// results of ice restarts initiated by the server
+const (
+	iceRestartAttempt   = "attempt"
+	iceRestartSucceeded = "succeeded"
+	iceRestartFailed    = "failed"
+)
+
 type metricsManager struct {
 	mu sync.Mutex
 
@@ -40,6 +47,20 @@ func (m *metricsManager) getBySession(session types.Session) *metrics {
 		return met
 	}
 
+	iceRestarts := map[string]prometheus.Counter{}
+	for _, result := range []string{iceRestartAttempt, iceRestartSucceeded, iceRestartFailed} {
+		iceRestarts[result] = promauto.NewCounter(prometheus.CounterOpts{
+			Name:      "ice_restart_count",
+			Namespace: "neko",
+			Subsystem: "webrtc",
+			Help:      "Count of ICE restarts initiated by the server, by their result.",
+			ConstLabels: map[string]string{
+				"session_id": sessionId,
+				"result":     result,
+			},
+		})
+	}
+
 	met = &metrics{
 		sessionId: sessionId,
 
@@ -216,6 +237,10 @@ func (m *metricsManager) getBySession(session types.Session) *metrics {
 				"transport":  "sctp",
 			},
 		}),
+
+		iceRestarts: iceRestarts,
+
+		statsMu: &sync.Mutex{},
 	}
 
 	m.sessions[sessionId] = met
@@ -253,6 +278,12 @@ type metrics struct {
 	iceBytesReceived  prometheus.Gauge
 	sctpBytesSent     prometheus.Gauge
 	sctpBytesReceived prometheus.Gauge
+
+	iceRestarts map[string]prometheus.Counter
+
+	// same data as exported to prometheus, but for the api
+	stats   types.WebRTCStats
+	statsMu *sync.Mutex
 }
 
 func (met *metrics) reset() {
@@ -269,6 +300,34 @@ func (met *metrics) reset() {
 
 	met.receiverReportDelay.Set(0)
 	met.receiverReportJitter.Set(0)
+
+	met.statsMu.Lock()
+	met.stats = types.WebRTCStats{
+		State: met.stats.State,
+		Nacks: met.stats.Nacks,
+	}
+	met.statsMu.Unlock()
+}
+
+func (met *metrics) Stats() types.WebRTCStats {
+	met.statsMu.Lock()
+	defer met.statsMu.Unlock()
+
+	stats := met.stats
+	if stats.CandidatePair != nil {
+		pair := *stats.CandidatePair
+		stats.CandidatePair = &pair
+	}
+
+	return stats
+}
+
+func (met *metrics) updateStats(fn func(stats *types.WebRTCStats)) {
+	met.statsMu.Lock()
+	defer met.statsMu.Unlock()
+
+	fn(&met.stats)
+	met.stats.UpdatedAt = time.Now()
 }
 
 func (met *metrics) NewConnection() {
@@ -308,6 +367,10 @@ func (met *metrics) SetICECandidatesUsed(candidates []webrtc.ICECandidateStats)
 }
 
 func (met *metrics) SetState(state webrtc.PeerConnectionState) {
+	met.updateStats(func(stats *types.WebRTCStats) {
+		stats.State = state.String()
+	})
+
 	switch state {
 	case webrtc.PeerConnectionStateNew:
 		met.connectionState.Set(0)
@@ -329,6 +392,12 @@ func (met *metrics) SetState(state webrtc.PeerConnectionState) {
 	met.connectionStateCount.Add(1)
 }
 
+func (met *metrics) ICERestart(result string) {
+	if counter, ok := met.iceRestarts[result]; ok {
+		counter.Add(1)
+	}
+}
+
 func (met *metrics) SetVideoID(videoId string) {
 	met.videoIdsMu.Lock()
 	defer met.videoIdsMu.Unlock()
@@ -346,6 +415,10 @@ func (met *metrics) SetVideoID(videoId string) {
 		})
 	}
 
+	met.updateStats(func(stats *types.WebRTCStats) {
+		stats.VideoID = videoId
+	})
+
 	for id, entry := range met.videoIds {
 		if id == videoId {
 			entry.Set(1)
@@ -357,26 +430,60 @@ func (met *metrics) SetVideoID(videoId string) {
 
 func (met *metrics) SetReceiverEstimatedMaximumBitrate(bitrate float32) {
 	met.receiverEstimatedMaximumBitrate.Set(float64(bitrate))
+	met.updateStats(func(stats *types.WebRTCStats) {
+		stats.EstimatedMaximumBitrate = float64(bitrate)
+	})
 }
 
 func (met *metrics) SetReceiverEstimatedTargetBitrate(bitrate float64) {
 	met.receiverEstimatedTargetBitrate.Set(bitrate)
+	met.updateStats(func(stats *types.WebRTCStats) {
+		stats.EstimatedTargetBitrate = bitrate
+	})
 }
 
 func (met *metrics) SetReceiverReport(report rtcp.ReceptionReport) {
 	met.receiverReportDelay.Set(float64(report.Delay))
 	met.receiverReportJitter.Set(float64(report.Jitter))
 	met.receiverReportTotalLost.Set(float64(report.TotalLost))
+	met.updateStats(func(stats *types.WebRTCStats) {
+		stats.PacketsLost = report.TotalLost
+		stats.FractionLost = float64(report.FractionLost) / 256
+		stats.Jitter = report.Jitter
+	})
+}
+
+func (met *metrics) AddTransportLayerNacks(count int) {
+	met.transportLayerNacks.Add(float64(count))
+	met.updateStats(func(stats *types.WebRTCStats) {
+		stats.Nacks += uint64(count)
+	})
 }
 
 func (met *metrics) SetIceTransportStats(data webrtc.TransportStats) {
 	met.iceBytesSent.Set(float64(data.BytesSent))
 	met.iceBytesReceived.Set(float64(data.BytesReceived))
+	met.updateStats(func(stats *types.WebRTCStats) {
+		stats.BytesSent = data.BytesSent
+		stats.BytesReceived = data.BytesReceived
+	})
 }
 
 func (met *metrics) SetSctpTransportStats(data webrtc.TransportStats) {
 	met.sctpBytesSent.Set(float64(data.BytesSent))
 	met.sctpBytesReceived.Set(float64(data.BytesReceived))
+	met.updateStats(func(stats *types.WebRTCStats) {
+		stats.DataBytesSent = data.BytesSent
+		stats.DataBytesReceived = data.BytesReceived
+	})
+}
+
+func (met *metrics) SetCandidatePair(pair *types.WebRTCCandidatePair, rtt float64, remoteCandidates int) {
+	met.updateStats(func(stats *types.WebRTCStats) {
+		stats.CandidatePair = pair
+		stats.RTT = rtt
+		stats.RemoteCandidates = remoteCandidates
+	})
 }
 
 //
@@ -404,7 +511,7 @@ func (met *metrics) rtcpReceiver(rtcpCh chan []rtcp.Packet) {
 			case *rtcp.TransportLayerNack:
 				for _, pair := range rtcpPacket.Nacks {
 					packetList := pair.PacketList()
-					met.transportLayerNacks.Add(float64(len(packetList)))
+					met.AddTransportLayerNacks(len(packetList))
 				}
 			}
 		}
@@ -432,11 +539,17 @@ func (met *metrics) connectionStats(connection *webrtc.PeerConnection) {
 			met.SetSctpTransportStats(data)
 		}
 
+		localCandidates := map[string]webrtc.ICECandidateStats{}
 		remoteCandidates := map[string]webrtc.ICECandidateStats{}
 		nominatedRemoteCandidates := map[string]struct{}{}
+		var selectedPair *webrtc.ICECandidatePairStats
 		for _, entry := range stats {
-			// only remote ice candidate stats
 			candidate, ok := entry.(webrtc.ICECandidateStats)
+			if ok && candidate.Type == webrtc.StatsTypeLocalCandidate {
+				localCandidates[candidate.ID] = candidate
+			}
+
+			// only remote ice candidate stats
 			if ok && candidate.Type == webrtc.StatsTypeRemoteCandidate {
 				met.NewICECandidate(candidate)
 				remoteCandidates[candidate.ID] = candidate
@@ -446,9 +559,30 @@ func (met *metrics) connectionStats(connection *webrtc.PeerConnection) {
 			pair, ok := entry.(webrtc.ICECandidatePairStats)
 			if ok && pair.Nominated {
 				nominatedRemoteCandidates[pair.RemoteCandidateID] = struct{}{}
+
+				// succeeded pair is the one being used
+				if selectedPair == nil || pair.State == webrtc.StatsICECandidatePairStateSucceeded {
+					selectedPair = &pair
+				}
+			}
+		}
+
+		var candidatePair *types.WebRTCCandidatePair
+		var rtt float64
+		if selectedPair != nil {
+			local, localOk := localCandidates[selectedPair.LocalCandidateID]
+			remote, remoteOk := remoteCandidates[selectedPair.RemoteCandidateID]
+			if localOk && remoteOk {
+				candidatePair = &types.WebRTCCandidatePair{
+					Local:  candidateStats(local),
+					Remote: candidateStats(remote),
+				}
 			}
+			rtt = selectedPair.CurrentRoundTripTime * 1000
 		}
 
+		met.SetCandidatePair(candidatePair, rtt, len(remoteCandidates))
+
 		iceCandidatesUsed := []webrtc.ICECandidateStats{}
 		for id := range nominatedRemoteCandidates {
 			if candidate, ok := remoteCandidates[id]; ok {
@@ -459,3 +593,12 @@ func (met *metrics) connectionStats(connection *webrtc.PeerConnection) {
 		met.SetICECandidatesUsed(iceCandidatesUsed)
 	}
 }
+
+func candidateStats(candidate webrtc.ICECandidateStats) types.WebRTCCandidate {
+	return types.WebRTCCandidate{
+		Type:     candidate.CandidateType.String(),
+		Protocol: candidate.Protocol,
+		Address:  candidate.IP,
+		Port:     candidate.Port,
+	}
+}
@@ -0,0 +1,102 @@
+package webrtc
+
+import (
+	"errors"
+	"io"
+	"math"
+	"sync"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v4"
+	"github.com/rs/zerolog"
+
+	"github.com/m1k1o/neko/server/pkg/types"
+	"github.com/m1k1o/neko/server/pkg/types/codec"
+)
+
+// RFC 6464 header extension, browsers send it with every audio packet
+const audioLevelURI = "urn:ietf:params:rtp-hdrext:ssrc-audio-level"
+
+// handleMixedTrack forwards microphone of the session to its own input of the mixer,
+// while previous track of the same session is stopped.
+func (manager *WebRTCManagerCtx) handleMixedTrack(logger zerolog.Logger, session types.Session, track *webrtc.TrackRemote, receiver *webrtc.RTPReceiver, codec codec.RTPCodec) {
+	microphone := manager.capture.Microphone()
+	id := session.ID()
+
+	// called either by replacing track or when this track ends
+	var stopOnce sync.Once
+	stopFn := func() {
+		stopOnce.Do(func() {
+			err := receiver.Stop()
+			microphone.RemoveInput(id)
+			logger.Err(err).Msg("remote track stopped")
+		})
+	}
+
+	manager.micStopsMu.Lock()
+	if stop, ok := manager.micStops[id]; ok {
+		(*stop)()
+	}
+	manager.micStops[id] = &stopFn
+	manager.micStopsMu.Unlock()
+
+	defer func() {
+		stopFn()
+
+		manager.micStopsMu.Lock()
+		if manager.micStops[id] == &stopFn {
+			delete(manager.micStops, id)
+		}
+		manager.micStopsMu.Unlock()
+	}()
+
+	if err := microphone.AddInput(id, codec); err != nil {
+		logger.Err(err).Msg("failed to add microphone input")
+		return
+	}
+
+	var levelExtID uint8
+	for _, ext := range receiver.GetParameters().HeaderExtensions {
+		if ext.URI == audioLevelURI {
+			levelExtID = uint8(ext.ID)
+		}
+	}
+
+	header := rtp.Header{}
+	buf := make([]byte, 1400)
+	for {
+		i, _, err := track.Read(buf)
+		if err != nil {
+			// if the error is not io.EOF, log it. Otherwise, it's a normal closure of the track.
+			if !errors.Is(err, io.EOF) {
+				logger.Warn().Err(err).Msg("failed read from remote track")
+			}
+			break
+		}
+
+		microphone.PushInput(id, buf[:i])
+
+		if levelExtID == 0 {
+			continue
+		}
+
+		if _, err := header.Unmarshal(buf[:i]); err != nil {
+			continue
+		}
+
+		ext := header.GetExtension(levelExtID)
+		if ext == nil {
+			continue
+		}
+
+		level := rtp.AudioLevelExtension{}
+		if err := level.Unmarshal(ext); err != nil {
+			continue
+		}
+
+		// level is in -dBov, from 0 (loudest) to 127 (silence)
+		microphone.SetInputLevel(id, math.Pow(10, -float64(level.Level)/20))
+	}
+
+	logger.Info().Msg("remote track data finished")
+}
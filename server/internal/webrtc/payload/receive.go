@@ -14,6 +14,12 @@ const (
 	OP_TOUCH_BEGIN  = 0x08
 	OP_TOUCH_UPDATE = 0x09
 	OP_TOUCH_END    = 0x0a
+	// echo of server heartbeat
+	OP_HEARTBEAT_ACK = 0x0b
+	// utf-8 text, header length is its size in bytes
+	OP_TEXT = 0x0c
+	// pointer movement relative to its current position
+	OP_MOVE_RELATIVE = 0x0d
 )
 
 type Move struct {
@@ -21,6 +27,11 @@ type Move struct {
 	Y uint16
 }
 
+type MoveRelative struct {
+	DeltaX int16
+	DeltaY int16
+}
+
 // TODO: remove this once the client is fixed
 type Scroll_Old struct {
 	X int16
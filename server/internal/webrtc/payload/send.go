@@ -6,6 +6,9 @@ const (
 	OP_CURSOR_POSITION = 0x01
 	OP_CURSOR_IMAGE    = 0x02
 	OP_PONG            = 0x03
+	OP_HEARTBEAT       = 0x04
+	// batched positions of inactive cursors, see InactiveCursors
+	OP_INACTIVE_CURSORS = 0x05
 )
 
 type CursorPosition struct {
@@ -13,6 +16,12 @@ type CursorPosition struct {
 	Y uint16
 }
 
+// InactiveCursors is followed by its sessions, each as id length (uint8),
+// id, cursors count (uint8) and cursors encoded as CursorPosition.
+type InactiveCursors struct {
+	Sessions uint16
+}
+
 type CursorImage struct {
 	Width  uint16
 	Height uint16
@@ -31,3 +40,14 @@ type Pong struct {
 func (p Pong) ServerTs() uint64 {
 	return (uint64(p.ServerTs1) * uint64(math.MaxUint32)) + uint64(p.ServerTs2)
 }
+
+// sent by server and echoed back by client as OP_HEARTBEAT_ACK
+type Heartbeat struct {
+	// server's timestamp split into two uint32
+	ServerTs1 uint32
+	ServerTs2 uint32
+}
+
+func (p Heartbeat) ServerTs() uint64 {
+	return (uint64(p.ServerTs1) * uint64(math.MaxUint32)) + uint64(p.ServerTs2)
+}
@@ -3,6 +3,11 @@ package webrtc
 import (
 	"bytes"
 	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
 	"sync"
 	"time"
 
@@ -12,12 +17,15 @@ import (
 	"github.com/rs/zerolog"
 
 	"github.com/m1k1o/neko/server/internal/config"
+	"github.com/m1k1o/neko/server/internal/webrtc/estimator"
 	"github.com/m1k1o/neko/server/internal/webrtc/payload"
 	"github.com/m1k1o/neko/server/pkg/types"
 	"github.com/m1k1o/neko/server/pkg/types/event"
-	"github.com/m1k1o/neko/server/pkg/utils"
 )
 
+// session id contains username, that must not escape trace directory
+var traceNameReplacer = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
 type WebRTCPeerCtx struct {
 	mu         sync.Mutex
 	logger     zerolog.Logger
@@ -25,8 +33,7 @@ type WebRTCPeerCtx struct {
 	metrics    *metrics
 	connection *webrtc.PeerConnection
 	// bandwidth estimator
-	estimator     cc.BandwidthEstimator
-	estimateTrend *utils.TrendDetector
+	estimator cc.BandwidthEstimator
 	// stream selectors
 	video types.StreamSelectorManager
 	audio types.StreamSinkManager
@@ -37,11 +44,20 @@ type WebRTCPeerCtx struct {
 	rtcpChannel chan []rtcp.Packet
 	// config
 	iceTrickle      bool
+	iceRestart      bool
 	estimatorConfig config.WebRTCEstimator
 	paused          bool
 	videoAuto       bool
 	videoDisabled   bool
 	audioDisabled   bool
+	inactiveCursors bool
+	// ice restart initiated by the server
+	recoveryMu        sync.Mutex
+	recoveryStop      chan struct{}
+	recoveryAttempted bool
+	// closed when the peer connection is closed
+	done     chan struct{}
+	doneOnce sync.Once
 }
 
 //
@@ -107,6 +123,10 @@ func (peer *WebRTCPeerCtx) SetCandidate(candidate webrtc.ICECandidateInit) error
 	return peer.connection.AddICECandidate(candidate)
 }
 
+func (peer *WebRTCPeerCtx) Stats() types.WebRTCStats {
+	return peer.metrics.Stats()
+}
+
 // TODO: Add shutdown function?
 func (peer *WebRTCPeerCtx) Destroy() {
 	peer.mu.Lock()
@@ -120,6 +140,15 @@ func (peer *WebRTCPeerCtx) Destroy() {
 	}
 
 	peer.logger.Err(err).Msg("peer connection destroyed")
+
+	// closed state is not reported when connection was never established
+	peer.doneOnce.Do(func() {
+		close(peer.done)
+	})
+}
+
+func (peer *WebRTCPeerCtx) Done() <-chan struct{} {
+	return peer.done
 }
 
 func (peer *WebRTCPeerCtx) estimatorReader() {
@@ -128,7 +157,7 @@ func (peer *WebRTCPeerCtx) estimatorReader() {
 	// if estimator is not in debug mode, use a nop logger
 	var debugLogger zerolog.Logger
 	if conf.Debug {
-		debugLogger = peer.logger.With().Str("component", "estimator").Logger().Level(zerolog.DebugLevel)
+		debugLogger = peer.logger.With().Str("component", "estimator").Str("strategy", conf.Strategy).Logger().Level(zerolog.DebugLevel)
 	} else {
 		debugLogger = zerolog.Nop()
 	}
@@ -138,20 +167,30 @@ func (peer *WebRTCPeerCtx) estimatorReader() {
 		return
 	}
 
+	strategy, err := estimator.New(conf, debugLogger)
+	if err != nil {
+		peer.logger.Err(err).Msg("unable to create estimator strategy")
+		return
+	}
+
+	// record samples, so that they can be replayed offline
+	var trace *estimator.TraceWriter
+	if conf.TraceDir != "" {
+		id := traceNameReplacer.ReplaceAllString(peer.session.ID(), "_")
+		name := fmt.Sprintf("%s-%d.jsonl", id, time.Now().UnixMilli())
+		file, err := os.Create(filepath.Join(conf.TraceDir, name))
+		if err != nil {
+			peer.logger.Err(err).Msg("unable to create estimator trace")
+		} else {
+			defer file.Close()
+			trace = estimator.NewTraceWriter(file)
+		}
+	}
+
 	// use a ticker to get current client target bitrate
 	ticker := time.NewTicker(conf.ReadInterval)
 	defer ticker.Stop()
 
-	// since when is the estimate stable/unstable
-	stableSince := time.Now() // we asume stable at start
-	unstableSince := time.Time{}
-	// since when are we neutral but cannot accomodate current bitrate
-	// we migt be stalled or estimator just reached zer (very bad connection)
-	stalledSince := time.Time{}
-	// when was the last upgrade/downgrade
-	lastUpgradeTime := time.Time{}
-	lastDowngradeTime := time.Time{}
-
 	for range ticker.C {
 		targetBitrate := peer.estimator.GetTargetBitrate()
 		peer.metrics.SetReceiverEstimatedTargetBitrate(float64(targetBitrate))
@@ -161,152 +200,61 @@ func (peer *WebRTCPeerCtx) estimatorReader() {
 			break
 		}
 
-		// if estimation or video is disabled, do nothing
-		if !peer.videoAuto || peer.videoDisabled || peer.paused || conf.Passive {
-			continue
-		}
-
-		// get trend direction to decide if we should upgrade or downgrade
-		peer.estimateTrend.AddValue(int64(targetBitrate))
-		direction := peer.estimateTrend.GetDirection()
-
-		// get current stream bitrate
-		stream, ok := peer.videoTrack.Stream()
-		if !ok {
-			debugLogger.Warn().Msg("looks like we don't have a stream yet, skipping bitrate estimation")
-			continue
-		}
-
-		// if stream bitrate is 0, we need to wait for some time until we get a valid value
-		streamId, streamBitrate := stream.ID(), stream.Bitrate()
-		if streamBitrate == 0 {
-			debugLogger.Warn().Msg("looks like stream bitrate is 0, we need to wait for some time")
+		// if video is not being sent, there is nothing to estimate
+		if peer.videoDisabled || peer.paused {
 			continue
 		}
 
-		// check whats the difference between target and stream bitrate
-		diff := float64(targetBitrate) / float64(streamBitrate)
-
-		debugLogger.Info().
-			Float64("diff", diff).
-			Int("target_bitrate", targetBitrate).
-			Uint64("stream_bitrate", streamBitrate).
-			Str("direction", direction.String()).
-			Msg("got bitrate from estimator")
-
-		// if we can accomodate current stream or we are not netural anymore,
-		// we are not stalled so we reset the stalled time
-		if direction != utils.TrendDirectionNeutral || diff > 1+conf.DiffThreshold {
-			stalledSince = time.Now()
+		stats := peer.metrics.Stats()
+		sample := estimator.Sample{
+			Time:           time.Now(),
+			TargetBitrate:  targetBitrate,
+			MaximumBitrate: stats.EstimatedMaximumBitrate,
+			FractionLost:   stats.FractionLost,
+			RTT:            stats.RTT,
 		}
 
-		// if we are neutral and stalled for too long, we might be congesting
-		stalled := direction == utils.TrendDirectionNeutral && time.Since(stalledSince) > conf.StalledDuration
-		if stalled {
-			debugLogger.Warn().
-				Time("stalled_since", stalledSince).
-				Msgf("it looks like we are stalled")
+		// get current stream bitrate
+		if stream, ok := peer.videoTrack.Stream(); ok {
+			sample.StreamID, sample.StreamBitrate = stream.ID(), stream.Bitrate()
 		}
 
-		// if we have an downward trend or are stalled, we might be congesting
-		if direction == utils.TrendDirectionDownward || stalled {
-			// we reset the stable time because we are congesting
-			stableSince = time.Now()
-
-			// if we downgraded recently, we wait for some more time
-			if time.Since(lastDowngradeTime) < conf.DowngradeBackoff {
-				debugLogger.Debug().
-					Time("last_downgrade", lastDowngradeTime).
-					Msgf("downgraded recently, waiting for at least %v", conf.DowngradeBackoff)
-				continue
-			}
-
-			// if we are not unstable but we fluctuate we should wait for some more time
-			if time.Since(unstableSince) < conf.UnstableDuration {
-				debugLogger.Debug().
-					Time("unstable_since", unstableSince).
-					Msgf("we are not unstable long enough, waiting for at least %v", conf.UnstableDuration)
-				continue
-			}
-
-			// if we still have a big difference between target and stream bitrate, we wait for some more time
-			if conf.DiffThreshold >= 0 && diff > 1+conf.DiffThreshold {
-				debugLogger.Debug().
-					Float64("diff", diff).
-					Float64("threshold", conf.DiffThreshold).
-					Msgf("we still have a big difference between target and stream bitrate, " +
-						"therefore we still should be able to accomodate current stream")
-				continue
-			}
-
-			err := peer.SetVideo(types.PeerVideoRequest{
-				Selector: &types.StreamSelector{
-					ID:   streamId,
-					Type: types.StreamSelectorTypeLower,
-				},
-			})
-			if err != nil && err != types.ErrWebRTCStreamNotFound {
-				peer.logger.Warn().Err(err).Msg("failed to downgrade video stream")
+		if trace != nil {
+			if err := trace.Write(sample); err != nil {
+				peer.logger.Err(err).Msg("unable to write estimator trace")
+				trace = nil
 			}
-			lastDowngradeTime = time.Now()
-
-			if err == types.ErrWebRTCStreamNotFound {
-				debugLogger.Info().Msg("looks like we are already on the lowest stream")
-			} else {
-				debugLogger.Info().Msg("downgraded video stream")
-			}
-			continue
-		}
-
-		// we reset the unstable time because we are not congesting
-		unstableSince = time.Now()
-
-		// if we have a neutral or upward trend, that means our estimate is stable
-		// if we are on the highest stream, we don't need to do anything
-		// but if there is a higher stream, we should try to upgrade and see if it works
-
-		// if we upgraded recently, we wait for some more time
-		if time.Since(lastUpgradeTime) < conf.UpgradeBackoff {
-			debugLogger.Debug().
-				Time("last_upgrade", lastUpgradeTime).
-				Msgf("upgraded recently, waiting for at least %v", conf.UpgradeBackoff)
-			continue
 		}
 
-		// if we are not stable for long enough, we wait for some more time
-		// because bandwidth estimation might fluctuate
-		if time.Since(stableSince) < conf.StableDuration {
-			debugLogger.Debug().
-				Time("stable_since", stableSince).
-				Msgf("we are not stable long enough, waiting for at least %v", conf.StableDuration)
+		// if estimation is disabled, do nothing
+		if !peer.videoAuto || conf.Passive {
 			continue
 		}
 
-		// upgrade only if estimated bitrate passed the threshold
-		if conf.DiffThreshold >= 0 && diff < 1+conf.DiffThreshold {
-			debugLogger.Debug().
-				Float64("diff", diff).
-				Float64("threshold", conf.DiffThreshold).
-				Msgf("looks like we don't have enough bitrate to accomodate higher stream, " +
-					"therefore we should wait for some more time")
+		var selectorType types.StreamSelectorType
+		switch strategy.Next(sample) {
+		case estimator.Upgrade:
+			selectorType = types.StreamSelectorTypeHigher
+		case estimator.Downgrade:
+			selectorType = types.StreamSelectorTypeLower
+		default:
 			continue
 		}
 
 		err := peer.SetVideo(types.PeerVideoRequest{
 			Selector: &types.StreamSelector{
-				ID:   streamId,
-				Type: types.StreamSelectorTypeHigher,
+				ID:   sample.StreamID,
+				Type: selectorType,
 			},
 		})
 		if err != nil && err != types.ErrWebRTCStreamNotFound {
-			peer.logger.Warn().Err(err).Msg("failed to upgrade video stream")
+			peer.logger.Warn().Err(err).Str("type", selectorType.String()).Msg("failed to switch video stream")
 		}
-		lastUpgradeTime = time.Now()
 
 		if err == types.ErrWebRTCStreamNotFound {
-			debugLogger.Info().Msg("looks like we are already on the highest stream")
+			debugLogger.Info().Str("type", selectorType.String()).Msg("looks like there is no such stream")
 		} else {
-			debugLogger.Info().Msg("upgraded video stream")
+			debugLogger.Info().Str("type", selectorType.String()).Msg("switched video stream")
 		}
 	}
 }
@@ -427,6 +375,7 @@ func (peer *WebRTCPeerCtx) Video() types.PeerVideo {
 		ID:       ID,
 		Video:    ID, // TODO: Remove, used for backward compatibility
 		Auto:     peer.videoAuto,
+		Codec:    peer.video.Codec().Name,
 	}
 }
 
@@ -543,3 +492,111 @@ func (peer *WebRTCPeerCtx) SendCursorImage(cur *types.CursorImage, img []byte) e
 
 	return peer.dataChannel.Send(buffer.Bytes())
 }
+
+func (peer *WebRTCPeerCtx) SetInactiveCursors(enabled bool) {
+	peer.mu.Lock()
+	defer peer.mu.Unlock()
+
+	peer.inactiveCursors = enabled
+}
+
+func (peer *WebRTCPeerCtx) SendInactiveCursors(cursors map[string][]types.Cursor) error {
+	peer.mu.Lock()
+	defer peer.mu.Unlock()
+
+	if !peer.inactiveCursors {
+		return types.ErrWebRTCCursorsNotEnabled
+	}
+
+	if peer.dataChannel == nil || peer.dataChannel.ReadyState() != webrtc.DataChannelStateOpen {
+		return types.ErrWebRTCDataChannelNotFound
+	}
+
+	body := &bytes.Buffer{}
+	sessions := 0
+
+	for id, positions := range cursors {
+		// only the most recent positions fit into the count
+		if len(positions) > math.MaxUint8 {
+			positions = positions[len(positions)-math.MaxUint8:]
+		}
+
+		size := 2 + len(id) + len(positions)*4
+		if len(id) > math.MaxUint8 || 5+body.Len()+size > math.MaxUint16 {
+			peer.logger.Warn().Str("id", id).Msg("inactive cursors do not fit into message, skipping")
+			continue
+		}
+
+		body.WriteByte(uint8(len(id)))
+		body.WriteString(id)
+		body.WriteByte(uint8(len(positions)))
+
+		for _, position := range positions {
+			data := payload.CursorPosition{
+				X: uint16(position.X),
+				Y: uint16(position.Y),
+			}
+
+			if err := binary.Write(body, binary.BigEndian, data); err != nil {
+				return err
+			}
+		}
+
+		sessions++
+	}
+
+	header := payload.Header{
+		Event:  payload.OP_INACTIVE_CURSORS,
+		Length: uint16(5 + body.Len()),
+	}
+
+	data := payload.InactiveCursors{
+		Sessions: uint16(sessions),
+	}
+
+	buffer := &bytes.Buffer{}
+
+	if err := binary.Write(buffer, binary.BigEndian, header); err != nil {
+		return err
+	}
+
+	if err := binary.Write(buffer, binary.BigEndian, data); err != nil {
+		return err
+	}
+
+	if _, err := buffer.Write(body.Bytes()); err != nil {
+		return err
+	}
+
+	return peer.dataChannel.Send(buffer.Bytes())
+}
+
+func (peer *WebRTCPeerCtx) sendHeartbeat() error {
+	peer.mu.Lock()
+	defer peer.mu.Unlock()
+
+	header := payload.Header{
+		Event:  payload.OP_HEARTBEAT,
+		Length: 11,
+	}
+
+	// generate server timestamp, client echoes it back
+	serverTs := uint64(time.Now().UnixMilli())
+
+	data := payload.Heartbeat{
+		ServerTs1: uint32(serverTs / math.MaxUint32),
+		ServerTs2: uint32(serverTs % math.MaxUint32),
+	}
+
+	buffer := &bytes.Buffer{}
+
+	if err := binary.Write(buffer, binary.BigEndian, header); err != nil {
+		return err
+	}
+
+	if err := binary.Write(buffer, binary.BigEndian, data); err != nil {
+		return err
+	}
+
+	return peer.dataChannel.Send(buffer.Bytes())
+}
@@ -0,0 +1,106 @@
+package webrtc
+
+import (
+	"time"
+
+	"github.com/m1k1o/neko/server/pkg/types/event"
+	"github.com/m1k1o/neko/server/pkg/types/message"
+)
+
+// recover restarts ice with increasing backoff until the peer is connected again,
+// or destroys it when all attempts fail. It does nothing, if recovery is running.
+func (peer *WebRTCPeerCtx) recover() {
+	peer.recoveryMu.Lock()
+	defer peer.recoveryMu.Unlock()
+
+	if peer.recoveryStop != nil {
+		return
+	}
+
+	stop := make(chan struct{})
+	peer.recoveryStop = stop
+	peer.recoveryAttempted = false
+
+	peer.logger.Info().Msg("connection lost, starting recovery")
+
+	go func() {
+		backoff := iceRestartBackoff
+
+		for attempt := 1; attempt <= iceRestartAttempts; attempt++ {
+			select {
+			case <-stop:
+				return
+			case <-time.After(backoff):
+			}
+
+			peer.recoveryMu.Lock()
+			running := peer.recoveryStop == stop
+			if running {
+				peer.recoveryAttempted = true
+			}
+			peer.recoveryMu.Unlock()
+
+			if !running {
+				return
+			}
+
+			peer.metrics.ICERestart(iceRestartAttempt)
+			peer.logger.Info().Int("attempt", attempt).Msg("restarting ice")
+
+			if err := peer.restartICE(); err != nil {
+				peer.logger.Err(err).Int("attempt", attempt).Msg("ice restart failed")
+			}
+
+			backoff = min(backoff*2, iceRestartMaxBackoff)
+		}
+
+		// give the last attempt time to succeed
+		select {
+		case <-stop:
+			return
+		case <-time.After(backoff):
+		}
+
+		peer.metrics.ICERestart(iceRestartFailed)
+		peer.logger.Warn().Msg("unable to recover connection, destroying peer")
+		peer.Destroy()
+	}()
+}
+
+// recovered stops running recovery, because the peer is connected again. Connection,
+// that recovered before ice was restarted, is not counted as a successful restart.
+func (peer *WebRTCPeerCtx) recovered() {
+	if peer.stopRecovery() {
+		peer.metrics.ICERestart(iceRestartSucceeded)
+		peer.logger.Info().Msg("connection recovered")
+	}
+}
+
+// stopRecovery returns whether recovery was running and ice was restarted.
+func (peer *WebRTCPeerCtx) stopRecovery() bool {
+	peer.recoveryMu.Lock()
+	defer peer.recoveryMu.Unlock()
+
+	if peer.recoveryStop == nil {
+		return false
+	}
+
+	close(peer.recoveryStop)
+	peer.recoveryStop = nil
+	return peer.recoveryAttempted
+}
+
+func (peer *WebRTCPeerCtx) restartICE() error {
+	offer, err := peer.CreateOffer(true)
+	if err != nil {
+		return err
+	}
+
+	peer.session.Send(
+		event.SIGNAL_RESTART,
+		message.SignalDescription{
+			SDP: offer.SDP,
+		})
+
+	return nil
+}
@@ -0,0 +1,123 @@
+package webrtc
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/pion/turn/v4"
+
+	"github.com/m1k1o/neko/server/internal/webrtc/pionlog"
+	"github.com/m1k1o/neko/server/pkg/types"
+	"github.com/m1k1o/neko/server/pkg/utils"
+)
+
+// realm of embedded turn server
+const turnRealm = "neko"
+
+// startTURN runs embedded turn server on the same port for UDP and TCP. It authenticates
+// using ephemeral credentials derived from a secret, that is generated on every start.
+func (manager *WebRTCManagerCtx) startTURN(loggerFactory pionlog.Factory) error {
+	config := manager.config.TURN
+
+	relayIP := net.ParseIP(config.RelayAddress)
+	if relayIP == nil {
+		return fmt.Errorf("invalid relay address %q", config.RelayAddress)
+	}
+
+	secret, err := utils.NewUID(32)
+	if err != nil {
+		return err
+	}
+
+	localIPs, err := turnLocalIPs(manager.config.NAT1To1IPs)
+	if err != nil {
+		return err
+	}
+
+	addr := fmt.Sprintf(":%d", config.Port)
+
+	udpListener, err := net.ListenPacket("udp4", addr)
+	if err != nil {
+		return err
+	}
+
+	tcpListener, err := net.Listen("tcp4", addr)
+	if err != nil {
+		udpListener.Close()
+		return err
+	}
+
+	relayAddressGenerator := &turn.RelayAddressGeneratorStatic{
+		RelayAddress: relayIP,
+		Address:      relayIP.String(),
+	}
+
+	// only this server can be reached through the relay, so that it cannot be abused
+	permissionHandler := func(clientAddr net.Addr, peerIP net.IP) bool {
+		_, ok := localIPs[peerIP.String()]
+		return ok
+	}
+
+	manager.turnServer, err = turn.NewServer(turn.ServerConfig{
+		Realm:         turnRealm,
+		AuthHandler:   turn.LongTermTURNRESTAuthHandler(secret, loggerFactory.NewLogger("turn")),
+		LoggerFactory: loggerFactory,
+		PacketConnConfigs: []turn.PacketConnConfig{
+			{
+				PacketConn:            udpListener,
+				RelayAddressGenerator: relayAddressGenerator,
+				PermissionHandler:     permissionHandler,
+			},
+		},
+		ListenerConfigs: []turn.ListenerConfig{
+			{
+				Listener:              tcpListener,
+				RelayAddressGenerator: relayAddressGenerator,
+				PermissionHandler:     permissionHandler,
+			},
+		},
+	})
+	if err != nil {
+		udpListener.Close()
+		tcpListener.Close()
+		return err
+	}
+
+	// without public host, server is running but clients do not know about it
+	if config.Host != "" {
+		manager.turnICEServers = []types.ICEServer{
+			{
+				URLs: []string{
+					fmt.Sprintf("turn:%s?transport=udp", net.JoinHostPort(config.Host, fmt.Sprint(config.Port))),
+					fmt.Sprintf("turn:%s?transport=tcp", net.JoinHostPort(config.Host, fmt.Sprint(config.Port))),
+				},
+				Secret: secret,
+			},
+		}
+	}
+
+	return nil
+}
+
+// addresses of all local interfaces and those behind 1:1 NAT
+func turnLocalIPs(nat1to1IPs []string) (map[string]struct{}, error) {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil, err
+	}
+
+	localIPs := map[string]struct{}{}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok {
+			localIPs[ipNet.IP.String()] = struct{}{}
+		}
+	}
+
+	for _, ip := range nat1to1IPs {
+		if parsed := net.ParseIP(ip); parsed != nil {
+			localIPs[parsed.String()] = struct{}{}
+		}
+	}
+
+	return localIPs, nil
+}
@@ -167,6 +167,18 @@ func (h *MessageHandlerCtx) controlKeyUp(session types.Session, payload *message
 	return h.desktop.KeyUp(payload.Keysym)
 }
 
+func (h *MessageHandlerCtx) controlText(session types.Session, payload *message.ControlText) error {
+	if payload.ControlPos != nil {
+		if err := h.controlMove(session, payload.ControlPos); err != nil {
+			return err
+		}
+	} else if err := h.controlRequest(session); err != nil && !errors.Is(err, ErrIsAlreadyTheHost) {
+		return err
+	}
+
+	return h.desktop.TypeText(payload.Text)
+}
+
 func (h *MessageHandlerCtx) controlTouchBegin(session types.Session, payload *message.ControlTouch) error {
 	if err := h.controlRequest(session); err != nil && !errors.Is(err, ErrIsAlreadyTheHost) {
 		return err
@@ -1,12 +1,15 @@
 package handler
 
 import (
+	"context"
+	"encoding/json"
+	"sync"
+
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 
 	"github.com/m1k1o/neko/server/pkg/types"
 	"github.com/m1k1o/neko/server/pkg/types/event"
-	"github.com/m1k1o/neko/server/pkg/types/message"
 	"github.com/m1k1o/neko/server/pkg/utils"
 )
 
@@ -16,13 +19,22 @@ func New(
 	capture types.CaptureManager,
 	webrtc types.WebRTCManager,
 ) *MessageHandlerCtx {
-	return &MessageHandlerCtx{
+	h := &MessageHandlerCtx{
 		logger:   log.With().Str("module", "websocket").Str("submodule", "handler").Logger(),
 		sessions: sessions,
 		desktop:  desktop,
 		capture:  capture,
 		webrtc:   webrtc,
+
+		screencastStops: map[string]context.CancelFunc{},
 	}
+	h.handlers = h.eventHandlers()
+
+	sessions.OnDisconnected(func(session types.Session) {
+		h.screencastUnsubscribe(session)
+	})
+
+	return h
 }
 
 type MessageHandlerCtx struct {
@@ -31,172 +43,97 @@ type MessageHandlerCtx struct {
 	webrtc   types.WebRTCManager
 	desktop  types.DesktopManager
 	capture  types.CaptureManager
+
+	// screencast streams of subscribed sessions
+	screencastStops   map[string]context.CancelFunc
+	screencastStopsMu sync.Mutex
+
+	handlers map[string]eventHandler
+}
+
+// handlers of all client events, payload types are used to describe the api
+func (h *MessageHandlerCtx) eventHandlers() map[string]eventHandler {
+	return map[string]eventHandler{
+		// Client Events
+		event.CLIENT_HEARTBEAT: withoutPayload(func(session types.Session) error {
+			session.WebSocketHeartbeat(0)
+			return nil
+		}),
+
+		// System Events
+		event.SYSTEM_LOGS: withPayload(h.systemLogs),
+
+		// Signal Events
+		event.SIGNAL_REQUEST:   withPayload(h.signalRequest),
+		event.SIGNAL_RESTART:   withoutPayload(h.signalRestart),
+		event.SIGNAL_OFFER:     withPayload(h.signalOffer),
+		event.SIGNAL_ANSWER:    withPayload(h.signalAnswer),
+		event.SIGNAL_CANDIDATE: withPayload(h.signalCandidate),
+		event.SIGNAL_VIDEO:     withPayload(h.signalVideo),
+		event.SIGNAL_AUDIO:     withPayload(h.signalAudio),
+
+		// Control Events
+		event.CONTROL_RELEASE:     withoutPayload(h.controlRelease),
+		event.CONTROL_REQUEST:     withoutPayload(h.controlRequest),
+		event.CONTROL_MOVE:        withPayload(h.controlMove),
+		event.CONTROL_SCROLL:      withPayload(h.controlScroll),
+		event.CONTROL_BUTTONPRESS: withPayload(h.controlButtonPress),
+		event.CONTROL_BUTTONDOWN:  withPayload(h.controlButtonDown),
+		event.CONTROL_BUTTONUP:    withPayload(h.controlButtonUp),
+		event.CONTROL_KEYPRESS:    withPayload(h.controlKeyPress),
+		event.CONTROL_KEYDOWN:     withPayload(h.controlKeyDown),
+		event.CONTROL_KEYUP:       withPayload(h.controlKeyUp),
+		event.CONTROL_TEXT:        withPayload(h.controlText),
+		// touch
+		event.CONTROL_TOUCHBEGIN:  withPayload(h.controlTouchBegin),
+		event.CONTROL_TOUCHUPDATE: withPayload(h.controlTouchUpdate),
+		event.CONTROL_TOUCHEND:    withPayload(h.controlTouchEnd),
+		// actions
+		event.CONTROL_CUT:        withoutPayload(h.controlCut),
+		event.CONTROL_COPY:       withoutPayload(h.controlCopy),
+		event.CONTROL_PASTE:      withPayload(h.controlPaste),
+		event.CONTROL_SELECT_ALL: withoutPayload(h.controlSelectAll),
+
+		// Screen Events
+		event.SCREEN_SET: withPayload(h.screenSet),
+
+		// Screencast Events
+		event.SCREENCAST_SUBSCRIBE: withPayload(h.screencastSubscribe),
+		event.SCREENCAST_UNSUBSCRIBE: withoutPayload(func(session types.Session) error {
+			h.screencastUnsubscribe(session)
+			return nil
+		}),
+
+		// Clipboard Events
+		event.CLIPBOARD_SET: withPayload(h.clipboardSet),
+
+		// Keyboard Events
+		event.KEYBOARD_MAP:       withPayload(h.keyboardMap),
+		event.KEYBOARD_MODIFIERS: withPayload(h.keyboardModifiers),
+
+		// Send Events
+		event.SEND_UNICAST:   withPayload(h.sendUnicast),
+		event.SEND_BROADCAST: withPayload(h.sendBroadcast),
+	}
+}
+
+// Events returns all client events handled by the message handler
+// and go values describing their payloads, nil means no payload.
+func Events() map[string]any {
+	events := map[string]any{}
+	for name, handler := range (&MessageHandlerCtx{}).eventHandlers() {
+		events[name] = handler.payload
+	}
+	return events
 }
 
 func (h *MessageHandlerCtx) Message(session types.Session, data types.WebSocketMessage) bool {
-	var err error
-	switch data.Event {
-	// Client Events
-	case event.CLIENT_HEARTBEAT:
-		// do nothing
-
-	// System Events
-	case event.SYSTEM_LOGS:
-		payload := &message.SystemLogs{}
-		err = utils.Unmarshal(payload, data.Payload, func() error {
-			return h.systemLogs(session, payload)
-		})
-
-	// Signal Events
-	case event.SIGNAL_REQUEST:
-		payload := &message.SignalRequest{}
-		err = utils.Unmarshal(payload, data.Payload, func() error {
-			return h.signalRequest(session, payload)
-		})
-	case event.SIGNAL_RESTART:
-		err = h.signalRestart(session)
-	case event.SIGNAL_OFFER:
-		payload := &message.SignalDescription{}
-		err = utils.Unmarshal(payload, data.Payload, func() error {
-			return h.signalOffer(session, payload)
-		})
-	case event.SIGNAL_ANSWER:
-		payload := &message.SignalDescription{}
-		err = utils.Unmarshal(payload, data.Payload, func() error {
-			return h.signalAnswer(session, payload)
-		})
-	case event.SIGNAL_CANDIDATE:
-		payload := &message.SignalCandidate{}
-		err = utils.Unmarshal(payload, data.Payload, func() error {
-			return h.signalCandidate(session, payload)
-		})
-	case event.SIGNAL_VIDEO:
-		payload := &message.SignalVideo{}
-		err = utils.Unmarshal(payload, data.Payload, func() error {
-			return h.signalVideo(session, payload)
-		})
-	case event.SIGNAL_AUDIO:
-		payload := &message.SignalAudio{}
-		err = utils.Unmarshal(payload, data.Payload, func() error {
-			return h.signalAudio(session, payload)
-		})
-
-	// Control Events
-	case event.CONTROL_RELEASE:
-		err = h.controlRelease(session)
-	case event.CONTROL_REQUEST:
-		err = h.controlRequest(session)
-	case event.CONTROL_MOVE:
-		payload := &message.ControlPos{}
-		err = utils.Unmarshal(payload, data.Payload, func() error {
-			return h.controlMove(session, payload)
-		})
-	case event.CONTROL_SCROLL:
-		payload := &message.ControlScroll{}
-		err = utils.Unmarshal(payload, data.Payload, func() error {
-			return h.controlScroll(session, payload)
-		})
-	case event.CONTROL_BUTTONPRESS:
-		payload := &message.ControlButton{}
-		err = utils.Unmarshal(payload, data.Payload, func() error {
-			return h.controlButtonPress(session, payload)
-		})
-	case event.CONTROL_BUTTONDOWN:
-		payload := &message.ControlButton{}
-		err = utils.Unmarshal(payload, data.Payload, func() error {
-			return h.controlButtonDown(session, payload)
-		})
-	case event.CONTROL_BUTTONUP:
-		payload := &message.ControlButton{}
-		err = utils.Unmarshal(payload, data.Payload, func() error {
-			return h.controlButtonUp(session, payload)
-		})
-	case event.CONTROL_KEYPRESS:
-		payload := &message.ControlKey{}
-		err = utils.Unmarshal(payload, data.Payload, func() error {
-			return h.controlKeyPress(session, payload)
-		})
-	case event.CONTROL_KEYDOWN:
-		payload := &message.ControlKey{}
-		err = utils.Unmarshal(payload, data.Payload, func() error {
-			return h.controlKeyDown(session, payload)
-		})
-	case event.CONTROL_KEYUP:
-		payload := &message.ControlKey{}
-		err = utils.Unmarshal(payload, data.Payload, func() error {
-			return h.controlKeyUp(session, payload)
-		})
-	// touch
-	case event.CONTROL_TOUCHBEGIN:
-		payload := &message.ControlTouch{}
-		err = utils.Unmarshal(payload, data.Payload, func() error {
-			return h.controlTouchBegin(session, payload)
-		})
-	case event.CONTROL_TOUCHUPDATE:
-		payload := &message.ControlTouch{}
-		err = utils.Unmarshal(payload, data.Payload, func() error {
-			return h.controlTouchUpdate(session, payload)
-		})
-	case event.CONTROL_TOUCHEND:
-		payload := &message.ControlTouch{}
-		err = utils.Unmarshal(payload, data.Payload, func() error {
-			return h.controlTouchEnd(session, payload)
-		})
-	// actions
-	case event.CONTROL_CUT:
-		err = h.controlCut(session)
-	case event.CONTROL_COPY:
-		err = h.controlCopy(session)
-	case event.CONTROL_PASTE:
-		payload := &message.ClipboardData{}
-		err = utils.Unmarshal(payload, data.Payload, func() error {
-			return h.controlPaste(session, payload)
-		})
-	case event.CONTROL_SELECT_ALL:
-		err = h.controlSelectAll(session)
-
-	// Screen Events
-	case event.SCREEN_SET:
-		payload := &message.ScreenSize{}
-		err = utils.Unmarshal(payload, data.Payload, func() error {
-			return h.screenSet(session, payload)
-		})
-
-	// Clipboard Events
-	case event.CLIPBOARD_SET:
-		payload := &message.ClipboardData{}
-		err = utils.Unmarshal(payload, data.Payload, func() error {
-			return h.clipboardSet(session, payload)
-		})
-
-	// Keyboard Events
-	case event.KEYBOARD_MAP:
-		payload := &message.KeyboardMap{}
-		err = utils.Unmarshal(payload, data.Payload, func() error {
-			return h.keyboardMap(session, payload)
-		})
-	case event.KEYBOARD_MODIFIERS:
-		payload := &message.KeyboardModifiers{}
-		err = utils.Unmarshal(payload, data.Payload, func() error {
-			return h.keyboardModifiers(session, payload)
-		})
-
-	// Send Events
-	case event.SEND_UNICAST:
-		payload := &message.SendUnicast{}
-		err = utils.Unmarshal(payload, data.Payload, func() error {
-			return h.sendUnicast(session, payload)
-		})
-	case event.SEND_BROADCAST:
-		payload := &message.SendBroadcast{}
-		err = utils.Unmarshal(payload, data.Payload, func() error {
-			return h.sendBroadcast(session, payload)
-		})
-	default:
+	handler, ok := h.handlers[data.Event]
+	if !ok {
 		return false
 	}
 
-	if err != nil {
+	if err := handler.handle(session, data.Payload); err != nil {
 		h.logger.Warn().Err(err).
 			Str("event", data.Event).
 			Str("session_id", session.ID()).
@@ -205,3 +142,31 @@ func (h *MessageHandlerCtx) Message(session types.Session, data types.WebSocketM
 
 	return true
 }
+
+type eventHandler struct {
+	// go value describing the payload, nil means no payload
+	payload any
+	handle  func(session types.Session, payload json.RawMessage) error
+}
+
+// withPayload unmarshals payload of the event before passing it to the handler
+func withPayload[T any](fn func(types.Session, *T) error) eventHandler {
+	var zero T
+	return eventHandler{
+		payload: zero,
+		handle: func(session types.Session, raw json.RawMessage) error {
+			payload := new(T)
+			return utils.Unmarshal(payload, raw, func() error {
+				return fn(session, payload)
+			})
+		},
+	}
+}
+
+func withoutPayload(fn func(types.Session) error) eventHandler {
+	return eventHandler{
+		handle: func(session types.Session, _ json.RawMessage) error {
+			return fn(session)
+		},
+	}
+}
@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"github.com/m1k1o/neko/server/pkg/types"
+	"github.com/m1k1o/neko/server/pkg/types/event"
+	"github.com/m1k1o/neko/server/pkg/types/message"
+)
+
+func (h *MessageHandlerCtx) screencastSubscribe(session types.Session, payload *message.ScreencastSubscribe) error {
+	if !session.Profile().CanWatch {
+		return errors.New("not allowed to watch")
+	}
+
+	screencast := h.capture.Screencast()
+	if !screencast.Enabled() {
+		return errors.New("screencast is not enabled")
+	}
+
+	// new subscription replaces the previous one, e.g. to change the rate
+	h.screencastUnsubscribe(session)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	images, err := screencast.Stream(ctx, payload.Rate)
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	h.screencastStopsMu.Lock()
+	h.screencastStops[session.ID()] = cancel
+	h.screencastStopsMu.Unlock()
+
+	go func() {
+		for image := range images {
+			// screen is hidden from session in private mode
+			if session.PrivateModeEnabled() {
+				continue
+			}
+
+			session.Send(event.SCREENCAST_FRAME, message.ScreencastFrame{
+				Image: image,
+			})
+		}
+	}()
+
+	return nil
+}
+
+func (h *MessageHandlerCtx) screencastUnsubscribe(session types.Session) {
+	h.screencastStopsMu.Lock()
+	cancel, ok := h.screencastStops[session.ID()]
+	delete(h.screencastStops, session.ID())
+	h.screencastStopsMu.Unlock()
+
+	if ok {
+		cancel()
+	}
+}
@@ -15,7 +15,16 @@ func (h *MessageHandlerCtx) signalRequest(session types.Session, payload *messag
 		return errors.New("not allowed to watch")
 	}
 
-	offer, peer, err := h.webrtc.CreatePeer(session)
+	if h.webrtcViewersLimitReached(session) {
+		session.Send(
+			event.SIGNAL_HLS,
+			message.SignalHLS{
+				Playlist: "api/room/hls/playlist.m3u8",
+			})
+		return nil
+	}
+
+	offer, peer, err := h.webrtc.CreatePeer(session, nil, payload.VideoCodecs)
 	if err != nil {
 		return err
 	}
@@ -25,6 +34,8 @@ func (h *MessageHandlerCtx) signalRequest(session types.Session, payload *messag
 		peer.SetPaused(true)
 	}
 
+	peer.SetInactiveCursors(payload.InactiveCursors)
+
 	video := payload.Video
 
 	// use default first video, if not provided
@@ -65,7 +76,7 @@ func (h *MessageHandlerCtx) signalRequest(session types.Session, payload *messag
 		event.SIGNAL_PROVIDE,
 		message.SignalProvide{
 			SDP:        offer.SDP,
-			ICEServers: h.webrtc.ICEServers(),
+			ICEServers: h.webrtc.ICEServers(session),
 
 			Video: peer.Video(),
 			Audio: peer.Audio(),
@@ -74,6 +85,36 @@ func (h *MessageHandlerCtx) signalRequest(session types.Session, payload *messag
 	return nil
 }
 
+// webrtcViewersLimitReached reports whether watch-only session should be redirected to hls,
+// sessions that can host or are admins always use webrtc.
+func (h *MessageHandlerCtx) webrtcViewersLimitReached(session types.Session) bool {
+	hls := h.capture.HLS()
+
+	limit := hls.MaxWebRTCViewers()
+	if !hls.Enabled() || limit <= 0 {
+		return false
+	}
+
+	isViewer := func(session types.Session) bool {
+		profile := session.Profile()
+		return !profile.IsAdmin && !profile.CanHost
+	}
+
+	if !isViewer(session) {
+		return false
+	}
+
+	viewers := 0
+	h.sessions.Range(func(other types.Session) bool {
+		if other != session && other.State().IsWatching && isViewer(other) {
+			viewers++
+		}
+		return true
+	})
+
+	return viewers >= limit
+}
+
 func (h *MessageHandlerCtx) signalRestart(session types.Session) error {
 	peer := session.GetWebRTCPeer()
 	if peer == nil {
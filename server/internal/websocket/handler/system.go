@@ -63,14 +63,21 @@ func (h *MessageHandlerCtx) systemAdmin(session types.Session) error {
 	}
 
 	broadcast := h.capture.Broadcast()
+	recording := h.capture.Recording()
 	session.Send(
 		event.SYSTEM_ADMIN,
 		message.SystemAdmin{
 			ScreenSizesList: list, // TODO: remove
 			BroadcastStatus: message.BroadcastStatus{
+				ID:       types.BroadcastDefaultID,
 				IsActive: broadcast.Started(),
 				URL:      broadcast.Url(),
 			},
+			BroadcastDestinations: broadcast.Destinations(),
+			RecordingStatus: message.RecordingStatus{
+				IsActive: recording.Started(),
+				File:     recording.File(),
+			},
 		})
 
 	return nil
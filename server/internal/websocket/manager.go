@@ -5,6 +5,7 @@ import (
 	"errors"
 	"net/http"
 	"slices"
+	"strconv"
 	"sync"
 	"time"
 
@@ -26,6 +27,12 @@ const (
 	// period for sending inactive cursor messages
 	inactiveCursorsPeriod = 750 * time.Millisecond
 
+	// period for sending webrtc stats to admins
+	webrtcStatsPeriod = 5 * time.Second
+
+	// period for sending microphone levels to admins
+	microphoneInputsPeriod = 250 * time.Millisecond
+
 	// maximum payload length for logging
 	maxPayloadLogLength = 10_000
 )
@@ -39,6 +46,11 @@ var nologEvents = []string{
 	event.CLIENT_HEARTBEAT,
 	// don't log every cursor update
 	event.SESSION_CURSORS,
+	// don't log periodic stats
+	event.SIGNAL_STATS,
+	event.MICROPHONE_INPUTS,
+	// don't log images
+	event.SCREENCAST_FRAME,
 }
 
 func New(
@@ -54,6 +66,7 @@ func New(
 		shutdown: make(chan struct{}),
 		sessions: sessions,
 		desktop:  desktop,
+		capture:  capture,
 		handler:  handler.New(sessions, desktop, capture, webrtc),
 		handlers: []types.WebSocketHandler{},
 	}
@@ -65,6 +78,7 @@ type WebSocketManagerCtx struct {
 	shutdown chan struct{}
 	sessions types.SessionManager
 	desktop  types.DesktopManager
+	capture  types.CaptureManager
 	handler  *handler.MessageHandlerCtx
 	handlers []types.WebSocketHandler
 
@@ -180,14 +194,50 @@ func (manager *WebSocketManagerCtx) Start() {
 			})
 	})
 
+	manager.capture.Broadcast().OnStatusChange(func(destination types.BroadcastDestination) {
+		manager.sessions.AdminBroadcast(
+			event.BROADCAST_STATUS,
+			message.BroadcastStatus{
+				ID:       destination.ID,
+				IsActive: destination.IsActive,
+				URL:      destination.URL,
+				State:    destination.State,
+				Error:    destination.Error,
+			})
+	})
+
+	manager.capture.Recording().OnStatusChange(func(isActive bool, file string) {
+		manager.sessions.AdminBroadcast(
+			event.RECORDING_STATUS,
+			message.RecordingStatus{
+				IsActive: isActive,
+				File:     file,
+			})
+	})
+
 	if manager.desktop.IsFileChooserDialogEnabled() {
 		manager.fileChooserDialogEvents()
 	}
 
+	manager.pointerGrabEvents()
+
 	if manager.sessions.Settings().InactiveCursors {
 		manager.startInactiveCursors()
 	}
 
+	manager.wg.Go(manager.webrtcStats)
+
+	if manager.capture.Microphone().Mixing() {
+		manager.wg.Go(manager.microphoneInputs)
+
+		// remote track is kept by the peer, but it must not be mixed anymore
+		manager.sessions.OnProfileChanged(func(session types.Session, new, old types.MemberProfile) {
+			if !new.CanShareMedia {
+				manager.capture.Microphone().RemoveInput(session.ID())
+			}
+		})
+	}
+
 	manager.logger.Info().Msg("websocket starting")
 }
 
@@ -223,7 +273,16 @@ func (manager *WebSocketManagerCtx) Upgrade(checkOrigin types.CheckOrigin) types
 }
 
 func (manager *WebSocketManagerCtx) connect(connection *websocket.Conn, r *http.Request) {
-	session, err := manager.sessions.Authenticate(r)
+	var session types.Session
+	var err error
+
+	// single-use ticket takes precedence over the session token
+	if ticket := r.URL.Query().Get("ticket"); ticket != "" {
+		session, err = manager.sessions.AuthenticateTicket(ticket)
+	} else {
+		session, err = manager.sessions.Authenticate(r)
+	}
+
 	if err != nil {
 		manager.logger.Warn().Err(err).Msg("authentication failed")
 		newPeer(manager.logger, connection).Destroy(err.Error())
@@ -312,6 +371,16 @@ func (manager *WebSocketManagerCtx) handle(connection *websocket.Conn, peer type
 	ticker := time.NewTicker(pingPeriod)
 	defer ticker.Stop()
 
+	connection.SetPongHandler(func(appData string) error {
+		var rtt time.Duration
+		if sent, err := strconv.ParseInt(appData, 10, 64); err == nil {
+			rtt = time.Since(time.Unix(0, sent))
+		}
+
+		session.WebSocketHeartbeat(rtt)
+		return nil
+	})
+
 	manager.wg.Go(func() {
 		for {
 			_, raw, err := connection.ReadMessage()
@@ -347,6 +416,12 @@ func (manager *WebSocketManagerCtx) handle(connection *websocket.Conn, peer type
 					Msg("received message from client")
 			}
 
+			// drop events exceeding rate limit
+			if !session.AllowEvent(data.Event) {
+				logger.Debug().Str("event", data.Event).Msg("rate limit exceeded, dropping message")
+				break
+			}
+
 			handled := manager.handler.Message(session, data)
 			for _, handler := range manager.handlers {
 				if handled {
@@ -396,7 +471,7 @@ func (manager *WebSocketManagerCtx) startInactiveCursors() {
 
 				// remove last cursor entries and send empty message
 				_ = manager.sessions.PopCursors()
-				manager.sessions.InactiveCursorsBroadcast(event.SESSION_CURSORS, []message.SessionCursors{})
+				manager.broadcastInactiveCursors(map[types.Session][]types.Cursor{})
 				return
 			case <-ticker.C:
 				cursorsMap := manager.sessions.PopCursors()
@@ -407,25 +482,79 @@ func (manager *WebSocketManagerCtx) startInactiveCursors() {
 				}
 				lastEmpty = currentEmpty
 
-				sessionCursors := []message.SessionCursors{}
-				for session, cursors := range cursorsMap {
-					sessionCursors = append(
-						sessionCursors,
-						message.SessionCursors{
-							ID:      session.ID(),
-							Cursors: cursors,
-						},
-					)
-				}
-
-				manager.sessions.InactiveCursorsBroadcast(event.SESSION_CURSORS, sessionCursors)
+				manager.broadcastInactiveCursors(cursorsMap)
 			}
 		}
 	})
 }
 
+// broadcastInactiveCursors sends cursors over data channel to peers that support it,
+// everyone else receives them over websocket.
+func (manager *WebSocketManagerCtx) broadcastInactiveCursors(cursorsMap map[types.Session][]types.Cursor) {
+	cursors := map[string][]types.Cursor{}
+	sessionCursors := []message.SessionCursors{}
+	for session, c := range cursorsMap {
+		cursors[session.ID()] = c
+		sessionCursors = append(
+			sessionCursors,
+			message.SessionCursors{
+				ID:      session.ID(),
+				Cursors: c,
+			},
+		)
+	}
+
+	exclude := []string{}
+	for _, session := range manager.sessions.List() {
+		if !session.State().IsConnected || !session.Profile().CanSeeInactiveCursors {
+			continue
+		}
+
+		peer := session.GetWebRTCPeer()
+		if peer == nil {
+			continue
+		}
+
+		err := peer.SendInactiveCursors(cursors)
+		if err == nil {
+			exclude = append(exclude, session.ID())
+			continue
+		}
+
+		if !errors.Is(err, types.ErrWebRTCCursorsNotEnabled) && !errors.Is(err, types.ErrWebRTCDataChannelNotFound) {
+			manager.logger.Warn().Err(err).Str("session_id", session.ID()).Msg("could not send inactive cursors over data channel")
+		}
+	}
+
+	manager.sessions.InactiveCursorsBroadcast(event.SESSION_CURSORS, sessionCursors, exclude...)
+}
+
 func (manager *WebSocketManagerCtx) stopInactiveCursors() {
 	if manager.shutdownInactiveCursors != nil {
 		close(manager.shutdownInactiveCursors)
 	}
 }
+
+func (manager *WebSocketManagerCtx) webrtcStats() {
+	ticker := time.NewTicker(webrtcStatsPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-manager.shutdown:
+			return
+		case <-ticker.C:
+			for _, session := range manager.sessions.List() {
+				peer := session.GetWebRTCPeer()
+				if peer == nil {
+					continue
+				}
+
+				manager.sessions.AdminBroadcast(event.SIGNAL_STATS, message.SignalStats{
+					ID:          session.ID(),
+					WebRTCStats: peer.Stats(),
+				})
+			}
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package websocket
+
+import (
+	"slices"
+	"time"
+
+	"github.com/m1k1o/neko/server/pkg/types"
+	"github.com/m1k1o/neko/server/pkg/types/event"
+	"github.com/m1k1o/neko/server/pkg/types/message"
+)
+
+// microphoneInputs periodically sends mixed microphones with their speaking level to admins,
+// levels are rounded so that small changes of background noise are not sent.
+func (manager *WebSocketManagerCtx) microphoneInputs() {
+	ticker := time.NewTicker(microphoneInputsPeriod)
+	defer ticker.Stop()
+
+	var last []types.StreamSrcInput
+	for {
+		select {
+		case <-manager.shutdown:
+			return
+		case <-ticker.C:
+			inputs := manager.capture.Microphone().Inputs()
+			for i := range inputs {
+				inputs[i].Level = float64(int(inputs[i].Level*20)) / 20
+			}
+
+			if slices.Equal(inputs, last) {
+				continue
+			}
+
+			last = inputs
+			manager.sessions.AdminBroadcast(event.MICROPHONE_INPUTS, message.MicrophoneInputs{
+				Inputs: inputs,
+			})
+		}
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"encoding/json"
 	"errors"
 	"slices"
+	"strconv"
 	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/rs/zerolog"
@@ -75,7 +77,9 @@ func (peer *WebSocketPeerCtx) Ping() error {
 		return err
 	}
 
-	return peer.connection.WriteMessage(websocket.PingMessage, nil)
+	// protocol level heartbeat, pong echoes send time so that latency can be measured
+	data := strconv.AppendInt(nil, time.Now().UnixNano(), 10)
+	return peer.connection.WriteMessage(websocket.PingMessage, data)
 }
 
 func (peer *WebSocketPeerCtx) Destroy(reason string) {
@@ -0,0 +1,32 @@
+package websocket
+
+import (
+	"github.com/m1k1o/neko/server/pkg/types"
+	"github.com/m1k1o/neko/server/pkg/types/event"
+	"github.com/m1k1o/neko/server/pkg/types/message"
+)
+
+func (manager *WebSocketManagerCtx) pointerGrabEvents() {
+	// when application grabs or releases pointer, everyone should be notified,
+	// so that the host can lock its pointer and send relative movement.
+	manager.desktop.OnPointerGrabChanged(func(grabbed bool) {
+		go manager.sessions.Broadcast(
+			event.CONTROL_POINTERGRAB,
+			message.ControlPointerGrab{
+				Grabbed: grabbed,
+			})
+	})
+
+	// when new user joins while pointer is grabbed, it should be notified about it.
+	manager.sessions.OnConnected(func(session types.Session) {
+		if !manager.desktop.IsPointerGrabbed() {
+			return
+		}
+
+		session.Send(
+			event.CONTROL_POINTERGRAB,
+			message.ControlPointerGrab{
+				Grabbed: true,
+			})
+	})
+}
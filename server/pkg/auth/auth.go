@@ -80,6 +80,15 @@ func CanAccessClipboardOnly(w http.ResponseWriter, r *http.Request) (context.Con
 	return nil, nil
 }
 
+func CanShareMediaOnly(w http.ResponseWriter, r *http.Request) (context.Context, error) {
+	session, ok := GetSession(r)
+	if !ok || !session.Profile().CanShareMedia {
+		return nil, utils.HttpForbidden("session cannot share media")
+	}
+
+	return nil, nil
+}
+
 func PluginsGenericOnly[V comparable](key string, exp V) func(w http.ResponseWriter, r *http.Request) (context.Context, error) {
 	return func(w http.ResponseWriter, r *http.Request) (context.Context, error) {
 		session, ok := GetSession(r)
@@ -0,0 +1,274 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/m1k1o/neko/server/pkg/types"
+)
+
+type MemberData struct {
+	ID      string              `json:"id"`
+	Profile types.MemberProfile `json:"profile"`
+}
+
+type MemberCreate struct {
+	Username string              `json:"username"`
+	Password string              `json:"password"`
+	Profile  types.MemberProfile `json:"profile"`
+}
+
+type BroadcastStatus struct {
+	URL      string `json:"url,omitempty"`
+	IsActive bool   `json:"is_active"`
+	// all started destinations including the default one
+	Destinations []types.BroadcastDestination `json:"destinations,omitempty"`
+}
+
+type ClipboardData struct {
+	Text string `json:"text,omitempty"`
+	HTML string `json:"html,omitempty"`
+}
+
+type ControlStatus struct {
+	HasHost bool   `json:"has_host"`
+	HostID  string `json:"host_id,omitempty"`
+}
+
+//
+// sessions
+//
+
+func (c *Client) Sessions(ctx context.Context) ([]SessionData, error) {
+	var data []SessionData
+	if err := c.request(ctx, http.MethodGet, "/api/sessions", nil, &data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (c *Client) Session(ctx context.Context, sessionId string) (*SessionData, error) {
+	data := &SessionData{}
+	if err := c.request(ctx, http.MethodGet, "/api/sessions/"+url.PathEscape(sessionId), nil, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (c *Client) SessionRemove(ctx context.Context, sessionId string) error {
+	return c.request(ctx, http.MethodDelete, "/api/sessions/"+url.PathEscape(sessionId), nil, nil)
+}
+
+func (c *Client) SessionDisconnect(ctx context.Context, sessionId string) error {
+	return c.request(ctx, http.MethodPost, "/api/sessions/"+url.PathEscape(sessionId)+"/disconnect", nil, nil)
+}
+
+func (c *Client) SessionWebRTCStats(ctx context.Context, sessionId string) (*types.WebRTCStats, error) {
+	data := &types.WebRTCStats{}
+	if err := c.request(ctx, http.MethodGet, "/api/sessions/"+url.PathEscape(sessionId)+"/webrtc", nil, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+//
+// members
+//
+
+// Members lists members, zero limit means no limit.
+func (c *Client) Members(ctx context.Context, limit, offset int) ([]MemberData, error) {
+	query := url.Values{}
+	query.Set("limit", strconv.Itoa(limit))
+	query.Set("offset", strconv.Itoa(offset))
+
+	var data []MemberData
+	if err := c.request(ctx, http.MethodGet, "/api/members?"+query.Encode(), nil, &data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (c *Client) MemberCreate(ctx context.Context, member MemberCreate) (*MemberData, error) {
+	data := &MemberData{}
+	if err := c.request(ctx, http.MethodPost, "/api/members", member, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (c *Client) Member(ctx context.Context, memberId string) (*types.MemberProfile, error) {
+	data := &types.MemberProfile{}
+	if err := c.request(ctx, http.MethodGet, "/api/members/"+url.PathEscape(memberId), nil, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (c *Client) MemberUpdateProfile(ctx context.Context, memberId string, profile types.MemberProfile) error {
+	return c.request(ctx, http.MethodPost, "/api/members/"+url.PathEscape(memberId), profile, nil)
+}
+
+func (c *Client) MemberUpdatePassword(ctx context.Context, memberId string, password string) error {
+	return c.request(ctx, http.MethodPost, "/api/members/"+url.PathEscape(memberId)+"/password", map[string]string{
+		"password": password,
+	}, nil)
+}
+
+func (c *Client) MemberRemove(ctx context.Context, memberId string) error {
+	return c.request(ctx, http.MethodDelete, "/api/members/"+url.PathEscape(memberId), nil, nil)
+}
+
+//
+// room
+//
+
+func (c *Client) RoomSettings(ctx context.Context) (*types.Settings, error) {
+	data := &types.Settings{}
+	if err := c.request(ctx, http.MethodGet, "/api/room/settings", nil, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (c *Client) RoomSettingsSet(ctx context.Context, settings types.Settings) error {
+	return c.request(ctx, http.MethodPost, "/api/room/settings", settings, nil)
+}
+
+func (c *Client) BroadcastStatus(ctx context.Context) (*BroadcastStatus, error) {
+	data := &BroadcastStatus{}
+	if err := c.request(ctx, http.MethodGet, "/api/room/broadcast", nil, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (c *Client) BroadcastStart(ctx context.Context, target string) error {
+	return c.request(ctx, http.MethodPost, "/api/room/broadcast/start", BroadcastStatus{URL: target}, nil)
+}
+
+func (c *Client) BroadcastStop(ctx context.Context) error {
+	return c.request(ctx, http.MethodPost, "/api/room/broadcast/stop", nil, nil)
+}
+
+func (c *Client) BroadcastDestinations(ctx context.Context) ([]types.BroadcastDestination, error) {
+	var data []types.BroadcastDestination
+	if err := c.request(ctx, http.MethodGet, "/api/room/broadcast/destinations", nil, &data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (c *Client) BroadcastDestinationStart(ctx context.Context, destinationId, target string) error {
+	return c.request(ctx, http.MethodPost, "/api/room/broadcast/destinations", types.BroadcastDestination{
+		ID:  destinationId,
+		URL: target,
+	}, nil)
+}
+
+func (c *Client) BroadcastDestinationStop(ctx context.Context, destinationId string) error {
+	return c.request(ctx, http.MethodDelete, "/api/room/broadcast/destinations/"+url.PathEscape(destinationId), nil, nil)
+}
+
+func (c *Client) Clipboard(ctx context.Context) (*ClipboardData, error) {
+	data := &ClipboardData{}
+	if err := c.request(ctx, http.MethodGet, "/api/room/clipboard", nil, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (c *Client) ClipboardSet(ctx context.Context, clipboard ClipboardData) error {
+	return c.request(ctx, http.MethodPost, "/api/room/clipboard", clipboard, nil)
+}
+
+func (c *Client) KeyboardMap(ctx context.Context) (*types.KeyboardMap, error) {
+	data := &types.KeyboardMap{}
+	if err := c.request(ctx, http.MethodGet, "/api/room/keyboard/map", nil, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (c *Client) KeyboardMapSet(ctx context.Context, keyboardMap types.KeyboardMap) error {
+	return c.request(ctx, http.MethodPost, "/api/room/keyboard/map", keyboardMap, nil)
+}
+
+func (c *Client) KeyboardModifiers(ctx context.Context) (*types.KeyboardModifiers, error) {
+	data := &types.KeyboardModifiers{}
+	if err := c.request(ctx, http.MethodGet, "/api/room/keyboard/modifiers", nil, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (c *Client) KeyboardModifiersSet(ctx context.Context, modifiers types.KeyboardModifiers) error {
+	return c.request(ctx, http.MethodPost, "/api/room/keyboard/modifiers", modifiers, nil)
+}
+
+func (c *Client) ControlStatus(ctx context.Context) (*ControlStatus, error) {
+	data := &ControlStatus{}
+	if err := c.request(ctx, http.MethodGet, "/api/room/control", nil, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (c *Client) ControlRequest(ctx context.Context) error {
+	return c.request(ctx, http.MethodPost, "/api/room/control/request", nil, nil)
+}
+
+func (c *Client) ControlRelease(ctx context.Context) error {
+	return c.request(ctx, http.MethodPost, "/api/room/control/release", nil, nil)
+}
+
+func (c *Client) ControlTake(ctx context.Context) error {
+	return c.request(ctx, http.MethodPost, "/api/room/control/take", nil, nil)
+}
+
+func (c *Client) ControlGive(ctx context.Context, sessionId string) error {
+	return c.request(ctx, http.MethodPost, "/api/room/control/give/"+url.PathEscape(sessionId), nil, nil)
+}
+
+func (c *Client) ControlReset(ctx context.Context) error {
+	return c.request(ctx, http.MethodPost, "/api/room/control/reset", nil, nil)
+}
+
+func (c *Client) Screen(ctx context.Context) (*types.ScreenSize, error) {
+	data := &types.ScreenSize{}
+	if err := c.request(ctx, http.MethodGet, "/api/room/screen", nil, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (c *Client) ScreenSet(ctx context.Context, size types.ScreenSize) (*types.ScreenSize, error) {
+	data := &types.ScreenSize{}
+	if err := c.request(ctx, http.MethodPost, "/api/room/screen", size, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (c *Client) ScreenConfigurations(ctx context.Context) ([]types.ScreenSize, error) {
+	var data []types.ScreenSize
+	if err := c.request(ctx, http.MethodGet, "/api/room/screen/configurations", nil, &data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Screenshot returns current screen as jpeg image, zero quality means server default.
+func (c *Client) Screenshot(ctx context.Context, quality int) ([]byte, error) {
+	path := "/api/room/screen/shot.jpg"
+	if quality > 0 {
+		path += "?quality=" + strconv.Itoa(quality)
+	}
+	return c.requestBytes(ctx, http.MethodGet, path, nil)
+}
+
+// Screencast returns latest screencast frame as jpeg image.
+func (c *Client) Screencast(ctx context.Context) ([]byte, error) {
+	return c.requestBytes(ctx, http.MethodGet, "/api/room/screen/cast.jpg", nil)
+}
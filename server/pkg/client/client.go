@@ -0,0 +1,189 @@
+// Package client implements a Go client for the neko REST API, its WebSocket
+// protocol and WebRTC signaling, so that bots and test harnesses do not need
+// to reimplement them.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/m1k1o/neko/server/pkg/types"
+	"github.com/m1k1o/neko/server/pkg/utils"
+)
+
+type SessionData struct {
+	ID      string              `json:"id"`
+	Token   string              `json:"token,omitempty"`
+	Profile types.MemberProfile `json:"profile"`
+	State   types.SessionState  `json:"state"`
+}
+
+type Client struct {
+	url  *url.URL
+	http *http.Client
+
+	tokenMu sync.RWMutex
+	token   string
+}
+
+// New creates a client for the server at baseURL, e.g. http://127.0.0.1:8080.
+// If the server uses a path prefix, it must be part of the url.
+func New(baseURL string) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported url scheme %q", u.Scheme)
+	}
+
+	// cookies are used when the server has them enabled, otherwise the bearer token
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		url:  u,
+		http: &http.Client{Jar: jar},
+	}, nil
+}
+
+// Token returns the session token used as bearer authentication.
+func (c *Client) Token() string {
+	c.tokenMu.RLock()
+	defer c.tokenMu.RUnlock()
+
+	return c.token
+}
+
+// SetToken sets the session or api token used as bearer authentication.
+func (c *Client) SetToken(token string) {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+
+	c.token = token
+}
+
+func (c *Client) Login(ctx context.Context, username, password string) (*SessionData, error) {
+	data := &SessionData{}
+	err := c.request(ctx, http.MethodPost, "/api/login", map[string]string{
+		"username": username,
+		"password": password,
+	}, data)
+	if err != nil {
+		return nil, err
+	}
+
+	// empty when the server uses cookies
+	if data.Token != "" {
+		c.SetToken(data.Token)
+	}
+
+	return data, nil
+}
+
+func (c *Client) Logout(ctx context.Context) error {
+	if err := c.request(ctx, http.MethodPost, "/api/logout", nil, nil); err != nil {
+		return err
+	}
+
+	c.SetToken("")
+	return nil
+}
+
+func (c *Client) Whoami(ctx context.Context) (*SessionData, error) {
+	data := &SessionData{}
+	if err := c.request(ctx, http.MethodGet, "/api/whoami", nil, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (c *Client) endpoint(path string) *url.URL {
+	path, query, _ := strings.Cut(path, "?")
+
+	u := *c.url
+	u.Path = strings.TrimSuffix(u.Path, "/") + path
+	u.RawQuery = query
+	return &u
+}
+
+// request sends in as json body and decodes json response into out, nil values are skipped.
+func (c *Client) request(ctx context.Context, method, path string, in, out any) error {
+	res, err := c.do(ctx, method, path, in)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if out == nil {
+		_, err = io.Copy(io.Discard, res.Body)
+		return err
+	}
+
+	return json.NewDecoder(res.Body).Decode(out)
+}
+
+// requestBytes sends in as json body and returns raw response body.
+func (c *Client) requestBytes(ctx context.Context, method, path string, in any) ([]byte, error) {
+	res, err := c.do(ctx, method, path, in)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	return io.ReadAll(res.Body)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, in any) (*http.Response, error) {
+	var body io.Reader
+	if in != nil {
+		raw, err := json.Marshal(in)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(raw)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.endpoint(path).String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	if token := c.Token(); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return res, nil
+	}
+
+	defer res.Body.Close()
+
+	// server responds with json encoded http error
+	httpErr := &utils.HTTPError{Code: res.StatusCode}
+	if err := json.NewDecoder(res.Body).Decode(httpErr); err != nil || httpErr.Message == "" {
+		httpErr.Message = http.StatusText(res.StatusCode)
+	}
+
+	return nil, httpErr
+}
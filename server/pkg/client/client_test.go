@@ -0,0 +1,346 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/pion/webrtc/v4"
+	"github.com/pion/webrtc/v4/pkg/media"
+
+	"github.com/m1k1o/neko/server/internal/api"
+	"github.com/m1k1o/neko/server/internal/config"
+	nekohttp "github.com/m1k1o/neko/server/internal/http"
+	"github.com/m1k1o/neko/server/internal/member"
+	"github.com/m1k1o/neko/server/internal/member/multiuser"
+	"github.com/m1k1o/neko/server/internal/session"
+	nekows "github.com/m1k1o/neko/server/internal/websocket"
+	"github.com/m1k1o/neko/server/pkg/types"
+	"github.com/m1k1o/neko/server/pkg/types/event"
+	"github.com/m1k1o/neko/server/pkg/types/message"
+	"github.com/m1k1o/neko/server/pkg/utils"
+)
+
+// starts server without desktop and capture, only sessions, members and websocket are available
+func newServer(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	sessions := session.New(&config.Session{WSTicketExpiration: time.Minute})
+	members := member.New(sessions, &config.Member{
+		Provider: "multiuser",
+		Multiuser: multiuser.Config{
+			AdminPassword: "admin",
+			UserPassword:  "neko",
+			AdminProfile:  types.MemberProfile{IsAdmin: true, CanLogin: true, CanConnect: true},
+			UserProfile:   types.MemberProfile{CanLogin: true, CanConnect: true},
+		},
+	})
+	if err := members.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	webSocket := nekows.New(sessions, nil, nil, nil)
+	apiManager := api.New(sessions, members, nil, nil, nil)
+
+	server := nekohttp.New(webSocket, apiManager, &config.Server{Bind: addr, PathPrefix: "/"})
+	server.Start()
+	t.Cleanup(func() {
+		server.Shutdown()
+	})
+
+	baseURL := "http://" + addr
+	for range 50 {
+		if res, err := http.Get(baseURL + "/health"); err == nil {
+			res.Body.Close()
+			return baseURL
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatal("server did not start")
+	return ""
+}
+
+func login(t *testing.T, baseURL, username, password string) (*Client, *SessionData) {
+	t.Helper()
+
+	c, err := New(baseURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := c.Login(context.Background(), username, password)
+	if err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+
+	return c, data
+}
+
+func TestREST(t *testing.T) {
+	ctx := context.Background()
+	baseURL := newServer(t)
+
+	admin, adminData := login(t, baseURL, "admin", "admin")
+	if !adminData.Profile.IsAdmin {
+		t.Error("expected admin profile")
+	}
+
+	whoami, err := admin.Whoami(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if whoami.ID != adminData.ID {
+		t.Errorf("whoami returned %q, want %q", whoami.ID, adminData.ID)
+	}
+
+	user, userData := login(t, baseURL, "user", "neko")
+
+	sessions, err := admin.Sessions(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 2 {
+		t.Errorf("got %d sessions, want 2", len(sessions))
+	}
+
+	data, err := admin.Session(ctx, userData.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.Profile.IsAdmin {
+		t.Error("expected user profile")
+	}
+
+	// server errors are decoded
+	var httpErr *utils.HTTPError
+	if _, err := user.Session(ctx, adminData.ID); !errors.As(err, &httpErr) || httpErr.Code != http.StatusForbidden {
+		t.Errorf("expected forbidden error, got %v", err)
+	}
+
+	if err := user.Logout(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := user.Whoami(ctx); !errors.As(err, &httpErr) || httpErr.Code != http.StatusUnauthorized {
+		t.Errorf("expected unauthorized error, got %v", err)
+	}
+}
+
+func TestWebSocket(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	baseURL := newServer(t)
+
+	sender, senderData := login(t, baseURL, "sender", "neko")
+	receiver, _ := login(t, baseURL, "receiver", "neko")
+
+	senderConn, err := sender.Connect(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer senderConn.Close()
+
+	receiverConn, err := receiver.Connect(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer receiverConn.Close()
+
+	received := make(chan message.SendBroadcast, 1)
+	receiverConn.OnSendBroadcast(func(payload message.SendBroadcast) {
+		received <- payload
+	})
+
+	err = senderConn.Send(event.SEND_BROADCAST, message.SendBroadcast{
+		Subject: "test",
+		Body:    "hello",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case payload := <-received:
+		if payload.Sender != senderData.ID || payload.Subject != "test" {
+			t.Errorf("unexpected payload %+v", payload)
+		}
+	case <-ctx.Done():
+		t.Fatal("broadcast was not received")
+	}
+
+	// ticket can be requested explicitly as well
+	ticket, err := sender.WebSocketTicket(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ticket.Ticket == "" || !ticket.ExpiresAt.After(time.Now()) {
+		t.Errorf("unexpected ticket %+v", ticket)
+	}
+}
+
+// webrtc peers cannot be created without capture, signaling server is therefore faked
+func TestWatch(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	settings := webrtc.SettingEngine{}
+	settings.SetIncludeLoopbackCandidate(true)
+	settings.SetNetworkTypes([]webrtc.NetworkType{webrtc.NetworkTypeUDP4})
+	settings.SetInterfaceFilter(func(name string) bool { return name == "lo" })
+
+	mediaEngine := &webrtc.MediaEngine{}
+	if err := mediaEngine.RegisterDefaultCodecs(); err != nil {
+		t.Fatal(err)
+	}
+
+	webrtcAPI := webrtc.NewAPI(webrtc.WithSettingEngine(settings), webrtc.WithMediaEngine(mediaEngine))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/ws-ticket", func(w http.ResponseWriter, r *http.Request) {
+		utils.HttpSuccess(w, WebSocketTicket{Ticket: "ticket", ExpiresAt: time.Now().Add(time.Minute)})
+	})
+	mux.HandleFunc("GET /api/ws", func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		serveSignaling(t, ctx, conn, webrtcAPI)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	c, err := New(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ws, err := c.Connect(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+
+	samples := make(chan *media.Sample, 1)
+	peer, err := ws.Watch(ctx, PeerOptions{
+		API: webrtcAPI,
+		OnVideoSample: func(sample *media.Sample) {
+			select {
+			case samples <- sample:
+			default:
+			}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peer.Close()
+
+	select {
+	case sample := <-samples:
+		if len(sample.Data) == 0 {
+			t.Error("received empty sample")
+		}
+	case <-ctx.Done():
+		t.Fatal("no video sample was received")
+	}
+}
+
+func serveSignaling(t *testing.T, ctx context.Context, conn *websocket.Conn, webrtcAPI *webrtc.API) {
+	var connection *webrtc.PeerConnection
+	defer func() {
+		if connection != nil {
+			connection.Close()
+		}
+	}()
+
+	track, err := webrtc.NewTrackLocalStaticSample(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8}, "video", "stream")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	for {
+		data := types.WebSocketMessage{}
+		if err := conn.ReadJSON(&data); err != nil {
+			return
+		}
+
+		switch data.Event {
+		case event.SIGNAL_REQUEST:
+			connection, err = webrtcAPI.NewPeerConnection(webrtc.Configuration{})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			if _, err := connection.AddTrack(track); err != nil {
+				t.Error(err)
+				return
+			}
+
+			offer, err := connection.CreateOffer(nil)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			// gather all candidates upfront, so that they do not need to be trickled
+			gathered := webrtc.GatheringCompletePromise(connection)
+			if err := connection.SetLocalDescription(offer); err != nil {
+				t.Error(err)
+				return
+			}
+			<-gathered
+
+			payload, _ := json.Marshal(message.SignalProvide{SDP: connection.LocalDescription().SDP})
+			if err := conn.WriteJSON(types.WebSocketMessage{Event: event.SIGNAL_PROVIDE, Payload: payload}); err != nil {
+				return
+			}
+		case event.SIGNAL_ANSWER:
+			answer := message.SignalDescription{}
+			if err := json.Unmarshal(data.Payload, &answer); err != nil {
+				t.Error(err)
+				return
+			}
+
+			err := connection.SetRemoteDescription(webrtc.SessionDescription{Type: webrtc.SDPTypeAnswer, SDP: answer.SDP})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			go func() {
+				ticker := time.NewTicker(20 * time.Millisecond)
+				defer ticker.Stop()
+
+				for {
+					select {
+					case <-ctx.Done():
+						return
+					case <-ticker.C:
+						// key frame header followed by dummy data
+						frame := []byte{0x10, 0x02, 0x00, 0x9d, 0x01, 0x2a, 0x00, 0x00, 0x00, 0x00}
+						track.WriteSample(media.Sample{Data: frame, Duration: 20 * time.Millisecond})
+					}
+				}
+			}()
+		}
+	}
+}
@@ -0,0 +1,372 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/pion/rtp"
+	"github.com/pion/rtp/codecs"
+	"github.com/pion/webrtc/v4"
+	"github.com/pion/webrtc/v4/pkg/media"
+	"github.com/pion/webrtc/v4/pkg/media/samplebuilder"
+
+	"github.com/m1k1o/neko/server/internal/webrtc/payload"
+	"github.com/m1k1o/neko/server/pkg/types"
+	"github.com/m1k1o/neko/server/pkg/types/event"
+	"github.com/m1k1o/neko/server/pkg/types/message"
+)
+
+// how many packets can be buffered while waiting for missing ones
+const sampleMaxLate = 128
+
+type PeerOptions struct {
+	Video types.PeerVideoRequest
+	Audio types.PeerAudioRequest
+	// video codecs in order of preference, server default is used when empty
+	VideoCodecs []string
+
+	// ICE servers to be used instead of those provided by the server
+	ICEServers []webrtc.ICEServer
+	// custom webrtc api, e.g. with setting engine or interceptors
+	API *webrtc.API
+
+	// called for every remote track, before samples are read from it
+	OnTrack func(track *webrtc.TrackRemote)
+	// called with depacketized samples, track is not read when both are nil
+	OnVideoSample func(sample *media.Sample)
+	OnAudioSample func(sample *media.Sample)
+}
+
+type Peer struct {
+	ws      *Conn
+	opts    PeerOptions
+	provide message.SignalProvide
+
+	mu          sync.Mutex
+	connection  *webrtc.PeerConnection
+	unsubscribe []func()
+	// candidates received before remote description was set
+	candidates []webrtc.ICECandidateInit
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// Watch requests a webrtc peer from the server and answers its offer. Media is received
+// through callbacks in options. Returned peer is ready, but might not be connected yet.
+func (ws *Conn) Watch(ctx context.Context, opts PeerOptions) (*Peer, error) {
+	peer := &Peer{
+		ws:   ws,
+		opts: opts,
+		done: make(chan struct{}),
+	}
+
+	// candidates can arrive before the peer connection is created
+	peer.subscribe(
+		Subscribe(ws, event.SIGNAL_CANDIDATE, peer.onCandidate),
+		Subscribe(ws, event.SIGNAL_CLOSE, func(any) { peer.Close() }),
+	)
+
+	provideCh := make(chan message.SignalProvide, 1)
+	unsubscribe := Subscribe(ws, event.SIGNAL_PROVIDE, func(payload message.SignalProvide) {
+		select {
+		case provideCh <- payload:
+		default:
+		}
+	})
+	defer unsubscribe()
+
+	err := ws.Send(event.SIGNAL_REQUEST, message.SignalRequest{
+		Video:       opts.Video,
+		Audio:       opts.Audio,
+		VideoCodecs: opts.VideoCodecs,
+	})
+	if err != nil {
+		peer.Close()
+		return nil, err
+	}
+
+	select {
+	case peer.provide = <-provideCh:
+	case <-ws.Done():
+		peer.Close()
+		return nil, ErrConnClosed
+	case <-ctx.Done():
+		peer.Close()
+		return nil, ctx.Err()
+	}
+
+	if err := peer.connect(); err != nil {
+		peer.Close()
+		return nil, err
+	}
+
+	return peer, nil
+}
+
+func (peer *Peer) connect() error {
+	iceServers := peer.opts.ICEServers
+	if iceServers == nil {
+		for _, server := range peer.provide.ICEServers {
+			iceServers = append(iceServers, webrtc.ICEServer{
+				URLs:       server.URLs,
+				Username:   server.Username,
+				Credential: server.Credential,
+			})
+		}
+	}
+
+	newPeerConnection := webrtc.NewPeerConnection
+	if peer.opts.API != nil {
+		newPeerConnection = peer.opts.API.NewPeerConnection
+	}
+
+	connection, err := newPeerConnection(webrtc.Configuration{
+		ICEServers: iceServers,
+	})
+	if err != nil {
+		return err
+	}
+
+	peer.mu.Lock()
+	peer.connection = connection
+	peer.mu.Unlock()
+
+	connection.OnICECandidate(func(candidate *webrtc.ICECandidate) {
+		if candidate == nil {
+			return
+		}
+
+		peer.ws.Send(event.SIGNAL_CANDIDATE, message.SignalCandidate{
+			ICECandidateInit: candidate.ToJSON(),
+		})
+	})
+
+	connection.OnConnectionStateChange(func(state webrtc.PeerConnectionState) {
+		if state == webrtc.PeerConnectionStateFailed || state == webrtc.PeerConnectionStateClosed {
+			peer.Close()
+		}
+	})
+
+	connection.OnTrack(peer.onTrack)
+	connection.OnDataChannel(peer.onDataChannel)
+
+	// server renegotiates when tracks change and on ice restart
+	onOffer := func(payload message.SignalDescription) {
+		if err := peer.answer(payload.SDP); err != nil {
+			peer.Close()
+		}
+	}
+
+	peer.subscribe(
+		Subscribe(peer.ws, event.SIGNAL_OFFER, onOffer),
+		Subscribe(peer.ws, event.SIGNAL_RESTART, onOffer),
+	)
+
+	return peer.answer(peer.provide.SDP)
+}
+
+func (peer *Peer) answer(offer string) error {
+	err := peer.connection.SetRemoteDescription(webrtc.SessionDescription{
+		Type: webrtc.SDPTypeOffer,
+		SDP:  offer,
+	})
+	if err != nil {
+		return err
+	}
+
+	peer.mu.Lock()
+	candidates := peer.candidates
+	peer.candidates = nil
+	peer.mu.Unlock()
+
+	for _, candidate := range candidates {
+		if err := peer.connection.AddICECandidate(candidate); err != nil {
+			return err
+		}
+	}
+
+	answer, err := peer.connection.CreateAnswer(nil)
+	if err != nil {
+		return err
+	}
+
+	if err := peer.connection.SetLocalDescription(answer); err != nil {
+		return err
+	}
+
+	return peer.ws.Send(event.SIGNAL_ANSWER, message.SignalDescription{
+		SDP: answer.SDP,
+	})
+}
+
+func (peer *Peer) subscribe(unsubscribe ...func()) {
+	peer.mu.Lock()
+	defer peer.mu.Unlock()
+
+	select {
+	case <-peer.done:
+		// peer was closed in the meantime
+		for _, fn := range unsubscribe {
+			fn()
+		}
+	default:
+		peer.unsubscribe = append(peer.unsubscribe, unsubscribe...)
+	}
+}
+
+func (peer *Peer) onCandidate(payload message.SignalCandidate) {
+	peer.mu.Lock()
+	defer peer.mu.Unlock()
+
+	if peer.connection == nil || peer.connection.RemoteDescription() == nil {
+		peer.candidates = append(peer.candidates, payload.ICECandidateInit)
+		return
+	}
+
+	peer.connection.AddICECandidate(payload.ICECandidateInit)
+}
+
+func (peer *Peer) onTrack(track *webrtc.TrackRemote, receiver *webrtc.RTPReceiver) {
+	if peer.opts.OnTrack != nil {
+		peer.opts.OnTrack(track)
+	}
+
+	onSample := peer.opts.OnAudioSample
+	if track.Kind() == webrtc.RTPCodecTypeVideo {
+		onSample = peer.opts.OnVideoSample
+	}
+
+	if onSample == nil {
+		return
+	}
+
+	codec := track.Codec()
+	depacketizer := newDepacketizer(codec.MimeType)
+	if depacketizer == nil {
+		return
+	}
+
+	builder := samplebuilder.New(sampleMaxLate, depacketizer, codec.ClockRate)
+
+	go func() {
+		for {
+			packet, _, err := track.ReadRTP()
+			if err != nil {
+				return
+			}
+
+			builder.Push(packet)
+			for sample := builder.Pop(); sample != nil; sample = builder.Pop() {
+				onSample(sample)
+			}
+		}
+	}()
+}
+
+// onDataChannel echoes heartbeats sent by the server, so that it can measure latency
+// of this peer and does not disconnect it when the grace period is over.
+func (peer *Peer) onDataChannel(dataChannel *webrtc.DataChannel) {
+	dataChannel.OnMessage(func(msg webrtc.DataChannelMessage) {
+		buffer := bytes.NewBuffer(msg.Data)
+
+		header := payload.Header{}
+		if err := binary.Read(buffer, binary.BigEndian, &header); err != nil {
+			return
+		}
+
+		if header.Event != payload.OP_HEARTBEAT {
+			return
+		}
+
+		heartbeat := payload.Heartbeat{}
+		if err := binary.Read(buffer, binary.BigEndian, &heartbeat); err != nil {
+			return
+		}
+
+		ack := &bytes.Buffer{}
+		header.Event = payload.OP_HEARTBEAT_ACK
+
+		if err := binary.Write(ack, binary.BigEndian, header); err != nil {
+			return
+		}
+
+		if err := binary.Write(ack, binary.BigEndian, heartbeat); err != nil {
+			return
+		}
+
+		dataChannel.Send(ack.Bytes())
+	})
+}
+
+func newDepacketizer(mimeType string) rtp.Depacketizer {
+	switch strings.ToLower(mimeType) {
+	case strings.ToLower(webrtc.MimeTypeVP8):
+		return &codecs.VP8Packet{}
+	case strings.ToLower(webrtc.MimeTypeVP9):
+		return &codecs.VP9Packet{}
+	case strings.ToLower(webrtc.MimeTypeH264):
+		return &codecs.H264Packet{}
+	case strings.ToLower(webrtc.MimeTypeAV1):
+		return &codecs.AV1Depacketizer{}
+	case strings.ToLower(webrtc.MimeTypeOpus):
+		return &codecs.OpusPacket{}
+	}
+	return nil
+}
+
+// SetVideo selects a different video stream.
+func (peer *Peer) SetVideo(video types.PeerVideoRequest) error {
+	return peer.ws.Send(event.SIGNAL_VIDEO, message.SignalVideo{PeerVideoRequest: video})
+}
+
+// SetAudio enables or disables audio stream.
+func (peer *Peer) SetAudio(audio types.PeerAudioRequest) error {
+	return peer.ws.Send(event.SIGNAL_AUDIO, message.SignalAudio{PeerAudioRequest: audio})
+}
+
+// Provided returns streams and ice servers provided by the server.
+func (peer *Peer) Provided() message.SignalProvide {
+	return peer.provide
+}
+
+// PeerConnection returns the underlying peer connection, nil before it is created.
+func (peer *Peer) PeerConnection() *webrtc.PeerConnection {
+	peer.mu.Lock()
+	defer peer.mu.Unlock()
+
+	return peer.connection
+}
+
+// Done is closed when the peer is closed.
+func (peer *Peer) Done() <-chan struct{} {
+	return peer.done
+}
+
+func (peer *Peer) Close() error {
+	var err error
+	peer.closeOnce.Do(func() {
+		peer.mu.Lock()
+		close(peer.done)
+		unsubscribe, connection := peer.unsubscribe, peer.connection
+		peer.unsubscribe = nil
+		peer.mu.Unlock()
+
+		for _, fn := range unsubscribe {
+			fn()
+		}
+
+		if connection != nil {
+			err = connection.Close()
+		}
+	})
+
+	if errors.Is(err, webrtc.ErrConnectionClosed) {
+		return nil
+	}
+	return err
+}
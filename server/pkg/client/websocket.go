@@ -0,0 +1,308 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/m1k1o/neko/server/pkg/types"
+	"github.com/m1k1o/neko/server/pkg/types/event"
+	"github.com/m1k1o/neko/server/pkg/types/message"
+)
+
+// period for sending pings to the server
+const pingPeriod = 10 * time.Second
+
+var ErrConnClosed = errors.New("connection closed")
+
+type WebSocketTicket struct {
+	Ticket    string    `json:"ticket"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type subscription struct {
+	event   string // empty means all events
+	handler func(types.WebSocketMessage)
+}
+
+type Conn struct {
+	conn    *websocket.Conn
+	writeMu sync.Mutex
+
+	subsMu sync.RWMutex
+	subs   map[uint64]subscription
+	nextId uint64
+
+	done     chan struct{}
+	doneOnce sync.Once
+	err      error
+}
+
+// WebSocketTicket creates a single-use ticket for opening a websocket connection.
+func (c *Client) WebSocketTicket(ctx context.Context) (*WebSocketTicket, error) {
+	data := &WebSocketTicket{}
+	if err := c.request(ctx, http.MethodPost, "/api/ws-ticket", nil, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Connect opens a websocket connection authenticated by a single-use ticket, so that
+// neither the token nor cookies need to be forwarded to the websocket handshake.
+func (c *Client) Connect(ctx context.Context) (*Conn, error) {
+	ticket, err := c.WebSocketTicket(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	u := c.endpoint("/api/ws")
+	if u.Scheme == "https" {
+		u.Scheme = "wss"
+	} else {
+		u.Scheme = "ws"
+	}
+
+	query := u.Query()
+	query.Set("ticket", ticket.Ticket)
+	u.RawQuery = query.Encode()
+
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	ws := &Conn{
+		conn: conn,
+		subs: make(map[uint64]subscription),
+		done: make(chan struct{}),
+	}
+
+	go ws.read()
+	go ws.ping()
+
+	return ws, nil
+}
+
+func (ws *Conn) read() {
+	for {
+		data := types.WebSocketMessage{}
+		if err := ws.conn.ReadJSON(&data); err != nil {
+			ws.close(err)
+			return
+		}
+
+		// application level heartbeat, server expects it to be answered
+		if data.Event == event.SYSTEM_HEARTBEAT {
+			if err := ws.Send(event.CLIENT_HEARTBEAT, nil); err != nil {
+				ws.close(err)
+				return
+			}
+		}
+
+		ws.subsMu.RLock()
+		subs := make([]subscription, 0, len(ws.subs))
+		for _, sub := range ws.subs {
+			if sub.event == "" || sub.event == data.Event {
+				subs = append(subs, sub)
+			}
+		}
+		ws.subsMu.RUnlock()
+
+		for _, sub := range subs {
+			sub.handler(data)
+		}
+	}
+}
+
+func (ws *Conn) ping() {
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ws.done:
+			return
+		case <-ticker.C:
+			ws.writeMu.Lock()
+			err := ws.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(pingPeriod))
+			ws.writeMu.Unlock()
+
+			if err != nil {
+				ws.close(err)
+				return
+			}
+		}
+	}
+}
+
+func (ws *Conn) close(err error) {
+	ws.doneOnce.Do(func() {
+		ws.err = err
+		close(ws.done)
+		ws.conn.Close()
+	})
+}
+
+// Send sends event with payload encoded as json, nil payload is omitted.
+func (ws *Conn) Send(event string, payload any) error {
+	data := types.WebSocketMessage{Event: event}
+	if payload != nil {
+		raw, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		data.Payload = raw
+	}
+
+	ws.writeMu.Lock()
+	defer ws.writeMu.Unlock()
+
+	select {
+	case <-ws.done:
+		return ErrConnClosed
+	default:
+	}
+
+	return ws.conn.WriteJSON(data)
+}
+
+// OnMessage registers handler called for every received message. Handlers are called
+// sequentially from the reading goroutine and must not block.
+func (ws *Conn) OnMessage(handler func(types.WebSocketMessage)) (unsubscribe func()) {
+	return ws.subscribe("", handler)
+}
+
+func (ws *Conn) subscribe(event string, handler func(types.WebSocketMessage)) func() {
+	ws.subsMu.Lock()
+	id := ws.nextId
+	ws.nextId++
+	ws.subs[id] = subscription{event, handler}
+	ws.subsMu.Unlock()
+
+	return func() {
+		ws.subsMu.Lock()
+		delete(ws.subs, id)
+		ws.subsMu.Unlock()
+	}
+}
+
+// Done is closed when the connection is closed.
+func (ws *Conn) Done() <-chan struct{} {
+	return ws.done
+}
+
+// Err returns the reason why the connection was closed.
+func (ws *Conn) Err() error {
+	select {
+	case <-ws.done:
+		return ws.err
+	default:
+		return nil
+	}
+}
+
+// Close closes the connection gracefully.
+func (ws *Conn) Close() error {
+	ws.writeMu.Lock()
+	err := ws.conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+		time.Now().Add(time.Second))
+	ws.writeMu.Unlock()
+
+	ws.close(ErrConnClosed)
+	return err
+}
+
+// Subscribe registers typed handler for given event, payloads that cannot be decoded
+// into T are skipped. Handlers are called from the reading goroutine and must not block.
+func Subscribe[T any](ws *Conn, event string, handler func(T)) (unsubscribe func()) {
+	return ws.subscribe(event, func(data types.WebSocketMessage) {
+		var payload T
+		if len(data.Payload) > 0 {
+			if err := json.Unmarshal(data.Payload, &payload); err != nil {
+				return
+			}
+		}
+
+		handler(payload)
+	})
+}
+
+// Wait blocks until the next message with given event is received and returns its payload.
+func Wait[T any](ctx context.Context, ws *Conn, event string) (T, error) {
+	ch := make(chan T, 1)
+	unsubscribe := Subscribe(ws, event, func(payload T) {
+		select {
+		case ch <- payload:
+		default:
+		}
+	})
+	defer unsubscribe()
+
+	var zero T
+	select {
+	case payload := <-ch:
+		return payload, nil
+	case <-ws.done:
+		return zero, ws.err
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	}
+}
+
+//
+// typed subscriptions for common events
+//
+
+func (ws *Conn) OnSystemInit(handler func(message.SystemInit)) func() {
+	return Subscribe(ws, event.SYSTEM_INIT, handler)
+}
+
+func (ws *Conn) OnSystemDisconnect(handler func(message.SystemDisconnect)) func() {
+	return Subscribe(ws, event.SYSTEM_DISCONNECT, handler)
+}
+
+func (ws *Conn) OnSessionCreated(handler func(message.SessionData)) func() {
+	return Subscribe(ws, event.SESSION_CREATED, handler)
+}
+
+func (ws *Conn) OnSessionDeleted(handler func(message.SessionID)) func() {
+	return Subscribe(ws, event.SESSION_DELETED, handler)
+}
+
+func (ws *Conn) OnSessionState(handler func(message.SessionState)) func() {
+	return Subscribe(ws, event.SESSION_STATE, handler)
+}
+
+func (ws *Conn) OnSignalStats(handler func(message.SignalStats)) func() {
+	return Subscribe(ws, event.SIGNAL_STATS, handler)
+}
+
+func (ws *Conn) OnControlHost(handler func(message.ControlHost)) func() {
+	return Subscribe(ws, event.CONTROL_HOST, handler)
+}
+
+func (ws *Conn) OnBroadcastStatus(handler func(message.BroadcastStatus)) func() {
+	return Subscribe(ws, event.BROADCAST_STATUS, handler)
+}
+
+func (ws *Conn) OnScreenUpdated(handler func(message.ScreenSizeUpdate)) func() {
+	return Subscribe(ws, event.SCREEN_UPDATED, handler)
+}
+
+func (ws *Conn) OnClipboardUpdated(handler func(message.ClipboardData)) func() {
+	return Subscribe(ws, event.CLIPBOARD_UPDATED, handler)
+}
+
+func (ws *Conn) OnSendUnicast(handler func(message.SendUnicast)) func() {
+	return Subscribe(ws, event.SEND_UNICAST, handler)
+}
+
+func (ws *Conn) OnSendBroadcast(handler func(message.SendBroadcast)) func() {
+	return Subscribe(ws, event.SEND_BROADCAST, handler)
+}
@@ -67,6 +67,48 @@ static gboolean gstreamer_bus_call(GstBus *bus, GstMessage *msg, gpointer user_d
   return TRUE;
 }
 
+// called synchronously from the thread posting the message, so that listeners are notified
+// even without running main loop, messages are passed to the bus watch afterwards
+static GstBusSyncReply gstreamer_bus_sync_call(GstBus *bus, GstMessage *msg, gpointer user_data) {
+  int pipelineId = GPOINTER_TO_INT(user_data);
+
+  switch (GST_MESSAGE_TYPE(msg)) {
+    case GST_MESSAGE_EOS: {
+      goPipelineEOS(pipelineId);
+      break;
+    }
+
+    case GST_MESSAGE_STATE_CHANGED: {
+      if (!GST_IS_PIPELINE(GST_MESSAGE_SRC(msg))) break;
+
+      GstState new_state;
+      gst_message_parse_state_changed(msg, NULL, &new_state, NULL);
+      if (new_state == GST_STATE_PLAYING) {
+        goPipelinePlaying(pipelineId);
+      }
+      break;
+    }
+
+    case GST_MESSAGE_ERROR: {
+      GError *err = NULL;
+      gst_message_parse_error(msg, &err, NULL);
+
+      gchar *text = g_strdup_printf("error from element %s: %s",
+        GST_OBJECT_NAME(msg->src), err->message);
+      goPipelineError(pipelineId, text);
+
+      g_free(text);
+      g_error_free(err);
+      break;
+    }
+
+    default:
+      break;
+  }
+
+  return GST_BUS_PASS;
+}
+
 GstPipelineCtx *gstreamer_pipeline_create(char *pipelineStr, int pipelineId, GError **error) {
   GstElement *pipeline = gst_parse_launch(pipelineStr, error);
   if (pipeline == NULL) return NULL;
@@ -78,6 +120,7 @@ GstPipelineCtx *gstreamer_pipeline_create(char *pipelineStr, int pipelineId, GEr
 
   GstBus *bus = gst_pipeline_get_bus(GST_PIPELINE(pipeline));
   gst_bus_add_watch(bus, gstreamer_bus_call, ctx);
+  gst_bus_set_sync_handler(bus, gstreamer_bus_sync_call, GINT_TO_POINTER(pipelineId), NULL);
   gst_object_unref(bus);
 
   return ctx;
@@ -85,6 +128,7 @@ GstPipelineCtx *gstreamer_pipeline_create(char *pipelineStr, int pipelineId, GEr
 
 static GstFlowReturn gstreamer_send_new_sample_handler(GstElement *object, gpointer user_data) {
   GstPipelineCtx *ctx = (GstPipelineCtx *)user_data;
+  int sinkId = GPOINTER_TO_INT(g_object_get_data(G_OBJECT(object), "sink-id"));
   GstSample *sample = NULL;
   GstBuffer *buffer = NULL;
   gpointer copy = NULL;
@@ -95,7 +139,7 @@ static GstFlowReturn gstreamer_send_new_sample_handler(GstElement *object, gpoin
     buffer = gst_sample_get_buffer(sample);
     if (buffer) {
       gst_buffer_extract_dup(buffer, 0, gst_buffer_get_size(buffer), &copy, &copy_size);
-      goHandlePipelineBuffer(ctx->pipelineId, copy, copy_size,
+      goHandlePipelineBuffer(ctx->pipelineId, sinkId, copy, copy_size,
         GST_BUFFER_DURATION(buffer),
         GST_BUFFER_FLAG_IS_SET(buffer, GST_BUFFER_FLAG_DELTA_UNIT)
       );
@@ -106,10 +150,17 @@ static GstFlowReturn gstreamer_send_new_sample_handler(GstElement *object, gpoin
   return GST_FLOW_OK;
 }
 
-void gstreamer_pipeline_attach_appsink(GstPipelineCtx *ctx, char *sinkName) {
-  ctx->appsink = gst_bin_get_by_name(GST_BIN(ctx->pipeline), sinkName);
-  g_object_set(ctx->appsink, "emit-signals", TRUE, "sync", FALSE, NULL);
-  g_signal_connect(ctx->appsink, "new-sample", G_CALLBACK(gstreamer_send_new_sample_handler), ctx);
+void gstreamer_pipeline_attach_appsink(GstPipelineCtx *ctx, char *sinkName, int sinkId) {
+  GstElement *appsink = gst_bin_get_by_name(GST_BIN(ctx->pipeline), sinkName);
+  if (appsink == NULL) {
+    gstreamer_pipeline_log(ctx, "error", "appsink %s not found", sinkName);
+    return;
+  }
+
+  g_object_set_data(G_OBJECT(appsink), "sink-id", GINT_TO_POINTER(sinkId));
+  g_object_set(appsink, "emit-signals", TRUE, "sync", FALSE, NULL);
+  g_signal_connect(appsink, "new-sample", G_CALLBACK(gstreamer_send_new_sample_handler), ctx);
+  ctx->appsinks = g_slist_prepend(ctx->appsinks, appsink);
 }
 
 void gstreamer_pipeline_attach_appsrc(GstPipelineCtx *ctx, char *srcName) {
@@ -124,7 +175,20 @@ void gstreamer_pipeline_pause(GstPipelineCtx *ctx) {
   gst_element_set_state(GST_ELEMENT(ctx->pipeline), GST_STATE_PAUSED);
 }
 
+void gstreamer_pipeline_send_eos(GstPipelineCtx *ctx) {
+  if (ctx->appsrc) {
+    gst_app_src_end_of_stream(GST_APP_SRC(ctx->appsrc));
+  }
+
+  gst_element_send_event(GST_ELEMENT(ctx->pipeline), gst_event_new_eos());
+}
+
 void gstreamer_pipeline_destory(GstPipelineCtx *ctx) {
+  // do not notify listeners about eos sent while destroying
+  GstBus *bus = gst_pipeline_get_bus(GST_PIPELINE(ctx->pipeline));
+  gst_bus_set_sync_handler(bus, NULL, NULL, NULL);
+  gst_object_unref(bus);
+
   // end appsrc, if exists
   if (ctx->appsrc) {
     gst_app_src_end_of_stream(GST_APP_SRC(ctx->appsrc));
@@ -136,9 +200,9 @@ void gstreamer_pipeline_destory(GstPipelineCtx *ctx) {
   // set null state
   gst_element_set_state(GST_ELEMENT(ctx->pipeline), GST_STATE_NULL);
 
-  if (ctx->appsink) {
-    gst_object_unref(ctx->appsink);
-    ctx->appsink = NULL;
+  if (ctx->appsinks) {
+    g_slist_free_full(ctx->appsinks, gst_object_unref);
+    ctx->appsinks = NULL;
   }
 
   if (ctx->appsrc) {
@@ -157,6 +221,16 @@ void gstreamer_pipeline_push(GstPipelineCtx *ctx, void *buffer, int bufferLen) {
   }
 }
 
+void gstreamer_pipeline_push_to(GstPipelineCtx *ctx, char *srcName, void *buffer, int bufferLen) {
+  GstElement *appsrc = gst_bin_get_by_name(GST_BIN(ctx->pipeline), srcName);
+  if (appsrc == NULL) return;
+
+  gpointer p = g_memdup2(buffer, bufferLen);
+  GstBuffer *buf = gst_buffer_new_wrapped(p, bufferLen);
+  gst_app_src_push_buffer(GST_APP_SRC(appsrc), buf);
+  gst_object_unref(appsrc);
+}
+
 gboolean gstreamer_pipeline_set_prop_int(GstPipelineCtx *ctx, char *binName, char *prop, gint value) {
   GstElement *el = gst_bin_get_by_name(GST_BIN(ctx->pipeline), binName);
   if (el == NULL) return FALSE;
@@ -7,6 +7,7 @@ package gst
 */
 import "C"
 import (
+	"errors"
 	"fmt"
 	"sync"
 	"sync/atomic"
@@ -36,18 +37,26 @@ type Pipeline interface {
 	Sample() chan types.Sample
 	// attach sink or src to pipeline
 	AttachAppsink(sinkName string)
+	// attach another sink, whose samples are sent to its own channel
+	AttachAppsinkChannel(sinkName string) chan types.Sample
 	AttachAppsrc(srcName string)
 	// control pipeline lifecycle
 	Play()
 	Pause()
 	Destroy()
 	Push(buffer []byte)
+	PushTo(srcName string, buffer []byte)
 	// modify the property of a bin
 	SetPropInt(binName string, prop string, value int) bool
 	SetCapsFramerate(binName string, numerator, denominator int) bool
 	SetCapsResolution(binName string, width, height int) bool
 	// emit video keyframe
 	EmitVideoKeyframe() bool
+	// send end of stream and wait until it reaches sinks
+	EndOfStream(timeout time.Duration) bool
+	// listeners are called in their own goroutine, register them before playing
+	OnPlaying(listener func())
+	OnError(listener func(err error))
 }
 
 type pipeline struct {
@@ -56,6 +65,14 @@ type pipeline struct {
 	src    string
 	ctx    *C.GstPipelineCtx
 	sample chan types.Sample
+	// additional sinks indexed by sink id - 1
+	sinks []chan types.Sample
+	// bus listeners
+	onPlaying func()
+	onError   func(err error)
+	// end of stream requested by EndOfStream
+	ending bool
+	eos    chan struct{}
 }
 
 func CreatePipeline(pipelineStr string) (Pipeline, error) {
@@ -102,7 +119,22 @@ func (p *pipeline) AttachAppsink(sinkName string) {
 	sinkNameUnsafe := C.CString(sinkName)
 	defer C.free(unsafe.Pointer(sinkNameUnsafe))
 
-	C.gstreamer_pipeline_attach_appsink(p.ctx, sinkNameUnsafe)
+	C.gstreamer_pipeline_attach_appsink(p.ctx, sinkNameUnsafe, 0)
+}
+
+func (p *pipeline) AttachAppsinkChannel(sinkName string) chan types.Sample {
+	sinkNameUnsafe := C.CString(sinkName)
+	defer C.free(unsafe.Pointer(sinkNameUnsafe))
+
+	sample := make(chan types.Sample, 4)
+
+	pipelinesLock.Lock()
+	p.sinks = append(p.sinks, sample)
+	sinkId := len(p.sinks)
+	pipelinesLock.Unlock()
+
+	C.gstreamer_pipeline_attach_appsink(p.ctx, sinkNameUnsafe, C.int(sinkId))
+	return sample
 }
 
 func (p *pipeline) AttachAppsrc(srcName string) {
@@ -128,6 +160,9 @@ func (p *pipeline) Destroy() {
 	pipelinesLock.Unlock()
 
 	close(p.sample)
+	for _, sample := range p.sinks {
+		close(sample)
+	}
 	C.free(unsafe.Pointer(p.ctx))
 }
 
@@ -138,6 +173,17 @@ func (p *pipeline) Push(buffer []byte) {
 	C.gstreamer_pipeline_push(p.ctx, bytes, C.int(len(buffer)))
 }
 
+// PushTo pushes buffer to appsrc found by its name, used by pipelines with multiple sources.
+func (p *pipeline) PushTo(srcName string, buffer []byte) {
+	srcNameUnsafe := C.CString(srcName)
+	defer C.free(unsafe.Pointer(srcNameUnsafe))
+
+	bytes := C.CBytes(buffer)
+	defer C.free(bytes)
+
+	C.gstreamer_pipeline_push_to(p.ctx, srcNameUnsafe, bytes, C.int(len(buffer)))
+}
+
 func (p *pipeline) SetPropInt(binName string, prop string, value int) bool {
 	cBinName := C.CString(binName)
 	defer C.free(unsafe.Pointer(cBinName))
@@ -184,6 +230,42 @@ func (p *pipeline) EmitVideoKeyframe() bool {
 	return ok == C.TRUE
 }
 
+func (p *pipeline) OnPlaying(listener func()) {
+	pipelinesLock.Lock()
+	defer pipelinesLock.Unlock()
+
+	p.onPlaying = listener
+}
+
+// OnError is called on error or end of stream, pipeline is not usable afterwards.
+func (p *pipeline) OnError(listener func(err error)) {
+	pipelinesLock.Lock()
+	defer pipelinesLock.Unlock()
+
+	p.onError = listener
+}
+
+// EndOfStream sends end of stream and waits until it passes through the pipeline, so that muxers
+// can finalize their output. Error listener is not notified, returns false on timeout.
+func (p *pipeline) EndOfStream(timeout time.Duration) bool {
+	eos := make(chan struct{})
+
+	pipelinesLock.Lock()
+	p.ending = true
+	p.eos = eos
+	pipelinesLock.Unlock()
+
+	C.gstreamer_pipeline_send_eos(p.ctx)
+
+	select {
+	case <-eos:
+		return true
+	case <-time.After(timeout):
+		p.logger.Warn().Msg("timeout while waiting for end of stream")
+		return false
+	}
+}
+
 // gst-inspect-1.0
 func CheckPlugins(plugins []string) error {
 	var plugin *C.GstPlugin
@@ -211,15 +293,22 @@ func CheckElement(element string) error {
 }
 
 //export goHandlePipelineBuffer
-func goHandlePipelineBuffer(pipelineID C.int, buf C.gpointer, bufLen C.int, duration C.guint64, deltaUnit C.gboolean) {
+func goHandlePipelineBuffer(pipelineID C.int, sinkID C.int, buf C.gpointer, bufLen C.int, duration C.guint64, deltaUnit C.gboolean) {
 	defer C.g_free(buf)
 
 	pipelinesLock.Lock()
 	pipeline, ok := pipelines[int(pipelineID)]
+	var sample chan types.Sample
+	if ok {
+		sample = pipeline.sample
+		if id := int(sinkID); id > 0 && id <= len(pipeline.sinks) {
+			sample = pipeline.sinks[id-1]
+		}
+	}
 	pipelinesLock.Unlock()
 
 	if ok {
-		pipeline.sample <- types.Sample{
+		sample <- types.Sample{
 			Data:      C.GoBytes(unsafe.Pointer(buf), bufLen),
 			Length:    int(bufLen),
 			Timestamp: time.Now(),
@@ -247,3 +336,59 @@ func goPipelineLog(pipelineID C.int, levelUnsafe *C.char, msgUnsafe *C.char) {
 		Int("pipeline_id", int(pipelineID)).
 		Msg(msg)
 }
+
+//export goPipelinePlaying
+func goPipelinePlaying(pipelineID C.int) {
+	pipelinesLock.Lock()
+	pipeline, ok := pipelines[int(pipelineID)]
+	var listener func()
+	if ok {
+		listener = pipeline.onPlaying
+	}
+	pipelinesLock.Unlock()
+
+	// called from streaming thread, that must not be blocked
+	if listener != nil {
+		go listener()
+	}
+}
+
+//export goPipelineError
+func goPipelineError(pipelineID C.int, msgUnsafe *C.char) {
+	err := errors.New(C.GoString(msgUnsafe))
+
+	pipelinesLock.Lock()
+	pipeline, ok := pipelines[int(pipelineID)]
+	var listener func(err error)
+	if ok {
+		listener = pipeline.onError
+	}
+	pipelinesLock.Unlock()
+
+	// called from streaming thread, that must not be blocked
+	if listener != nil {
+		go listener(err)
+	}
+}
+
+//export goPipelineEOS
+func goPipelineEOS(pipelineID C.int) {
+	pipelinesLock.Lock()
+	pipeline, ok := pipelines[int(pipelineID)]
+	var listener func(err error)
+	if ok {
+		if pipeline.eos != nil {
+			close(pipeline.eos)
+			pipeline.eos = nil
+		}
+		if !pipeline.ending {
+			listener = pipeline.onError
+		}
+	}
+	pipelinesLock.Unlock()
+
+	// called from streaming thread, that must not be blocked
+	if listener != nil {
+		go listener(errors.New("end of stream"))
+	}
+}
@@ -19,20 +19,25 @@
 typedef struct GstPipelineCtx {
   int pipelineId;
   GstElement *pipeline;
-  GstElement *appsink;
+  GSList *appsinks;
   GstElement *appsrc;
 } GstPipelineCtx;
 
-extern void goHandlePipelineBuffer(int pipelineId, void *buffer, int bufferLen, guint64 duration, gboolean deltaUnit);
+extern void goHandlePipelineBuffer(int pipelineId, int sinkId, void *buffer, int bufferLen, guint64 duration, gboolean deltaUnit);
 extern void goPipelineLog(int pipelineId, char *level, char *msg);
+extern void goPipelinePlaying(int pipelineId);
+extern void goPipelineError(int pipelineId, char *msg);
+extern void goPipelineEOS(int pipelineId);
 
 GstPipelineCtx *gstreamer_pipeline_create(char *pipelineStr, int pipelineId, GError **error);
-void gstreamer_pipeline_attach_appsink(GstPipelineCtx *ctx, char *sinkName);
+void gstreamer_pipeline_attach_appsink(GstPipelineCtx *ctx, char *sinkName, int sinkId);
 void gstreamer_pipeline_attach_appsrc(GstPipelineCtx *ctx, char *srcName);
 void gstreamer_pipeline_play(GstPipelineCtx *ctx);
 void gstreamer_pipeline_pause(GstPipelineCtx *ctx);
+void gstreamer_pipeline_send_eos(GstPipelineCtx *ctx);
 void gstreamer_pipeline_destory(GstPipelineCtx *ctx);
 void gstreamer_pipeline_push(GstPipelineCtx *ctx, void *buffer, int bufferLen);
+void gstreamer_pipeline_push_to(GstPipelineCtx *ctx, char *srcName, void *buffer, int bufferLen);
 
 gboolean gstreamer_pipeline_set_prop_int(GstPipelineCtx *ctx, char *binName, char *prop, gint value);
 gboolean gstreamer_pipeline_set_caps_framerate(GstPipelineCtx *ctx, const gchar* binName, gint numerator, gint denominator);
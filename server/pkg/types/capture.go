@@ -15,6 +15,11 @@ import (
 
 var (
 	ErrCapturePipelineAlreadyExists = errors.New("capture pipeline already exists")
+	ErrForwardNotFound              = errors.New("forward not found")
+	ErrStreamSrcInputNotFound       = errors.New("stream-src input not found")
+	ErrRecordingNotFound            = errors.New("recording not found")
+	ErrBroadcastNotFound            = errors.New("broadcast destination not found")
+	ErrHLSSegmentNotFound           = errors.New("hls segment not found")
 )
 
 type Sample struct {
@@ -33,17 +38,105 @@ type SampleListener interface {
 	WriteSample(Sample)
 }
 
+// destination used by single broadcast api
+const BroadcastDefaultID = "main"
+
+// state of started broadcast destination
+const (
+	BroadcastStateConnecting = "connecting"
+	BroadcastStateLive       = "live"
+	BroadcastStateError      = "error" // waiting before reconnecting
+	BroadcastStateRetrying   = "retrying"
+)
+
+type BroadcastDestination struct {
+	ID       string `json:"id"`
+	URL      string `json:"url"`
+	IsActive bool   `json:"is_active"`
+	State    string `json:"state,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
 type BroadcastManager interface {
+	// default destination
 	Start(url string) error
 	Stop()
 	Started() bool
 	Url() string
+
+	StartDestination(id string, url string) error
+	StopDestination(id string) error
+	Destinations() []BroadcastDestination
+
+	// called when destination is started, stopped or its state changes
+	OnStatusChange(listener func(destination BroadcastDestination))
+}
+
+const (
+	ForwardProtocolRTP = "rtp"
+	ForwardProtocolSRT = "srt"
+)
+
+type Forward struct {
+	ID string `json:"id"`
+	// rtp or srt
+	Protocol string `json:"protocol"`
+	// host and port of the receiver, audio is sent to port+2 over rtp
+	Address string `json:"address"`
+	// forwarded video stream, first stream of default codec if empty
+	VideoID    string `json:"video_id,omitempty"`
+	VideoCodec string `json:"video_codec,omitempty"`
+}
+
+type ForwardManager interface {
+	List() []Forward
+	Get(id string) (Forward, bool)
+	Start(forward Forward) (Forward, error)
+	Stop(id string) error
+	// session description of rtp forward, that can be used by receivers
+	SDP(id string) (string, error)
+}
+
+type Recording struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	Active  bool      `json:"active"`
+}
+
+type RecordingManager interface {
+	Start() error
+	Stop()
+	Started() bool
+	// name of the file currently being recorded
+	File() string
+	// called when recording starts, stops or continues in a new file
+	OnStatusChange(listener func(isActive bool, file string))
+
+	// finished and active recordings, ordered from the oldest
+	List() ([]Recording, error)
+	// path of a recording on the disk, that can be downloaded
+	Path(name string) (string, error)
 }
 
 type ScreencastManager interface {
 	Enabled() bool
 	Started() bool
 	Image() ([]byte, error)
+	// sends changed images at most at given frame rate, until context is done
+	Stream(ctx context.Context, rate float64) (<-chan []byte, error)
+}
+
+type HLSManager interface {
+	Enabled() bool
+	Started() bool
+	// maximum number of watch-only webrtc viewers, zero for unlimited
+	MaxWebRTCViewers() int
+
+	// pipeline is started on demand, returns content of the playlist
+	Playlist() ([]byte, error)
+	// path to the segment file
+	Segment(name string) (string, error)
 }
 
 type StreamSelectorType int
@@ -126,6 +219,12 @@ type StreamSinkManager interface {
 	DestroyPipeline()
 }
 
+type StreamSrcInput struct {
+	ID    string  `json:"id"`
+	Muted bool    `json:"muted"`
+	Level float64 `json:"level"`
+}
+
 type StreamSrcManager interface {
 	Codec() codec.RTPCodec
 
@@ -134,6 +233,15 @@ type StreamSrcManager interface {
 	Push(bytes []byte)
 
 	Started() bool
+
+	// when mixing is enabled, multiple inputs are mixed together instead of a single one
+	Mixing() bool
+	AddInput(id string, codec codec.RTPCodec) error
+	RemoveInput(id string)
+	PushInput(id string, bytes []byte)
+	SetInputMuted(id string, muted bool) error
+	SetInputLevel(id string, level float64)
+	Inputs() []StreamSrcInput
 }
 
 type CaptureManager interface {
@@ -141,9 +249,14 @@ type CaptureManager interface {
 	Shutdown() error
 
 	Broadcast() BroadcastManager
+	Recording() RecordingManager
 	Screencast() ScreencastManager
+	HLS() HLSManager
+	Forward() ForwardManager
 	Audio() StreamSinkManager
 	Video() StreamSelectorManager
+	// videos of all configured codecs, default codec is first
+	Videos() []StreamSelectorManager
 
 	Webcam() StreamSrcManager
 	Microphone() StreamSrcManager
@@ -163,6 +276,12 @@ type VideoConfig struct {
 }
 
 func (config *VideoConfig) GetPipeline(screen ScreenSize) (string, error) {
+	return config.GetNamedPipeline(screen, "")
+}
+
+// GetNamedPipeline appends suffix to names of generated elements, so that multiple
+// pipelines can be placed in branches of the same gstreamer pipeline.
+func (config *VideoConfig) GetNamedPipeline(screen ScreenSize, suffix string) (string, error) {
 	values := map[string]any{
 		"width":  screen.Width,
 		"height": screen.Height,
@@ -188,7 +307,13 @@ func (config *VideoConfig) GetPipeline(screen ScreenSize) (string, error) {
 			return "", err
 		}
 
-		fpsPipeline = fmt.Sprintf("! capsfilter caps=video/x-raw,framerate=%d/100 name=framerate ! videoconvert ! queue", int(val*100))
+		// branches of a shared pipeline receive frames at the capture framerate, that must be converted
+		videorate := ""
+		if suffix != "" {
+			videorate = "! videorate "
+		}
+
+		fpsPipeline = fmt.Sprintf("%s! capsfilter caps=video/x-raw,framerate=%d/100 name=framerate%s ! videoconvert ! queue", videorate, int(val*100), suffix)
 	}
 
 	// get scale pipeline
@@ -215,11 +340,11 @@ func (config *VideoConfig) GetPipeline(screen ScreenSize) (string, error) {
 		}
 
 		// element videoscale parameter method to 0 meaning nearest neighbor
-		scalePipeline = fmt.Sprintf("! videoscale method=0 ! capsfilter caps=video/x-raw,width=%d,height=%d name=resolution ! queue", w, h)
+		scalePipeline = fmt.Sprintf("! videoscale method=0 ! capsfilter caps=video/x-raw,width=%d,height=%d name=resolution%s ! queue", w, h, suffix)
 	}
 
 	// get encoder pipeline
-	encPipeline := fmt.Sprintf("! %s name=encoder", config.GstEncoder)
+	encPipeline := fmt.Sprintf("! %s name=encoder%s", config.GstEncoder, suffix)
 	for key, expr := range config.GstParams {
 		if expr == "" {
 			continue
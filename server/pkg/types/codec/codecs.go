@@ -1,8 +1,11 @@
 package codec
 
 import (
+	"fmt"
 	"strings"
 
+	"github.com/pion/rtp"
+	"github.com/pion/rtp/codecs"
 	"github.com/pion/webrtc/v4"
 )
 
@@ -67,6 +70,31 @@ func (codec *RTPCodec) Register(engine *webrtc.MediaEngine) error {
 	}, codec.Type)
 }
 
+// Payloader returns RTP payloader for the codec, used when samples are
+// packetized outside of webrtc, e.g. when forwarding them to RTP receivers.
+func (codec *RTPCodec) Payloader() (rtp.Payloader, error) {
+	switch codec.Name {
+	case VP8().Name:
+		return &codecs.VP8Payloader{EnablePictureID: true}, nil
+	case VP9().Name:
+		return &codecs.VP9Payloader{}, nil
+	case AV1().Name:
+		return &codecs.AV1Payloader{}, nil
+	case H264().Name:
+		return &codecs.H264Payloader{}, nil
+	case H265().Name:
+		return &codecs.H265Payloader{}, nil
+	case Opus().Name:
+		return &codecs.OpusPayloader{}, nil
+	case G722().Name:
+		return &codecs.G722Payloader{}, nil
+	case PCMU().Name, PCMA().Name:
+		return &codecs.G711Payloader{}, nil
+	default:
+		return nil, fmt.Errorf("no payloader for codec %s", codec.Name)
+	}
+}
+
 func (codec *RTPCodec) IsVideo() bool {
 	return codec.Type == webrtc.RTPCodecTypeVideo
 }
@@ -50,9 +50,12 @@ type DesktopManager interface {
 	Shutdown() error
 	OnBeforeScreenSizeChange(listener func())
 	OnAfterScreenSizeChange(listener func())
+	OnPointerGrabChanged(listener func(grabbed bool))
 
 	// xorg
 	Move(x, y int)
+	MoveRelative(deltaX, deltaY int)
+	IsPointerGrabbed() bool
 	GetCursorPosition() (int, int)
 	Scroll(deltaX, deltaY int, controlKey bool)
 	ButtonDown(code uint32) error
@@ -61,6 +64,7 @@ type DesktopManager interface {
 	KeyUp(code uint32) error
 	ButtonPress(code uint32) error
 	KeyPress(codes ...uint32) error
+	TypeText(text string) error
 	ResetKeys()
 	ScreenConfigurations() []ScreenSize
 	SetScreenSize(ScreenSize) (ScreenSize, error)
@@ -23,6 +23,8 @@ const (
 	SIGNAL_VIDEO     = "signal/video"
 	SIGNAL_AUDIO     = "signal/audio"
 	SIGNAL_CLOSE     = "signal/close"
+	SIGNAL_STATS     = "signal/stats"
+	SIGNAL_HLS       = "signal/hls"
 )
 
 const (
@@ -43,10 +45,12 @@ const (
 	CONTROL_BUTTONPRESS = "control/buttonpress"
 	CONTROL_BUTTONDOWN  = "control/buttondown"
 	CONTROL_BUTTONUP    = "control/buttonup"
+	CONTROL_POINTERGRAB = "control/pointergrab"
 	// keyboard
 	CONTROL_KEYPRESS = "control/keypress"
 	CONTROL_KEYDOWN  = "control/keydown"
 	CONTROL_KEYUP    = "control/keyup"
+	CONTROL_TEXT     = "control/text"
 	// touch
 	CONTROL_TOUCHBEGIN  = "control/touchbegin"
 	CONTROL_TOUCHUPDATE = "control/touchupdate"
@@ -63,6 +67,12 @@ const (
 	SCREEN_SET     = "screen/set"
 )
 
+const (
+	SCREENCAST_SUBSCRIBE   = "screencast/subscribe"
+	SCREENCAST_UNSUBSCRIBE = "screencast/unsubscribe"
+	SCREENCAST_FRAME       = "screencast/frame"
+)
+
 const (
 	CLIPBOARD_UPDATED = "clipboard/updated"
 	CLIPBOARD_SET     = "clipboard/set"
@@ -77,6 +87,15 @@ const (
 	BROADCAST_STATUS = "broadcast/status"
 )
 
+const (
+	RECORDING_STATUS = "recording/status"
+)
+
+const (
+	MICROPHONE_INPUTS = "microphone/inputs"
+	MICROPHONE_MUTED  = "microphone/muted"
+)
+
 const (
 	SEND_UNICAST   = "send/unicast"
 	SEND_BROADCAST = "send/broadcast"
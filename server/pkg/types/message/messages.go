@@ -28,6 +28,9 @@ type SystemInit struct {
 type SystemAdmin struct {
 	ScreenSizesList []types.ScreenSize `json:"screen_sizes_list"`
 	BroadcastStatus BroadcastStatus    `json:"broadcast_status"`
+	// all started destinations including the default one
+	BroadcastDestinations []types.BroadcastDestination `json:"broadcast_destinations"`
+	RecordingStatus       RecordingStatus              `json:"recording_status"`
 }
 
 type SystemLogs = []SystemLog
@@ -55,6 +58,12 @@ type SignalRequest struct {
 	Video types.PeerVideoRequest `json:"video"`
 	Audio types.PeerAudioRequest `json:"audio"`
 
+	// video codecs supported by the client, in order of preference
+	VideoCodecs []string `json:"video_codecs,omitempty"`
+
+	// inactive cursors are sent over data channel instead of websocket
+	InactiveCursors bool `json:"inactive_cursors,omitempty"`
+
 	Auto bool `json:"auto"` // TODO: Remove this
 }
 
@@ -66,6 +75,12 @@ type SignalProvide struct {
 	Audio types.PeerAudio `json:"audio"`
 }
 
+// sent instead of provide, when the session should watch using hls
+type SignalHLS struct {
+	// relative to the server base url
+	Playlist string `json:"playlist"`
+}
+
 type SignalCandidate struct {
 	webrtc.ICECandidateInit
 }
@@ -82,6 +97,11 @@ type SignalAudio struct {
 	types.PeerAudioRequest
 }
 
+type SignalStats struct {
+	ID string `json:"id"`
+	types.WebRTCStats
+}
+
 /////////////////////////////
 // Session
 /////////////////////////////
@@ -146,6 +166,15 @@ type ControlKey struct {
 	Keysym uint32 `json:"keysym"`
 }
 
+type ControlPointerGrab struct {
+	Grabbed bool `json:"grabbed"`
+}
+
+type ControlText struct {
+	*ControlPos
+	Text string `json:"text"`
+}
+
 type ControlTouch struct {
 	*ControlPos
 	TouchId  uint32 `json:"touch_id"`
@@ -165,6 +194,20 @@ type ScreenSizeUpdate struct {
 	types.ScreenSize
 }
 
+/////////////////////////////
+// Screencast
+/////////////////////////////
+
+type ScreencastSubscribe struct {
+	// frames per second, only changed images are sent
+	Rate float64 `json:"rate"`
+}
+
+type ScreencastFrame struct {
+	// base64 encoded JPEG image
+	Image []byte `json:"image"`
+}
+
 /////////////////////////////
 // Clipboard
 /////////////////////////////
@@ -190,8 +233,34 @@ type KeyboardModifiers struct {
 /////////////////////////////
 
 type BroadcastStatus struct {
+	// destination, the default one is used by single broadcast api
+	ID       string `json:"id,omitempty"`
 	IsActive bool   `json:"is_active"`
 	URL      string `json:"url,omitempty"`
+	State    string `json:"state,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+/////////////////////////////
+// Recording
+/////////////////////////////
+
+type RecordingStatus struct {
+	IsActive bool   `json:"is_active"`
+	File     string `json:"file,omitempty"`
+}
+
+/////////////////////////////
+// Microphone
+/////////////////////////////
+
+type MicrophoneInputs struct {
+	Inputs []types.StreamSrcInput `json:"inputs"`
+}
+
+type MicrophoneMuted struct {
+	ID    string `json:"id"`
+	Muted bool   `json:"muted"`
 }
 
 /////////////////////////////
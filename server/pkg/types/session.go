@@ -12,6 +12,7 @@ var (
 	ErrSessionAlreadyConnected = errors.New("session is already connected")
 	ErrSessionLoginDisabled    = errors.New("session login disabled")
 	ErrSessionLoginsLocked     = errors.New("session logins locked")
+	ErrSessionTicketInvalid    = errors.New("session ticket invalid or expired")
 )
 
 type Cursor struct {
@@ -37,6 +38,11 @@ type SessionState struct {
 	WatchingSince *time.Time `json:"watching_since,omitempty"`
 	// when the session was last not watching
 	NotWatchingSince *time.Time `json:"not_watching_since,omitempty"`
+
+	// round-trip time of the last websocket heartbeat in milliseconds
+	WebSocketLatency float64 `json:"websocket_latency,omitempty"`
+	// round-trip time of the last webrtc heartbeat in milliseconds
+	WebRTCLatency float64 `json:"webrtc_latency,omitempty"`
 }
 
 type Settings struct {
@@ -63,6 +69,13 @@ type Stats struct {
 	LastAdminLeftAt *time.Time `json:"last_admin_left_at,omitempty"`
 }
 
+type RateLimit struct {
+	// events per second, zero or negative means unlimited
+	Rate float64 `mapstructure:"rate" json:"rate"`
+	// maximum number of events allowed at once
+	Burst int `mapstructure:"burst" json:"burst"`
+}
+
 type Session interface {
 	ID() string
 	Profile() MemberProfile
@@ -77,6 +90,13 @@ type Session interface {
 	// cursor
 	SetCursor(cursor Cursor)
 
+	// rate limit
+	AllowEvent(event string) bool
+
+	// heartbeat, zero rtt means that peer is alive but latency was not measured
+	WebSocketHeartbeat(rtt time.Duration)
+	WebRTCHeartbeat(rtt time.Duration)
+
 	// websocket
 	ConnectWebSocketPeer(websocketPeer WebSocketPeer)
 	DisconnectWebSocketPeer(websocketPeer WebSocketPeer, delayed bool)
@@ -126,4 +146,7 @@ type SessionManager interface {
 	CookieSetToken(w http.ResponseWriter, token string)
 	CookieClearToken(w http.ResponseWriter, r *http.Request)
 	Authenticate(r *http.Request) (Session, error)
+
+	CreateTicket(session Session) (string, time.Time, error)
+	AuthenticateTicket(ticket string) (Session, error)
 }
@@ -2,6 +2,7 @@ package types
 
 import (
 	"errors"
+	"time"
 
 	"github.com/pion/webrtc/v4"
 )
@@ -10,12 +11,15 @@ var (
 	ErrWebRTCDataChannelNotFound = errors.New("webrtc data channel not found")
 	ErrWebRTCConnectionNotFound  = errors.New("webrtc connection not found")
 	ErrWebRTCStreamNotFound      = errors.New("webrtc stream not found")
+	ErrWebRTCCursorsNotEnabled   = errors.New("webrtc inactive cursors not enabled")
 )
 
 type ICEServer struct {
 	URLs       []string `mapstructure:"urls"       json:"urls"`
 	Username   string   `mapstructure:"username"   json:"username,omitempty"`
 	Credential string   `mapstructure:"credential" json:"credential,omitempty"`
+	// shared secret for generating ephemeral credentials, never sent to clients
+	Secret string `mapstructure:"secret" json:"-"`
 }
 
 type PeerVideo struct {
@@ -23,6 +27,7 @@ type PeerVideo struct {
 	ID       string `json:"id"`
 	Video    string `json:"video"` // TODO: Remove this, used for compatibility with old clients.
 	Auto     bool   `json:"auto"`
+	Codec    string `json:"codec,omitempty"`
 }
 
 type PeerVideoRequest struct {
@@ -39,6 +44,45 @@ type PeerAudioRequest struct {
 	Disabled *bool `json:"disabled,omitempty"`
 }
 
+type WebRTCCandidate struct {
+	Type     string `json:"type"`
+	Protocol string `json:"protocol"`
+	Address  string `json:"address"`
+	Port     int32  `json:"port"`
+}
+
+type WebRTCCandidatePair struct {
+	Local  WebRTCCandidate `json:"local"`
+	Remote WebRTCCandidate `json:"remote"`
+}
+
+type WebRTCStats struct {
+	State   string `json:"state"`
+	VideoID string `json:"video_id,omitempty"`
+
+	// selected candidate pair and its round-trip time in milliseconds
+	CandidatePair    *WebRTCCandidatePair `json:"candidate_pair,omitempty"`
+	RTT              float64              `json:"rtt"`
+	RemoteCandidates int                  `json:"remote_candidates"`
+
+	// bitrates are in bits per second
+	EstimatedMaximumBitrate float64 `json:"estimated_maximum_bitrate"`
+	EstimatedTargetBitrate  float64 `json:"estimated_target_bitrate"`
+
+	// last receiver report, jitter is in timestamp units
+	PacketsLost  uint32  `json:"packets_lost"`
+	FractionLost float64 `json:"fraction_lost"`
+	Jitter       uint32  `json:"jitter"`
+	Nacks        uint64  `json:"nacks"`
+
+	BytesSent         uint64 `json:"bytes_sent"`
+	BytesReceived     uint64 `json:"bytes_received"`
+	DataBytesSent     uint64 `json:"data_bytes_sent"`
+	DataBytesReceived uint64 `json:"data_bytes_received"`
+
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
 type WebRTCPeer interface {
 	CreateOffer(ICERestart bool) (*webrtc.SessionDescription, error)
 	CreateAnswer() (*webrtc.SessionDescription, error)
@@ -55,7 +99,16 @@ type WebRTCPeer interface {
 
 	SendCursorPosition(x, y int) error
 	SendCursorImage(cur *CursorImage, img []byte) error
+	SetInactiveCursors(enabled bool)
+	SendInactiveCursors(cursors map[string][]Cursor) error
+
+	Stats() WebRTCStats
+	Done() <-chan struct{}
+	Destroy()
+}
 
+type WebRTCIngestPeer interface {
+	Done() <-chan struct{}
 	Destroy()
 }
 
@@ -63,8 +116,12 @@ type WebRTCManager interface {
 	Start()
 	Shutdown() error
 
-	ICEServers() []ICEServer
+	ICEServers(session Session) []ICEServer
 
-	CreatePeer(session Session) (*webrtc.SessionDescription, WebRTCPeer, error)
+	// without offer, local offer is created, otherwise remote offer is answered
+	// video codec is chosen from codecs in order of preference, or from the offer
+	CreatePeer(session Session, offer *webrtc.SessionDescription, videoCodecs []string) (*webrtc.SessionDescription, WebRTCPeer, error)
+	// receive only peer, that forwards remote media to webcam and microphone
+	CreateIngestPeer(session Session, offer webrtc.SessionDescription) (*webrtc.SessionDescription, WebRTCIngestPeer, error)
 	SetCursorPosition(x, y int)
 }
@@ -0,0 +1,30 @@
+package xorg
+
+import "unicode"
+
+// unicode characters outside of latin-1 are mapped to keysyms
+// with this offset, see keysymdef.h for more details
+const unicodeKeysymOffset = 0x01000000
+
+// get keysym producing given character, 0 if it cannot be typed
+func runeToKeysym(r rune) uint32 {
+	switch r {
+	case '\n', '\r':
+		return XK_Return
+	case '\t':
+		return XK_Tab
+	case '\b':
+		return XK_BackSpace
+	}
+
+	if unicode.IsControl(r) || r == unicode.ReplacementChar || r > unicode.MaxRune {
+		return 0
+	}
+
+	// latin-1 keysyms match their code points
+	if r < 0x100 {
+		return uint32(r)
+	}
+
+	return unicodeKeysymOffset | uint32(r)
+}
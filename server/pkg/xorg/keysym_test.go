@@ -0,0 +1,45 @@
+package xorg
+
+import (
+	"testing"
+	"unicode"
+)
+
+func TestRuneToKeysym(t *testing.T) {
+	tests := []struct {
+		name string
+		r    rune
+		want uint32
+	}{
+		// latin-1
+		{"space", ' ', XK_space},
+		{"ascii letter", 'a', XK_a},
+		{"ascii digit", '7', XK_7},
+		{"latin-1 letter", 'é', XK_eacute},
+		{"last latin-1", 'ÿ', XK_ydiaeresis},
+		// unicode offset
+		{"euro sign", '€', 0x010020ac},
+		{"cyrillic letter", 'ж', 0x01000436},
+		{"emoji", '😀', 0x0101f600},
+		// control characters
+		{"newline", '\n', XK_Return},
+		{"carriage return", '\r', XK_Return},
+		{"tab", '\t', XK_Tab},
+		{"backspace", '\b', XK_BackSpace},
+		{"null", 0x00, 0},
+		{"escape", 0x1b, 0},
+		{"delete", 0x7f, 0},
+		{"c1 control", 0x85, 0},
+		// invalid
+		{"replacement character", unicode.ReplacementChar, 0},
+		{"above max rune", unicode.MaxRune + 1, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := runeToKeysym(tt.r); got != tt.want {
+				t.Errorf("runeToKeysym(%q) = %#x, want %#x", tt.r, got, tt.want)
+			}
+		})
+	}
+}
@@ -65,6 +65,38 @@ void XMove(int x, int y) {
   XSync(display, 0);
 }
 
+void XMoveRelative(int deltaX, int deltaY) {
+  Display *display = getXDisplay();
+  XTestFakeRelativeMotionEvent(display, deltaX, deltaY, CurrentTime);
+  XSync(display, 0);
+}
+
+// X does not tell other clients about active grabs, but pointer grabbed
+// by another client cannot be grabbed again, so we try and release it.
+// Other clients see our short grab, so this should not be called often.
+int XPointerGrabbed(void) {
+  Display *display = getXDisplay();
+  Window root = DefaultRootWindow(display);
+
+  // pressed button grabs pointer implicitly, that cannot be told apart from grab
+  // of an application and must not be interrupted, so the result is unknown
+  Window rootReturn, childReturn;
+  int i;
+  unsigned mask;
+  XQueryPointer(display, root, &rootReturn, &childReturn, &i, &i, &i, &i, &mask);
+  if (mask & (Button1Mask | Button2Mask | Button3Mask | Button4Mask | Button5Mask)) {
+    return -1;
+  }
+
+  int status = XGrabPointer(display, root, False, 0, GrabModeAsync, GrabModeAsync, None, None, CurrentTime);
+  if (status == GrabSuccess) {
+    XUngrabPointer(display, CurrentTime);
+    XSync(display, 0);
+  }
+
+  return status == AlreadyGrabbed;
+}
+
 void XCursorPosition(int *x, int *y) {
   Display *display = getXDisplay();
   Window root = DefaultRootWindow(display);
@@ -194,7 +226,7 @@ KeyCode XkbKeysymToKeycode(Display* dpy, KeySym keysym) {
 }
 
 // From https://github.com/TigerVNC/tigervnc/blob/a434ef3377943e89165ac13c537cd0f28be97f84/unix/x0vncserver/XDesktop.cxx#L401-L453
-KeyCode XkbAddKeyKeysym(Display* dpy, KeySym keysym) {
+KeyCode XkbAddKeyKeysym(Display* dpy, KeySym keysym, int oneLevel) {
   int types[1];
   unsigned int key;
   XkbDescPtr xkb;
@@ -222,6 +254,10 @@ KeyCode XkbAddKeyKeysym(Display* dpy, KeySym keysym) {
 
   XConvertCase(keysym, &lower, &upper);
 
+  // keysym must be produced regardless of shift and caps lock
+  if (oneLevel)
+    upper = lower = keysym;
+
   if (upper == lower)
     types[XkbGroup1Index] = XkbOneLevelIndex;
   else
@@ -264,7 +300,7 @@ void XKey(KeySym keysym, int down) {
 
   // Map non-existing keysyms to new keycodes
   if (keycode == 0)
-    keycode = XkbAddKeyKeysym(display, keysym);
+    keycode = XkbAddKeyKeysym(display, keysym, 0);
 
   if (down)
     XKeyEntryAdd(keysym, keycode);
@@ -281,6 +317,72 @@ void XKey(KeySym keysym, int down) {
   XSync(display, 0);
 }
 
+// Based on XDesktop::deleteAddedKeysyms from TigerVNC
+void XkbDeleteKeyKeysyms(Display* dpy, KeyCode *keycodes, int count) {
+  XkbDescPtr xkb;
+  XkbMapChangesRec changes;
+  KeyCode lowest, highest;
+
+  xkb = XkbGetMap(dpy, XkbAllComponentsMask, XkbUseCoreKbd);
+
+  if (!xkb)
+    return;
+
+  memset(&changes, 0, sizeof(changes));
+
+  lowest = xkb->max_key_code;
+  highest = xkb->min_key_code;
+  for (int i = 0; i < count; i++) {
+    if (XkbKeyNumGroups(xkb, keycodes[i]) == 0)
+      continue;
+
+    XkbChangeTypesOfKey(xkb, keycodes[i], 0, XkbGroup1Mask, NULL, &changes);
+
+    if (keycodes[i] < lowest)
+      lowest = keycodes[i];
+    if (keycodes[i] > highest)
+      highest = keycodes[i];
+  }
+
+  // skip if there was nothing to remove
+  if (highest >= lowest) {
+    changes.changed |= XkbKeySymsMask;
+    changes.first_key_sym = lowest;
+    changes.num_key_syms = highest - lowest + 1;
+    XkbChangeMap(dpy, xkb, &changes);
+  }
+
+  XkbFreeKeyboard(xkb, XkbAllComponentsMask, True);
+}
+
+// Press and release keysym, mapping it to a free keycode if no existing keycode produces
+// it with current modifiers. Returns the mapped keycode, that must be removed by caller,
+// 0 if existing keycode was used and -1 if there are no free keycodes left.
+int XKeyType(KeySym keysym) {
+  Display *display = getXDisplay();
+  KeyCode keycode = XkbKeysymToKeycode(display, keysym);
+  int added = 0;
+
+  if (keycode == 0) {
+    keycode = XkbAddKeyKeysym(display, keysym, 1);
+    if (keycode == 0)
+      return -1;
+
+    added = keycode;
+  }
+
+  if (XTEST_KEYBOARD != NULL) {
+    XTestFakeDeviceKeyEvent(display, XTEST_KEYBOARD, keycode, 1, NULL, 0, CurrentTime);
+    XTestFakeDeviceKeyEvent(display, XTEST_KEYBOARD, keycode, 0, NULL, 0, CurrentTime);
+  } else {
+    XTestFakeKeyEvent(display, keycode, 1, CurrentTime);
+    XTestFakeKeyEvent(display, keycode, 0, CurrentTime);
+  }
+  XSync(display, 0);
+
+  return added;
+}
+
 Status XSetScreenConfiguration(int width, int height, short rate) {
   Display *display = getXDisplay();
   Window root = DefaultRootWindow(display);
@@ -78,6 +78,23 @@ func Move(x, y int) {
 	C.XMove(C.int(x), C.int(y))
 }
 
+func MoveRelative(deltaX, deltaY int) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	C.XMoveRelative(C.int(deltaX), C.int(deltaY))
+}
+
+// IsPointerGrabbed reports whether pointer is grabbed by another client,
+// ok is false when it cannot be told, because a button is held.
+func IsPointerGrabbed() (grabbed bool, ok bool) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	status := int(C.XPointerGrabbed())
+	return status == 1, status >= 0
+}
+
 func GetCursorPosition() (int, int) {
 	mu.Lock()
 	defer mu.Unlock()
@@ -196,6 +213,59 @@ func CheckKeys(duration time.Duration) {
 	}
 }
 
+// type text character by character, keysyms missing in current keyboard
+// map are mapped to free keycodes only for the time of typing
+func TypeText(text string) error {
+	added := []C.KeyCode{}
+	removeAdded := func() {
+		if len(added) == 0 {
+			return
+		}
+
+		// give clients time to translate typed keys before their mapping is removed,
+		// lock is not held meanwhile, so that other input is not blocked
+		time.Sleep(50 * time.Millisecond)
+
+		mu.Lock()
+		C.XkbDeleteKeyKeysyms(C.getXDisplay(), &added[0], C.int(len(added)))
+		mu.Unlock()
+
+		added = added[:0]
+	}
+	defer removeAdded()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, r := range text {
+		keysym := runeToKeysym(r)
+		if keysym == 0 {
+			continue
+		}
+
+		status := C.XKeyType(C.KeySym(keysym))
+
+		// free keycodes might be used up by previous characters
+		if status < 0 && len(added) > 0 {
+			mu.Unlock()
+			removeAdded()
+			mu.Lock()
+
+			status = C.XKeyType(C.KeySym(keysym))
+		}
+
+		if status < 0 {
+			return fmt.Errorf("no free keycode for character %q", r)
+		}
+
+		if status > 0 {
+			added = append(added, C.KeyCode(status))
+		}
+	}
+
+	return nil
+}
+
 // set screen configuration, create new one if not exists
 func ChangeScreenSize(s types.ScreenSize) (types.ScreenSize, error) {
 	mu.Lock()
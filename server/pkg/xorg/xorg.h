@@ -22,6 +22,8 @@ int XDisplayOpen(char *input);
 void XDisplayClose(void);
 
 void XMove(int x, int y);
+void XMoveRelative(int deltaX, int deltaY);
+int XPointerGrabbed(void);
 void XCursorPosition(int *x, int *y);
 void XScroll(int deltaX, int deltaY);
 void XButton(unsigned int button, int down);
@@ -36,6 +38,8 @@ static void XKeyEntryAdd(KeySym keysym, KeyCode keycode);
 static KeyCode XKeyEntryGet(KeySym keysym);
 static KeyCode XkbKeysymToKeycode(Display *dpy, KeySym keysym);
 void XKey(KeySym keysym, int down);
+void XkbDeleteKeyKeysyms(Display *dpy, KeyCode *keycodes, int count);
+int XKeyType(KeySym keysym);
 
 Status XSetScreenConfiguration(int width, int height, short rate);
 void XGetScreenConfiguration(int *width, int *height, short *rate);